@@ -0,0 +1,78 @@
+// Command jsonconv applies a jq-style expression to JSON read from stdin and
+// prints each result on its own line.
+//
+//	echo '{"users":[{"name":"bob"}]}' | jsonconv -r '.users[].name'
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/Andrew-M-C/go-tools/jsonconv"
+	"github.com/Andrew-M-C/go-tools/jsonconv/jq"
+)
+
+func main() {
+	raw := flag.Bool("r", false, "output strings without quotes")
+	nullInput := flag.Bool("n", false, "use null as input instead of reading stdin")
+	sortKeys := flag.Bool("S", false, "sort object keys in output")
+	asciiOutput := flag.Bool("a", false, "escape non-ASCII characters in output")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <expression>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	expr := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		expr = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	q, err := jq.Compile(expr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(3)
+	}
+
+	input := jsonconv.NewNull()
+	if false == *nullInput {
+		b, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "jsonconv: read stdin:", err)
+			os.Exit(2)
+		}
+		input, err = jsonconv.NewFromString(string(b))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "jsonconv: parse input:", err)
+			os.Exit(2)
+		}
+	}
+
+	opt := jsonconv.Option{ShowNull: true, EnsureAscii: *asciiOutput}
+	if *sortKeys {
+		opt.SortMode = jsonconv.DictAsc
+	}
+	err = q.Iterate(input, func(v *jsonconv.JsonValue) error {
+		if *raw && v.IsString() {
+			fmt.Println(v.String())
+			return nil
+		}
+		s, err := v.Marshal(opt)
+		if err != nil {
+			return err
+		}
+		fmt.Println(s)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(5)
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/Andrew-M-C/go-tools
 
-go 1.12
+go 1.13
 
 require (
 	github.com/buger/jsonparser v0.0.0-20181115193947-bf1c66bbce23
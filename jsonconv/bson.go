@@ -0,0 +1,1014 @@
+package jsonconv
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// BSON element types, see http://bsonspec.org/spec.html
+const (
+	bsonDouble     byte = 0x01
+	bsonString     byte = 0x02
+	bsonDocument   byte = 0x03
+	bsonArray      byte = 0x04
+	bsonBinary     byte = 0x05
+	bsonUndefined  byte = 0x06
+	bsonObjectID   byte = 0x07
+	bsonBool       byte = 0x08
+	bsonDateTime   byte = 0x09
+	bsonNull       byte = 0x0A
+	bsonRegex      byte = 0x0B
+	bsonDBPointer  byte = 0x0C
+	bsonJavaScript byte = 0x0D
+	bsonSymbol     byte = 0x0E
+	bsonCodeScope  byte = 0x0F
+	bsonInt32      byte = 0x10
+	bsonTimestamp  byte = 0x11
+	bsonInt64      byte = 0x12
+	bsonDecimal128 byte = 0x13
+	bsonMinKey     byte = 0xFF
+	bsonMaxKey     byte = 0x7F
+)
+
+const bsonMaxDocumentSize = 16 * 1024 * 1024
+
+const extJSONTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// ====================
+// BSON -> JsonValue
+
+// NewFromBSON decodes a single BSON document into a JsonValue object. BSON
+// specific types are represented with MongoDB Extended JSON v2 wrappers,
+// e.g. ObjectId becomes {"$oid": "..."}. Option.CanonicalBSON selects the
+// canonical flavour instead of the relaxed one. Strings and keys which are
+// not valid UTF-8 give BSONFormatError.
+//
+// As JsonValue objects do not keep key order, the order of fields in the
+// document is lost, and MarshalBSON does not restore it.
+func NewFromBSON(b []byte, opts ...Option) (*JsonValue, error) {
+	opt := &dftOption
+	if len(opts) > 0 {
+		opt = &opts[0]
+	}
+	d := bsonDecoder{buf: b, canonical: opt.CanonicalBSON}
+	obj, err := d.readDocument(false)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(b) {
+		return nil, BSONFormatError
+	}
+	return obj, nil
+}
+
+type bsonDecoder struct {
+	buf       []byte
+	pos       int
+	canonical bool
+}
+
+func (d *bsonDecoder) need(n int) error {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return BSONFormatError
+	}
+	return nil
+}
+
+func (d *bsonDecoder) readBytes(n int) ([]byte, error) {
+	if err := d.need(n); err != nil {
+		return nil, err
+	}
+	ret := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return ret, nil
+}
+
+func (d *bsonDecoder) readInt32() (int32, error) {
+	b, err := d.readBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (d *bsonDecoder) readUint64() (uint64, error) {
+	b, err := d.readBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (d *bsonDecoder) readCString() (string, error) {
+	end := bytes.IndexByte(d.buf[d.pos:], 0)
+	if end < 0 {
+		return "", BSONFormatError
+	}
+	b := d.buf[d.pos : d.pos+end]
+	if false == utf8.Valid(b) {
+		return "", BSONFormatError
+	}
+	d.pos += end + 1
+	return string(b), nil
+}
+
+func (d *bsonDecoder) readString() (string, error) {
+	l, err := d.readInt32()
+	if err != nil {
+		return "", err
+	}
+	if l < 1 {
+		return "", BSONFormatError
+	}
+	b, err := d.readBytes(int(l))
+	if err != nil {
+		return "", err
+	}
+	if b[l-1] != 0 || false == utf8.Valid(b[:l-1]) {
+		return "", BSONFormatError
+	}
+	return string(b[:l-1]), nil
+}
+
+func (d *bsonDecoder) readDocument(isArray bool) (*JsonValue, error) {
+	start := d.pos
+	size, err := d.readInt32()
+	if err != nil {
+		return nil, err
+	}
+	if size < 5 || d.need(int(size)-4) != nil {
+		return nil, BSONFormatError
+	}
+	end := start + int(size)
+
+	var obj *JsonValue
+	if isArray {
+		obj = NewArray()
+	} else {
+		obj = NewObject()
+	}
+	for {
+		if d.pos >= end {
+			return nil, BSONFormatError
+		}
+		t := d.buf[d.pos]
+		d.pos++
+		if 0 == t {
+			break
+		}
+		key, err := d.readCString()
+		if err != nil {
+			return nil, err
+		}
+		child, err := d.readElement(t)
+		if err != nil {
+			return nil, err
+		}
+		if isArray {
+			obj.arrChildren = append(obj.arrChildren, child)
+		} else {
+			obj.objChildren[key] = child
+		}
+	}
+	if d.pos != end {
+		return nil, BSONFormatError
+	}
+	return obj, nil
+}
+
+func (d *bsonDecoder) readElement(t byte) (*JsonValue, error) {
+	switch t {
+	case bsonDouble:
+		u, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		f := math.Float64frombits(u)
+		if d.canonical || math.IsInf(f, 0) || math.IsNaN(f) {
+			return extJSONWrap("$numberDouble", NewString(formatExtJSONDouble(f))), nil
+		}
+		return NewFloat(f), nil
+
+	case bsonString:
+		s, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		return NewString(s), nil
+
+	case bsonDocument:
+		return d.readDocument(false)
+
+	case bsonArray:
+		return d.readDocument(true)
+
+	case bsonBinary:
+		l, err := d.readInt32()
+		if err != nil {
+			return nil, err
+		}
+		if l < 0 {
+			return nil, BSONFormatError
+		}
+		sub, err := d.readBytes(1)
+		if err != nil {
+			return nil, err
+		}
+		data, err := d.readBytes(int(l))
+		if err != nil {
+			return nil, err
+		}
+		// the deprecated subtype 0x02 carries a redundant inner length
+		if 0x02 == sub[0] && len(data) >= 4 {
+			data = data[4:]
+		}
+		bin := NewObject()
+		bin.objChildren["base64"] = NewString(base64.StdEncoding.EncodeToString(data))
+		bin.objChildren["subType"] = NewString(hex.EncodeToString(sub))
+		return extJSONWrap("$binary", bin), nil
+
+	case bsonUndefined:
+		return extJSONWrap("$undefined", NewBool(true)), nil
+
+	case bsonObjectID:
+		b, err := d.readBytes(12)
+		if err != nil {
+			return nil, err
+		}
+		return extJSONWrap("$oid", NewString(hex.EncodeToString(b))), nil
+
+	case bsonBool:
+		b, err := d.readBytes(1)
+		if err != nil {
+			return nil, err
+		}
+		switch b[0] {
+		case 0:
+			return NewBool(false), nil
+		case 1:
+			return NewBool(true), nil
+		default:
+			return nil, BSONFormatError
+		}
+
+	case bsonDateTime:
+		u, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		ms := int64(u)
+		t := time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)).UTC()
+		if d.canonical || t.Year() < 1970 || t.Year() > 9999 {
+			return extJSONWrap("$date", extJSONWrap("$numberLong", NewString(strconv.FormatInt(ms, 10)))), nil
+		}
+		return extJSONWrap("$date", NewString(t.Format(extJSONTimeLayout))), nil
+
+	case bsonNull:
+		return NewNull(), nil
+
+	case bsonRegex:
+		pattern, err := d.readCString()
+		if err != nil {
+			return nil, err
+		}
+		options, err := d.readCString()
+		if err != nil {
+			return nil, err
+		}
+		re := NewObject()
+		re.objChildren["pattern"] = NewString(pattern)
+		re.objChildren["options"] = NewString(options)
+		return extJSONWrap("$regularExpression", re), nil
+
+	case bsonDBPointer:
+		ns, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		id, err := d.readBytes(12)
+		if err != nil {
+			return nil, err
+		}
+		ptr := NewObject()
+		ptr.objChildren["$ref"] = NewString(ns)
+		ptr.objChildren["$id"] = extJSONWrap("$oid", NewString(hex.EncodeToString(id)))
+		return extJSONWrap("$dbPointer", ptr), nil
+
+	case bsonJavaScript:
+		s, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		return extJSONWrap("$code", NewString(s)), nil
+
+	case bsonSymbol:
+		s, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		return extJSONWrap("$symbol", NewString(s)), nil
+
+	case bsonCodeScope:
+		start := d.pos
+		l, err := d.readInt32()
+		if err != nil {
+			return nil, err
+		}
+		code, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		scope, err := d.readDocument(false)
+		if err != nil {
+			return nil, err
+		}
+		if d.pos-start != int(l) {
+			return nil, BSONFormatError
+		}
+		ret := extJSONWrap("$code", NewString(code))
+		ret.objChildren["$scope"] = scope
+		return ret, nil
+
+	case bsonInt32:
+		i, err := d.readInt32()
+		if err != nil {
+			return nil, err
+		}
+		if d.canonical {
+			return extJSONWrap("$numberInt", NewString(strconv.FormatInt(int64(i), 10))), nil
+		}
+		return NewInt32(i), nil
+
+	case bsonTimestamp:
+		u, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		ts := NewObject()
+		ts.objChildren["t"] = NewUint32(uint32(u >> 32))
+		ts.objChildren["i"] = NewUint32(uint32(u))
+		return extJSONWrap("$timestamp", ts), nil
+
+	case bsonInt64:
+		u, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		if d.canonical {
+			return extJSONWrap("$numberLong", NewString(strconv.FormatInt(int64(u), 10))), nil
+		}
+		return NewInt64(int64(u)), nil
+
+	case bsonDecimal128:
+		lo, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		hi, err := d.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		return extJSONWrap("$numberDecimal", NewString(decimal128ToString(hi, lo))), nil
+
+	case bsonMinKey:
+		return extJSONWrap("$minKey", NewInt(1)), nil
+
+	case bsonMaxKey:
+		return extJSONWrap("$maxKey", NewInt(1)), nil
+
+	default:
+		return nil, BSONFormatError
+	}
+}
+
+func extJSONWrap(key string, v *JsonValue) *JsonValue {
+	obj := NewObject()
+	obj.objChildren[key] = v
+	return obj
+}
+
+func formatExtJSONDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	s := strconv.FormatFloat(f, 'G', -1, 64)
+	if false == strings.ContainsAny(s, ".EN") {
+		s += ".0"
+	}
+	return s
+}
+
+// ====================
+// BSON dump reader
+
+// BSONReader reads concatenated BSON documents, as written by mongodump into
+// .bson files.
+type BSONReader struct {
+	r   io.Reader
+	opt Option
+}
+
+// NewBSONReader returns a reader decoding documents from r one by one.
+func NewBSONReader(r io.Reader, opts ...Option) *BSONReader {
+	ret := BSONReader{r: r, opt: dftOption}
+	if len(opts) > 0 {
+		ret.opt = opts[0]
+	}
+	return &ret
+}
+
+// Next returns the next document in the stream, or io.EOF when the stream
+// ends cleanly between two documents.
+func (br *BSONReader) Next() (*JsonValue, error) {
+	head := make([]byte, 4)
+	n, err := io.ReadFull(br.r, head)
+	if err != nil {
+		if err == io.EOF && 0 == n {
+			return nil, io.EOF
+		}
+		return nil, BSONFormatError
+	}
+	size := int(int32(binary.LittleEndian.Uint32(head)))
+	if size < 5 || size > bsonMaxDocumentSize {
+		return nil, BSONFormatError
+	}
+	doc := make([]byte, size)
+	copy(doc, head)
+	if _, err = io.ReadFull(br.r, doc[4:]); err != nil {
+		return nil, BSONFormatError
+	}
+	return NewFromBSON(doc, br.opt)
+}
+
+// ForEach calls callback for every remaining document in the stream.
+func (br *BSONReader) ForEach(callback func(doc *JsonValue) error) error {
+	for {
+		doc, err := br.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = callback(doc); err != nil {
+			return err
+		}
+	}
+}
+
+// ====================
+// JsonValue -> BSON
+
+// MarshalBSON encodes an object into a BSON document. Extended JSON v2
+// wrappers, either canonical or relaxed, are converted back to their BSON
+// types. Fields are written in random order unless Option.SortMode is set,
+// so a document read by NewFromBSON generally comes back in another order.
+// Integers above math.MaxInt64 are written as Decimal128, which NewFromBSON
+// reads back as {"$numberDecimal": "..."}.
+func (obj *JsonValue) MarshalBSON(opts ...Option) ([]byte, error) {
+	if false == obj.IsObject() {
+		return nil, NotAnObjectError
+	}
+	opt := &dftOption
+	if len(opts) > 0 {
+		opt = &opts[0]
+	}
+	b := bytes.Buffer{}
+	if err := writeBSONDocument(&b, obj, opt); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeBSONDocument(b *bytes.Buffer, obj *JsonValue, opt *Option) error {
+	start := b.Len()
+	b.Write([]byte{0, 0, 0, 0})
+
+	var err error
+	if obj.IsArray() {
+		for i, child := range obj.arrChildren {
+			if err = writeBSONElement(b, strconv.Itoa(i), child, opt); err != nil {
+				return err
+			}
+		}
+	} else if Random != opt.SortMode {
+		for _, pair := range sortObjects(obj, opt.SortMode) {
+			if err = writeBSONElement(b, pair.K, pair.V, opt); err != nil {
+				return err
+			}
+		}
+	} else {
+		for k, child := range obj.objChildren {
+			if err = writeBSONElement(b, k, child, opt); err != nil {
+				return err
+			}
+		}
+	}
+	b.WriteByte(0)
+
+	size := b.Len() - start
+	binary.LittleEndian.PutUint32(b.Bytes()[start:], uint32(size))
+	return nil
+}
+
+func writeBSONCString(b *bytes.Buffer, s string) error {
+	if strings.IndexByte(s, 0) >= 0 {
+		return DataTypeError
+	}
+	b.WriteString(s)
+	b.WriteByte(0)
+	return nil
+}
+
+func writeBSONString(b *bytes.Buffer, s string) {
+	l := make([]byte, 4)
+	binary.LittleEndian.PutUint32(l, uint32(len(s)+1))
+	b.Write(l)
+	b.WriteString(s)
+	b.WriteByte(0)
+}
+
+func writeBSONUint64(b *bytes.Buffer, u uint64) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, u)
+	b.Write(buf)
+}
+
+func writeBSONInt32(b *bytes.Buffer, i int32) {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(i))
+	b.Write(buf)
+}
+
+func writeBSONHeader(b *bytes.Buffer, t byte, key string) error {
+	b.WriteByte(t)
+	return writeBSONCString(b, key)
+}
+
+func writeBSONElement(b *bytes.Buffer, key string, v *JsonValue, opt *Option) error {
+	switch v.valueType {
+	case String:
+		if err := writeBSONHeader(b, bsonString, key); err != nil {
+			return err
+		}
+		writeBSONString(b, v.stringValue)
+		return nil
+
+	case Boolean:
+		if err := writeBSONHeader(b, bsonBool, key); err != nil {
+			return err
+		}
+		if v.boolValue {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+		return nil
+
+	case Null:
+		return writeBSONHeader(b, bsonNull, key)
+
+	case Number:
+		switch kind := v.numberKind(); {
+		case numberIsFloat == kind:
+			if err := writeBSONHeader(b, bsonDouble, key); err != nil {
+				return err
+			}
+			writeBSONUint64(b, math.Float64bits(v.floatValue))
+		case numberIsUint == kind:
+			// beyond int64, a double would lose precision
+			hi, lo, err := decimal128FromString(strconv.FormatUint(v.uintValue, 10))
+			if err != nil {
+				return err
+			}
+			if err = writeBSONHeader(b, bsonDecimal128, key); err != nil {
+				return err
+			}
+			writeBSONUint64(b, lo)
+			writeBSONUint64(b, hi)
+		case v.intValue >= math.MinInt32 && v.intValue <= math.MaxInt32:
+			if err := writeBSONHeader(b, bsonInt32, key); err != nil {
+				return err
+			}
+			writeBSONInt32(b, int32(v.intValue))
+		default:
+			if err := writeBSONHeader(b, bsonInt64, key); err != nil {
+				return err
+			}
+			writeBSONUint64(b, uint64(v.intValue))
+		}
+		return nil
+
+	case Array:
+		if err := writeBSONHeader(b, bsonArray, key); err != nil {
+			return err
+		}
+		return writeBSONDocument(b, v, opt)
+
+	case Object:
+		if handled, err := writeBSONExtJSON(b, key, v, opt); handled {
+			return err
+		}
+		if err := writeBSONHeader(b, bsonDocument, key); err != nil {
+			return err
+		}
+		return writeBSONDocument(b, v, opt)
+
+	default:
+		return JsonTypeError
+	}
+}
+
+// writeBSONExtJSON recognizes Extended JSON v2 wrappers. It returns false if v
+// is an ordinary object.
+func writeBSONExtJSON(b *bytes.Buffer, key string, v *JsonValue, opt *Option) (bool, error) {
+	l := len(v.objChildren)
+	if l < 1 || l > 2 {
+		return false, nil
+	}
+	if 2 == l {
+		code, codeExist := v.objChildren["$code"]
+		scope, scopeExist := v.objChildren["$scope"]
+		if false == codeExist || false == scopeExist || false == code.IsString() || false == scope.IsObject() {
+			return false, nil
+		}
+		if err := writeBSONHeader(b, bsonCodeScope, key); err != nil {
+			return true, err
+		}
+		start := b.Len()
+		b.Write([]byte{0, 0, 0, 0})
+		writeBSONString(b, code.stringValue)
+		if err := writeBSONDocument(b, scope, opt); err != nil {
+			return true, err
+		}
+		binary.LittleEndian.PutUint32(b.Bytes()[start:], uint32(b.Len()-start))
+		return true, nil
+	}
+
+	var name string
+	var inner *JsonValue
+	for name, inner = range v.objChildren {
+	}
+
+	switch name {
+	case "$oid":
+		id, err := hex.DecodeString(inner.String())
+		if err != nil || len(id) != 12 {
+			return true, BSONFormatError
+		}
+		if err = writeBSONHeader(b, bsonObjectID, key); err != nil {
+			return true, err
+		}
+		b.Write(id)
+		return true, nil
+
+	case "$date":
+		var ms int64
+		switch inner.valueType {
+		case String:
+			t, err := time.Parse(time.RFC3339Nano, inner.stringValue)
+			if err != nil {
+				return true, BSONFormatError
+			}
+			ms = t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+		case Number:
+			ms = inner.intValue
+		case Object:
+			s, err := inner.GetString("$numberLong")
+			if err != nil {
+				return true, BSONFormatError
+			}
+			if ms, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return true, BSONFormatError
+			}
+		default:
+			return true, BSONFormatError
+		}
+		if err := writeBSONHeader(b, bsonDateTime, key); err != nil {
+			return true, err
+		}
+		writeBSONUint64(b, uint64(ms))
+		return true, nil
+
+	case "$numberInt":
+		i, err := strconv.ParseInt(inner.String(), 10, 32)
+		if err != nil {
+			return true, BSONFormatError
+		}
+		if err = writeBSONHeader(b, bsonInt32, key); err != nil {
+			return true, err
+		}
+		writeBSONInt32(b, int32(i))
+		return true, nil
+
+	case "$numberLong":
+		i, err := strconv.ParseInt(inner.String(), 10, 64)
+		if err != nil {
+			return true, BSONFormatError
+		}
+		if err = writeBSONHeader(b, bsonInt64, key); err != nil {
+			return true, err
+		}
+		writeBSONUint64(b, uint64(i))
+		return true, nil
+
+	case "$numberDouble":
+		var f float64
+		switch s := inner.String(); s {
+		case "Infinity":
+			f = math.Inf(1)
+		case "-Infinity":
+			f = math.Inf(-1)
+		case "NaN":
+			f = math.NaN()
+		default:
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return true, BSONFormatError
+			}
+		}
+		if err := writeBSONHeader(b, bsonDouble, key); err != nil {
+			return true, err
+		}
+		writeBSONUint64(b, math.Float64bits(f))
+		return true, nil
+
+	case "$numberDecimal":
+		hi, lo, err := decimal128FromString(inner.String())
+		if err != nil {
+			return true, err
+		}
+		if err = writeBSONHeader(b, bsonDecimal128, key); err != nil {
+			return true, err
+		}
+		writeBSONUint64(b, lo)
+		writeBSONUint64(b, hi)
+		return true, nil
+
+	case "$binary":
+		data64, err := inner.GetString("base64")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		subStr, err := inner.GetString("subType")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		data, err := base64.StdEncoding.DecodeString(data64)
+		if err != nil {
+			return true, BSONFormatError
+		}
+		sub, err := strconv.ParseUint(subStr, 16, 8)
+		if err != nil {
+			return true, BSONFormatError
+		}
+		if err = writeBSONHeader(b, bsonBinary, key); err != nil {
+			return true, err
+		}
+		if 0x02 == sub {
+			writeBSONInt32(b, int32(len(data)+4))
+			b.WriteByte(byte(sub))
+			writeBSONInt32(b, int32(len(data)))
+		} else {
+			writeBSONInt32(b, int32(len(data)))
+			b.WriteByte(byte(sub))
+		}
+		b.Write(data)
+		return true, nil
+
+	case "$regularExpression":
+		pattern, err := inner.GetString("pattern")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		options, err := inner.GetString("options")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		if err = writeBSONHeader(b, bsonRegex, key); err != nil {
+			return true, err
+		}
+		if err = writeBSONCString(b, pattern); err != nil {
+			return true, err
+		}
+		return true, writeBSONCString(b, options)
+
+	case "$timestamp":
+		t, err := inner.GetInt64("t")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		i, err := inner.GetInt64("i")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		if err = writeBSONHeader(b, bsonTimestamp, key); err != nil {
+			return true, err
+		}
+		writeBSONUint64(b, uint64(t)<<32|uint64(uint32(i)))
+		return true, nil
+
+	case "$dbPointer":
+		ns, err := inner.GetString("$ref")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		oid, err := inner.GetString("$id", "$oid")
+		if err != nil {
+			return true, BSONFormatError
+		}
+		id, err := hex.DecodeString(oid)
+		if err != nil || len(id) != 12 {
+			return true, BSONFormatError
+		}
+		if err = writeBSONHeader(b, bsonDBPointer, key); err != nil {
+			return true, err
+		}
+		writeBSONString(b, ns)
+		b.Write(id)
+		return true, nil
+
+	case "$code":
+		if false == inner.IsString() {
+			return false, nil
+		}
+		if err := writeBSONHeader(b, bsonJavaScript, key); err != nil {
+			return true, err
+		}
+		writeBSONString(b, inner.stringValue)
+		return true, nil
+
+	case "$symbol":
+		if false == inner.IsString() {
+			return false, nil
+		}
+		if err := writeBSONHeader(b, bsonSymbol, key); err != nil {
+			return true, err
+		}
+		writeBSONString(b, inner.stringValue)
+		return true, nil
+
+	case "$minKey":
+		return true, writeBSONHeader(b, bsonMinKey, key)
+
+	case "$maxKey":
+		return true, writeBSONHeader(b, bsonMaxKey, key)
+
+	case "$undefined":
+		return true, writeBSONHeader(b, bsonUndefined, key)
+
+	default:
+		return false, nil
+	}
+}
+
+// ====================
+// Decimal128, see https://github.com/mongodb/specifications/blob/master/source/bson-decimal128/decimal128.md
+
+const (
+	decimal128ExponentBias = 6176
+	decimal128MaxExponent  = 6111
+	decimal128MinExponent  = -6176
+	decimal128MaxDigits    = 34
+)
+
+func decimal128ToString(hi, lo uint64) string {
+	sign := ""
+	if 0 != hi>>63 {
+		sign = "-"
+	}
+
+	var exp int
+	var sigHi uint64
+	if 3 == (hi>>61)&3 {
+		switch (hi >> 58) & 0x1F {
+		case 0x1E:
+			return sign + "Infinity"
+		case 0x1F:
+			return "NaN"
+		}
+		// the coefficient would exceed 10^34-1, which is treated as zero
+		exp = int((hi>>47)&0x3FFF) - decimal128ExponentBias
+		sigHi, lo = 0, 0
+	} else {
+		exp = int((hi>>49)&0x3FFF) - decimal128ExponentBias
+		sigHi = hi & 0x1FFFFFFFFFFFF
+	}
+
+	coef := new(big.Int).SetUint64(sigHi)
+	coef.Lsh(coef, 64)
+	coef.Or(coef, new(big.Int).SetUint64(lo))
+	if coef.Cmp(decimal128MaxCoefficient()) > 0 {
+		coef.SetInt64(0)
+	}
+	digits := coef.String()
+
+	adjusted := exp + len(digits) - 1
+	if exp > 0 || adjusted < -6 {
+		s := digits[:1]
+		if len(digits) > 1 {
+			s += "." + digits[1:]
+		}
+		if adjusted >= 0 {
+			return sign + s + "E+" + strconv.Itoa(adjusted)
+		}
+		return sign + s + "E" + strconv.Itoa(adjusted)
+	}
+	if 0 == exp {
+		return sign + digits
+	}
+	point := len(digits) + exp
+	if point > 0 {
+		return sign + digits[:point] + "." + digits[point:]
+	}
+	return sign + "0." + strings.Repeat("0", -point) + digits
+}
+
+func decimal128MaxCoefficient() *big.Int {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(decimal128MaxDigits), nil)
+	return max.Sub(max, big.NewInt(1))
+}
+
+func decimal128FromString(s string) (hi, lo uint64, err error) {
+	var signBit uint64
+	body := s
+	if strings.HasPrefix(body, "-") {
+		signBit = 1 << 63
+		body = body[1:]
+	} else if strings.HasPrefix(body, "+") {
+		body = body[1:]
+	}
+
+	switch strings.ToLower(body) {
+	case "inf", "infinity":
+		return signBit | 0x78<<56, 0, nil
+	case "nan":
+		return 0x7C << 56, 0, nil
+	}
+
+	exp := 0
+	if i := strings.IndexAny(body, "eE"); i >= 0 {
+		e, err := strconv.Atoi(body[i+1:])
+		if err != nil {
+			return 0, 0, BSONFormatError
+		}
+		exp = e
+		body = body[:i]
+	}
+	if i := strings.IndexByte(body, '.'); i >= 0 {
+		exp -= len(body) - i - 1
+		body = body[:i] + body[i+1:]
+	}
+	if "" == body {
+		return 0, 0, BSONFormatError
+	}
+	for _, c := range body {
+		if c < '0' || c > '9' {
+			return 0, 0, BSONFormatError
+		}
+	}
+
+	// clamp the exponent by moving trailing zeros, as long as no precision is lost
+	body = strings.TrimLeft(body, "0")
+	for exp > decimal128MaxExponent && len(body) > 0 && len(body) < decimal128MaxDigits {
+		body += "0"
+		exp--
+	}
+	for exp < decimal128MinExponent && strings.HasSuffix(body, "0") {
+		body = body[:len(body)-1]
+		exp++
+	}
+	if "" == body {
+		body = "0"
+		if exp > decimal128MaxExponent {
+			exp = decimal128MaxExponent
+		} else if exp < decimal128MinExponent {
+			exp = decimal128MinExponent
+		}
+	}
+	if len(body) > decimal128MaxDigits || exp > decimal128MaxExponent || exp < decimal128MinExponent {
+		return 0, 0, BSONFormatError
+	}
+
+	coef, _ := new(big.Int).SetString(body, 10)
+	mask := new(big.Int).SetUint64(math.MaxUint64)
+	lo = new(big.Int).And(coef, mask).Uint64()
+	hi = new(big.Int).Rsh(coef, 64).Uint64()
+	hi |= uint64(exp+decimal128ExponentBias) << 49
+	hi |= signBit
+	return hi, lo, nil
+}
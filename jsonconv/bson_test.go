@@ -0,0 +1,163 @@
+package jsonconv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBSONHelloWorld(t *testing.T) {
+	raw := []byte("\x16\x00\x00\x00\x02hello\x00\x06\x00\x00\x00world\x00\x00")
+	obj, err := NewFromBSON(raw)
+	if err != nil {
+		t.Errorf("NewFromBSON error: %v", err)
+		return
+	}
+	if s, _ := obj.GetString("hello"); s != "world" {
+		t.Errorf("unexpected value '%s'", s)
+	}
+
+	b, err := obj.MarshalBSON()
+	if err != nil {
+		t.Errorf("MarshalBSON error: %v", err)
+		return
+	}
+	if false == bytes.Equal(b, raw) {
+		t.Errorf("unexpected BSON: %q", b)
+	}
+}
+
+func TestBSONInvalidUTF8(t *testing.T) {
+	cases := [][]byte{
+		[]byte("\x16\x00\x00\x00\x02hello\x00\x06\x00\x00\x00w\xffrld\x00\x00"),
+		[]byte("\x16\x00\x00\x00\x02h\xc3llo\x00\x06\x00\x00\x00world\x00\x00"),
+	}
+	for _, raw := range cases {
+		if _, err := NewFromBSON(raw); err != BSONFormatError {
+			t.Errorf("%q: expected BSONFormatError, got %v", raw, err)
+		}
+	}
+}
+
+func TestBSONExtJSONRoundTrip(t *testing.T) {
+	s := `{
+		"_id": {"$oid": "5d505646cf6d4fe581014ab2"},
+		"created": {"$date": "2019-08-11T17:54:14.692Z"},
+		"ancient": {"$date": {"$numberLong": "-62135596800000"}},
+		"price": {"$numberDecimal": "1234.5678"},
+		"tiny": {"$numberDecimal": "1.0E-10"},
+		"blob": {"$binary": {"base64": "AQID", "subType": "00"}},
+		"re": {"$regularExpression": {"pattern": "^a", "options": "i"}},
+		"ts": {"$timestamp": {"t": 1565545664, "i": 1}},
+		"count": 42,
+		"big": 9007199254740993,
+		"ratio": 0.25,
+		"tags": ["a", "b", null, true],
+		"nested": {"min": {"$minKey": 1}, "max": {"$maxKey": 1}}
+	}`
+	obj, err := NewFromString(s)
+	if err != nil {
+		t.Errorf("parse error: %v", err)
+		return
+	}
+	opt := Option{SortMode: DictAsc, ShowNull: true}
+	b, err := obj.MarshalBSON(opt)
+	if err != nil {
+		t.Errorf("MarshalBSON error: %v", err)
+		return
+	}
+	back, err := NewFromBSON(b)
+	if err != nil {
+		t.Errorf("NewFromBSON error: %v", err)
+		return
+	}
+
+	expected, _ := obj.Marshal(opt)
+	got, _ := back.Marshal(opt)
+	if got != expected {
+		t.Errorf("round trip mismatch:\nexpected %s\ngot      %s", expected, got)
+	}
+
+	canonical, _ := NewFromBSON(b, Option{CanonicalBSON: true})
+	if v, _ := canonical.GetString("count", "$numberInt"); v != "42" {
+		t.Errorf("unexpected canonical int32 '%s'", v)
+	}
+	if v, _ := canonical.GetString("big", "$numberLong"); v != "9007199254740993" {
+		t.Errorf("unexpected canonical int64 '%s'", v)
+	}
+	if v, _ := canonical.GetString("created", "$date", "$numberLong"); v != "1565546054692" {
+		t.Errorf("unexpected canonical date '%s'", v)
+	}
+}
+
+func TestBSONBigUint(t *testing.T) {
+	obj := NewObject()
+	obj.SetUint64(18446744073709551615, "n")
+	b, err := obj.MarshalBSON()
+	if err != nil {
+		t.Fatalf("MarshalBSON error: %v", err)
+	}
+	back, err := NewFromBSON(b)
+	if err != nil {
+		t.Fatalf("NewFromBSON error: %v", err)
+	}
+	if v, _ := back.GetString("n", "$numberDecimal"); v != "18446744073709551615" {
+		t.Errorf("unexpected value %s", marshalSorted(back))
+	}
+}
+
+func TestDecimal128(t *testing.T) {
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"0", "0"},
+		{"1", "1"},
+		{"-1", "-1"},
+		{"0.001234", "0.001234"},
+		{"123400000", "123400000"},
+		{"1E+3", "1E+3"},
+		{"1.23E-7", "1.23E-7"},
+		{"-0.0", "-0.0"},
+		{"Infinity", "Infinity"},
+		{"-Infinity", "-Infinity"},
+		{"NaN", "NaN"},
+		{"9999999999999999999999999999999999", "9999999999999999999999999999999999"},
+	}
+	for _, c := range cases {
+		hi, lo, err := decimal128FromString(c.in)
+		if err != nil {
+			t.Errorf("parse '%s' error: %v", c.in, err)
+			continue
+		}
+		if s := decimal128ToString(hi, lo); s != c.out {
+			t.Errorf("'%s' expected '%s', got '%s'", c.in, c.out, s)
+		}
+	}
+
+	if _, _, err := decimal128FromString("99999999999999999999999999999999999"); err == nil {
+		t.Errorf("35 digits coefficient should fail")
+	}
+}
+
+func TestBSONReader(t *testing.T) {
+	doc := []byte("\x16\x00\x00\x00\x02hello\x00\x06\x00\x00\x00world\x00\x00")
+	dump := append(append([]byte{}, doc...), doc...)
+
+	count := 0
+	err := NewBSONReader(bytes.NewReader(dump)).ForEach(func(v *JsonValue) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 2 {
+		t.Errorf("expected 2 documents, got %d, err %v", count, err)
+	}
+
+	r := NewBSONReader(bytes.NewReader(dump[:len(dump)-3]))
+	if _, err = r.Next(); err != nil {
+		t.Errorf("first document error: %v", err)
+	}
+	if _, err = r.Next(); err == nil || err == io.EOF {
+		t.Errorf("truncated document should fail, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package jsonconv
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Coercion is a set of flags allowing value conversion between types when
+// reading values with AsXxx functions, a Cursor, or GetXxx functions with the
+// flags given after the keys.
+type Coercion int
+
+const (
+	// CoerceNone accepts only exactly matching types, like GetXxx functions
+	CoerceNone Coercion = 0
+	// CoerceNumericString reads strings such as "42" or "-1.5" as numbers
+	CoerceNumericString Coercion = 1 << iota
+	// CoerceBoolString reads "true"/"false", "1"/"0", "yes"/"no" and
+	// "on"/"off" strings as booleans, case-insensitively
+	CoerceBoolString
+	// CoerceNumberToBool reads zero as false and other numbers as true
+	CoerceNumberToBool
+	// CoerceToString formats numbers and booleans when a string is required
+	CoerceToString
+
+	// CoerceAll enables every conversion above
+	CoerceAll = CoerceNumericString | CoerceBoolString | CoerceNumberToBool | CoerceToString
+)
+
+// AsString returns the string value, formatting numbers and booleans if
+// CoerceToString is set.
+func (obj *JsonValue) AsString(c Coercion) (string, error) {
+	switch obj.valueType {
+	case String:
+		return obj.stringValue, nil
+	case Number, Boolean:
+		if 0 != c&CoerceToString {
+			return obj.Marshal()
+		}
+	}
+	return "", NotAStringError
+}
+
+// AsInt64 returns the value as int64. Floats are truncated.
+func (obj *JsonValue) AsInt64(c Coercion) (int64, error) {
+	num, err := obj.asNumber(c)
+	if err != nil {
+		return 0, err
+	}
+	return num.Int64(), nil
+}
+
+// AsUint64 returns the value as uint64
+func (obj *JsonValue) AsUint64(c Coercion) (uint64, error) {
+	num, err := obj.asNumber(c)
+	if err != nil {
+		return 0, err
+	}
+	return num.Uint64(), nil
+}
+
+// AsFloat returns the value as float64
+func (obj *JsonValue) AsFloat(c Coercion) (float64, error) {
+	num, err := obj.asNumber(c)
+	if err != nil {
+		return 0, err
+	}
+	return num.Float(), nil
+}
+
+// AsBool returns the boolean value, converting strings and numbers according
+// to c.
+func (obj *JsonValue) AsBool(c Coercion) (bool, error) {
+	switch obj.valueType {
+	case Boolean:
+		return obj.boolValue, nil
+	case Number:
+		if 0 != c&CoerceNumberToBool {
+			return obj.floatValue != 0 || obj.intValue != 0 || obj.uintValue != 0, nil
+		}
+	case String:
+		if 0 != c&CoerceBoolString {
+			switch strings.ToLower(strings.TrimSpace(obj.stringValue)) {
+			case "true", "1", "yes", "on":
+				return true, nil
+			case "false", "0", "no", "off":
+				return false, nil
+			}
+		}
+	}
+	return false, NotABoolError
+}
+
+func (obj *JsonValue) asNumber(c Coercion) (*JsonValue, error) {
+	switch obj.valueType {
+	case Number:
+		return obj, nil
+	case String:
+		if 0 != c&CoerceNumericString {
+			if num, ok := numberFromString(strings.TrimSpace(obj.stringValue)); ok {
+				return num, nil
+			}
+		}
+	}
+	return nil, NotANumberError
+}
+
+// numberFromString parses a JSON number literal into a Number value
+func numberFromString(s string) (*JsonValue, bool) {
+	if false == isJSONNumber(s) {
+		return nil, false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return NewInt64(i), true
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return NewUint64(u), true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return NewFloat(f), true
+}
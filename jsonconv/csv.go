@@ -0,0 +1,246 @@
+package jsonconv
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// CSVArrayMode decides how arrays inside rows are written into CSV cells
+type CSVArrayMode int
+
+const (
+	// ArrayAsColumns spreads array elements into index columns like "tags.0", "tags.1"
+	ArrayAsColumns CSVArrayMode = iota
+	// ArrayAsJSONCell writes the whole array as a JSON string in one cell
+	ArrayAsJSONCell
+)
+
+// CSVOptions controls ToCSV and FromCSV
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' by default. Use '\t' for TSV.
+	Comma rune
+	// KeySeparator joins nested keys into column names, "." by default.
+	KeySeparator string
+	// ArrayMode decides how arrays are written.
+	ArrayMode CSVArrayMode
+	// Header lists the columns to write. If empty, ToCSV infers the header
+	// from the union of keys in all rows, in the order they first appear.
+	// For FromCSV, a non-empty Header names the columns of input which has
+	// no header line, so that every line is read as a data row.
+	Header []string
+	// RawStrings disables type inference in FromCSV, all cells are read as
+	// strings.
+	RawStrings bool
+}
+
+func (opts *CSVOptions) comma() rune {
+	if 0 == opts.Comma {
+		return ','
+	}
+	return opts.Comma
+}
+
+func (opts *CSVOptions) keySeparator() string {
+	if "" == opts.KeySeparator {
+		return "."
+	}
+	return opts.KeySeparator
+}
+
+// ToCSV writes an array of objects as CSV, one row per object. Nested objects
+// are flattened into column names joined by KeySeparator, such as
+// "user.address.city". Missing values and null are written as empty cells.
+func ToCSV(w io.Writer, arr *JsonValue, opts CSVOptions) error {
+	if nil == arr || nil == w {
+		return ParaError
+	}
+	if false == arr.IsArray() {
+		return NotAnArrayError
+	}
+	sep := opts.keySeparator()
+
+	rows := make([]map[string]string, 0, len(arr.arrChildren))
+	header := opts.Header
+	inferHeader := 0 == len(header)
+	known := make(map[string]bool)
+
+	for _, row := range arr.arrChildren {
+		if false == row.IsObject() {
+			return NotAnObjectError
+		}
+		cells := make(map[string]string)
+		flattenForCSV(row, sep, &opts, func(col, cell string) {
+			cells[col] = cell
+			if inferHeader && false == known[col] {
+				known[col] = true
+				header = append(header, col)
+			}
+		})
+		rows = append(rows, cells)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.comma()
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(header))
+	for _, cells := range rows {
+		for i, col := range header {
+			record[i] = cells[col]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func flattenForCSV(row *JsonValue, sep string, opts *CSVOptions, callback func(col, cell string)) {
+	walkLeaves(row, nil, 0, ArrayAsJSONCell == opts.ArrayMode, func(path []string, leaf *JsonValue) {
+		if 0 == len(path) {
+			return
+		}
+		col := strings.Join(path, sep)
+		switch leaf.valueType {
+		case String:
+			callback(col, leaf.stringValue)
+		case Null:
+			callback(col, "")
+		default:
+			s, _ := leaf.Marshal(Option{ShowNull: true})
+			callback(col, s)
+		}
+	})
+}
+
+// FromCSV reads CSV with a header line and returns an array of objects. If
+// Header is set, the input must not have a header line.
+// Column names are split by KeySeparator to rebuild nested objects, and
+// numeric segments rebuild arrays. An index not less than the number of
+// columns gives IndexOutOfBoundsError. Unless RawStrings is set, cells
+// holding JSON numbers, booleans, null, arrays or objects are converted to
+// the matching types. Empty cells are omitted.
+func FromCSV(r io.Reader, opts CSVOptions) (*JsonValue, error) {
+	if nil == r {
+		return nil, ParaError
+	}
+	sep := opts.keySeparator()
+
+	cr := csv.NewReader(r)
+	cr.Comma = opts.comma()
+	cr.FieldsPerRecord = -1
+
+	header := opts.Header
+	if 0 == len(header) {
+		var err error
+		header, err = cr.Read()
+		if err == io.EOF {
+			return NewArray(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	paths := make([][]string, len(header))
+	for i, col := range header {
+		paths[i] = strings.Split(col, sep)
+	}
+
+	ret := NewArray()
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := NewObject()
+		b := newPathBuilder(len(paths))
+		for i, cell := range record {
+			if i >= len(paths) || "" == cell {
+				continue
+			}
+			if err = b.set(row, paths[i], cellToValue(cell, opts.RawStrings)); err != nil {
+				return nil, err
+			}
+		}
+		ret.arrChildren = append(ret.arrChildren, row)
+	}
+}
+
+func cellToValue(cell string, raw bool) *JsonValue {
+	if raw {
+		return NewString(cell)
+	}
+	switch cell {
+	case "true":
+		return NewBool(true)
+	case "false":
+		return NewBool(false)
+	case "null":
+		return NewNull()
+	}
+	switch cell[0] {
+	case '{', '[':
+		if v, err := NewFromString(cell); err == nil {
+			return v
+		}
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if isJSONNumber(cell) {
+			if v, err := NewFromString(cell); err == nil {
+				return v
+			}
+		}
+	}
+	return NewString(cell)
+}
+
+// isJSONNumber checks s against the number grammar of RFC 8259, so that
+// values like "007" or "1e" stay strings.
+func isJSONNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if i >= len(s) {
+		return false
+	}
+	if s[i] == '0' {
+		i++
+	} else if s[i] >= '1' && s[i] <= '9' {
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+	} else {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	return i == len(s)
+}
@@ -0,0 +1,93 @@
+package jsonconv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	s := `[
+		{"id": 1, "name": "Alice", "user": {"address": {"city": "Shenzhen"}}, "tags": ["a", "b"], "vip": true},
+		{"id": 2, "name": "Bob, Jr.", "zip": "007", "tags": ["c"], "score": 3.5}
+	]`
+	arr, err := NewFromString(s)
+	if err != nil {
+		t.Errorf("parse error: %v", err)
+		return
+	}
+
+	buff := bytes.Buffer{}
+	if err = ToCSV(&buff, arr, CSVOptions{}); err != nil {
+		t.Errorf("ToCSV error: %v", err)
+		return
+	}
+	t.Logf("CSV:\n%s", buff.String())
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	if lines[0] != "id,name,tags.0,tags.1,user.address.city,vip,score,zip" {
+		t.Errorf("unexpected header: %s", lines[0])
+	}
+	if lines[2] != `2,"Bob, Jr.",c,,,,3.5,007` {
+		t.Errorf("unexpected row: %s", lines[2])
+	}
+
+	back, err := FromCSV(&buff, CSVOptions{})
+	if err != nil {
+		t.Errorf("FromCSV error: %v", err)
+		return
+	}
+	opt := Option{SortMode: DictAsc}
+	expected, _ := arr.Marshal(opt)
+	got, _ := back.Marshal(opt)
+	if got != expected {
+		t.Errorf("round trip mismatch:\nexpected %s\ngot      %s", expected, got)
+	}
+}
+
+func TestTSVWithJSONCell(t *testing.T) {
+	arr, _ := NewFromString(`[{"k": "v", "list": [1, {"x": null}], "empty": {}}]`)
+	opts := CSVOptions{Comma: '\t', ArrayMode: ArrayAsJSONCell}
+
+	buff := bytes.Buffer{}
+	if err := ToCSV(&buff, arr, opts); err != nil {
+		t.Errorf("ToCSV error: %v", err)
+		return
+	}
+	back, err := FromCSV(&buff, opts)
+	if err != nil {
+		t.Errorf("FromCSV error: %v", err)
+		return
+	}
+	got, _ := back.Marshal(Option{SortMode: DictAsc, ShowNull: true})
+	if got != `[{"empty":{},"k":"v","list":[1,{"x":null}]}]` {
+		t.Errorf("unexpected result: %s", got)
+	}
+
+	raw, _ := FromCSV(strings.NewReader("a\n1\n"), CSVOptions{RawStrings: true})
+	if s, _ := raw.GetString(0, "a"); s != "1" {
+		t.Errorf("raw string expected, got %v", s)
+	}
+}
+
+func TestFromCSVConflict(t *testing.T) {
+	_, err := FromCSV(strings.NewReader("a,a.b\n1,2\n"), CSVOptions{})
+	if err != KeyConflictError {
+		t.Errorf("expected KeyConflictError, got %v", err)
+	}
+
+	_, err = FromCSV(strings.NewReader("id,tags.999999999\n1,x\n"), CSVOptions{})
+	if err != IndexOutOfBoundsError {
+		t.Errorf("expected IndexOutOfBoundsError, got %v", err)
+	}
+}
+
+func TestFromCSVWithHeader(t *testing.T) {
+	arr, err := FromCSV(strings.NewReader("1,x\n2,y\n"), CSVOptions{Header: []string{"id", "name"}})
+	if err != nil {
+		t.Fatalf("FromCSV error: %v", err)
+	}
+	if arr.Length() != 2 || arr.At(0, "id").IntOr(0) != 1 || arr.At(1, "name").StrOr("") != "y" {
+		t.Errorf("unexpected result: %s", marshalSorted(arr))
+	}
+}
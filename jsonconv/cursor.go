@@ -0,0 +1,243 @@
+package jsonconv
+
+// Cursor is a nil-safe position in a JsonValue tree. Navigation never fails
+// immediately: the first error is kept and reported by Err, the XxxOr
+// functions return their defaults, and MustXxx functions panic with a
+// *PathError.
+//
+//	port := obj.At("db").At("master", "port").IntOr(3306)
+type Cursor struct {
+	value  *JsonValue
+	path   Path
+	err    error
+	coerce Coercion
+}
+
+// At starts navigating from obj. It is safe to call with a nil obj.
+func (obj *JsonValue) At(first interface{}, keys ...interface{}) *Cursor {
+	c := &Cursor{value: obj, path: Path{}}
+	if nil == obj {
+		c.err = &PathError{Path: c.path, Index: -1, Expected: Unknown, Actual: NotExist, Err: ObjectNotFoundError}
+	}
+	return c.At(first, keys...)
+}
+
+// At moves further down by keys and array indexes
+func (c *Cursor) At(first interface{}, keys ...interface{}) *Cursor {
+	steps := append(Path{first}, keys...)
+	path := make(Path, 0, len(c.path)+len(steps))
+	path = append(append(path, c.path...), steps...)
+	ret := &Cursor{value: c.value, path: path, err: c.err, coerce: c.coerce}
+	if ret.err != nil {
+		ret.value = nil
+		return ret
+	}
+
+	v, err := c.value.getByPath(steps)
+	if err != nil {
+		if pe, ok := err.(*PathError); ok {
+			pe.Path = path
+			pe.Index += len(c.path)
+		}
+		ret.value = nil
+		ret.err = err
+		return ret
+	}
+	ret.value = v
+	return ret
+}
+
+// Coerce returns a cursor at the same position which converts values with the
+// given coercion flags.
+func (c *Cursor) Coerce(flags Coercion) *Cursor {
+	ret := *c
+	ret.coerce = flags
+	return &ret
+}
+
+// Path returns the path navigated so far
+func (c *Cursor) Path() Path {
+	return c.path
+}
+
+// Err returns the first error met during navigation
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Exists tells whether the whole path could be resolved
+func (c *Cursor) Exists() bool {
+	return nil == c.err
+}
+
+// Value returns the value at the cursor
+func (c *Cursor) Value() (*JsonValue, error) {
+	return c.value, c.err
+}
+
+// typeError wraps a conversion error of the value at the cursor
+func (c *Cursor) typeError(expected ValueType, err error) error {
+	last := len(c.path) - 1
+	var seg interface{}
+	if last >= 0 {
+		seg = c.path[last]
+	}
+	return &PathError{Path: c.path, Index: last, Segment: seg, Expected: expected, Actual: c.value.valueType, Err: err}
+}
+
+// Str returns the string at the cursor
+func (c *Cursor) Str() (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	s, err := c.value.AsString(c.coerce)
+	if err != nil {
+		return "", c.typeError(String, err)
+	}
+	return s, nil
+}
+
+// Int64 returns the integer at the cursor
+func (c *Cursor) Int64() (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	i, err := c.value.AsInt64(c.coerce)
+	if err != nil {
+		return 0, c.typeError(Number, err)
+	}
+	return i, nil
+}
+
+// Uint64 returns the unsigned integer at the cursor
+func (c *Cursor) Uint64() (uint64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	u, err := c.value.AsUint64(c.coerce)
+	if err != nil {
+		return 0, c.typeError(Number, err)
+	}
+	return u, nil
+}
+
+// Float returns the number at the cursor
+func (c *Cursor) Float() (float64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	f, err := c.value.AsFloat(c.coerce)
+	if err != nil {
+		return 0, c.typeError(Number, err)
+	}
+	return f, nil
+}
+
+// Bool returns the boolean at the cursor
+func (c *Cursor) Bool() (bool, error) {
+	if c.err != nil {
+		return false, c.err
+	}
+	b, err := c.value.AsBool(c.coerce)
+	if err != nil {
+		return false, c.typeError(Boolean, err)
+	}
+	return b, nil
+}
+
+// ==== XxxOr ====
+
+func (c *Cursor) StrOr(dft string) string {
+	if s, err := c.Str(); err == nil {
+		return s
+	}
+	return dft
+}
+
+func (c *Cursor) Int64Or(dft int64) int64 {
+	if i, err := c.Int64(); err == nil {
+		return i
+	}
+	return dft
+}
+
+func (c *Cursor) IntOr(dft int) int {
+	if i, err := c.Int64(); err == nil {
+		return int(i)
+	}
+	return dft
+}
+
+func (c *Cursor) Uint64Or(dft uint64) uint64 {
+	if u, err := c.Uint64(); err == nil {
+		return u
+	}
+	return dft
+}
+
+func (c *Cursor) FloatOr(dft float64) float64 {
+	if f, err := c.Float(); err == nil {
+		return f
+	}
+	return dft
+}
+
+func (c *Cursor) BoolOr(dft bool) bool {
+	if b, err := c.Bool(); err == nil {
+		return b
+	}
+	return dft
+}
+
+// ==== MustXxx ====
+
+func (c *Cursor) MustValue() *JsonValue {
+	if c.err != nil {
+		panic(c.err)
+	}
+	return c.value
+}
+
+func (c *Cursor) MustStr() string {
+	s, err := c.Str()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (c *Cursor) MustInt64() int64 {
+	i, err := c.Int64()
+	if err != nil {
+		panic(err)
+	}
+	return i
+}
+
+func (c *Cursor) MustInt() int {
+	return int(c.MustInt64())
+}
+
+func (c *Cursor) MustUint64() uint64 {
+	u, err := c.Uint64()
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func (c *Cursor) MustFloat() float64 {
+	f, err := c.Float()
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (c *Cursor) MustBool() bool {
+	b, err := c.Bool()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
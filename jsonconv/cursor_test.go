@@ -0,0 +1,71 @@
+package jsonconv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	obj, _ := NewFromString(`{"db": {"hosts": [{"port": "3307", "ssl": "yes"}], "timeout": 1.5}}`)
+
+	if port := obj.At("db", "hosts").At(0, "port").IntOr(3306); port != 3306 {
+		t.Errorf("string port should not be read without coercion, got %d", port)
+	}
+	if port := obj.At("db", "hosts").At(0, "port").Coerce(CoerceAll).IntOr(3306); port != 3307 {
+		t.Errorf("expected coerced port 3307, got %d", port)
+	}
+	if ssl := obj.At("db").Coerce(CoerceBoolString).At("hosts", 0, "ssl").BoolOr(false); false == ssl {
+		t.Errorf("expected coerced ssl true")
+	}
+	if s := obj.At("db", "timeout").Coerce(CoerceToString).StrOr(""); s != "1.5" {
+		t.Errorf("expected timeout string 1.5, got '%s'", s)
+	}
+	if obj.At("db", "missing", 0).Exists() {
+		t.Errorf("missing path should not exist")
+	}
+	if s := obj.At("db", "missing", 0).StrOr("dft"); s != "dft" {
+		t.Errorf("expected default value, got '%s'", s)
+	}
+
+	if s, err := obj.At("db", "hosts").At(0, "port").Str(); err != nil || s != "3307" {
+		t.Errorf("unexpected Str result: '%s', %v", s, err)
+	}
+
+	var nilObj *JsonValue
+	if nilObj.At("a").At(0).StrOr("nil-safe") != "nil-safe" {
+		t.Errorf("nil JsonValue should be safe")
+	}
+
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if false == ok || false == errors.Is(err, ObjectNotFoundError) {
+			t.Errorf("expected panic with ObjectNotFoundError, got %v", r)
+			return
+		}
+		if s := err.Error(); s != "object not found at db.hosts[0].user" {
+			t.Errorf("unexpected panic message: %s", s)
+		}
+	}()
+	obj.At("db").At("hosts", 0).At("user", "name").MustStr()
+}
+
+func TestGetWithCoercion(t *testing.T) {
+	obj, _ := NewFromString(`{"port": "3307", "ssl": "on", "ratio": 0.5}`)
+
+	if _, err := obj.GetInt("port"); false == errors.Is(err, NotANumberError) {
+		t.Errorf("expected NotANumberError without coercion, got %v", err)
+	}
+	if port, err := obj.GetInt("port", CoerceNumericString); err != nil || port != 3307 {
+		t.Errorf("unexpected coerced port: %d, %v", port, err)
+	}
+	if ssl, err := obj.GetBool("ssl", CoerceBoolString); err != nil || false == ssl {
+		t.Errorf("unexpected coerced ssl: %v, %v", ssl, err)
+	}
+	if s, err := obj.GetString("ratio", CoerceAll); err != nil || s != "0.5" {
+		t.Errorf("unexpected coerced ratio: '%s', %v", s, err)
+	}
+	if _, err := obj.GetFloat("ssl", CoerceNumericString); false == errors.Is(err, NotANumberError) {
+		t.Errorf("expected NotANumberError, got %v", err)
+	}
+}
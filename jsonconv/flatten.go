@@ -0,0 +1,245 @@
+package jsonconv
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FlattenOptions controls Flatten and Unflatten
+type FlattenOptions struct {
+	// EscapeSeparator escapes separators and backslashes inside keys with a
+	// backslash, so that a key like "a.b" does not split into two levels.
+	EscapeSeparator bool
+	// MaxDepth stops flattening at the given depth, deeper values are kept as
+	// objects or arrays. Zero means no limit.
+	MaxDepth int
+}
+
+// Flatten converts a tree into a one level map, with keys like "a.b.0.c" when
+// sep is ".". Empty objects and arrays are kept as leaves. Values in the
+// returned map are shared with the original tree. A non-container value is
+// returned with an empty key.
+func (obj *JsonValue) Flatten(sep string, opts ...FlattenOptions) map[string]*JsonValue {
+	opt := FlattenOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	ret := make(map[string]*JsonValue)
+	walkLeaves(obj, nil, opt.MaxDepth, false, func(path []string, leaf *JsonValue) {
+		if opt.EscapeSeparator {
+			escaped := make([]string, len(path))
+			for i, seg := range path {
+				escaped[i] = escapeFlattenKey(seg, sep)
+			}
+			path = escaped
+		}
+		ret[strings.Join(path, sep)] = leaf
+	})
+	return ret
+}
+
+// Unflatten rebuilds a tree from the result of Flatten. Numeric keys become
+// array indexes, and array gaps are filled with null. An index not less than
+// the number of keys gives IndexOutOfBoundsError, and a key going into a value
+// given by another key, e.g. "a.b" with "a", gives KeyConflictError.
+func Unflatten(m map[string]*JsonValue, sep string, opts ...FlattenOptions) (*JsonValue, error) {
+	if "" == sep {
+		return nil, ParaError
+	}
+	opt := FlattenOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if v, exist := m[""]; exist && 1 == len(m) {
+		return v, nil
+	}
+
+	keys := make([]string, 0, len(m))
+	paths := make(map[string][]string, len(m))
+	rootIsArray := len(m) > 0
+	for k := range m {
+		var path []string
+		if opt.EscapeSeparator {
+			path = splitEscapedFlattenKey(k, sep)
+		} else {
+			path = strings.Split(k, sep)
+		}
+		if _, isIndex := isArrayIndex(path[0]); false == isIndex {
+			rootIsArray = false
+		}
+		keys = append(keys, k)
+		paths[k] = path
+	}
+	// sorted keys ensure that conflicts are reported regardless of map order
+	sort.Strings(keys)
+
+	var root *JsonValue
+	if rootIsArray {
+		root = NewArray()
+	} else {
+		root = NewObject()
+	}
+	b := newPathBuilder(len(m))
+	for _, k := range keys {
+		v := m[k]
+		if nil == v {
+			v = NewNull()
+		}
+		if err := b.set(root, paths[k], v); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// walkLeaves calls callback with the path of each leaf value, visiting object
+// keys in ascending order. The path slice is reused between calls.
+func walkLeaves(v *JsonValue, path []string, maxDepth int, arrayAsLeaf bool, callback func(path []string, leaf *JsonValue)) {
+	if maxDepth > 0 && len(path) >= maxDepth {
+		callback(path, v)
+		return
+	}
+	switch v.valueType {
+	case Object:
+		if 0 == len(v.objChildren) {
+			callback(path, v)
+			return
+		}
+		for _, k := range sortedKeys(v) {
+			walkLeaves(v.objChildren[k], append(path, k), maxDepth, arrayAsLeaf, callback)
+		}
+	case Array:
+		if arrayAsLeaf || 0 == len(v.arrChildren) {
+			callback(path, v)
+			return
+		}
+		for i, child := range v.arrChildren {
+			walkLeaves(child, append(path, strconv.Itoa(i)), maxDepth, arrayAsLeaf, callback)
+		}
+	default:
+		callback(path, v)
+	}
+}
+
+func escapeFlattenKey(key, sep string) string {
+	if false == strings.Contains(key, "\\") && false == strings.Contains(key, sep) {
+		return key
+	}
+	key = strings.Replace(key, "\\", "\\\\", -1)
+	return strings.Replace(key, sep, "\\"+sep, -1)
+}
+
+func splitEscapedFlattenKey(key, sep string) []string {
+	ret := make([]string, 0, 4)
+	b := strings.Builder{}
+	for i := 0; i < len(key); {
+		switch {
+		case key[i] == '\\' && i+1 < len(key):
+			if strings.HasPrefix(key[i+1:], sep) {
+				b.WriteString(sep)
+				i += 1 + len(sep)
+			} else {
+				b.WriteByte(key[i+1])
+				i += 2
+			}
+		case strings.HasPrefix(key[i:], sep):
+			ret = append(ret, b.String())
+			b.Reset()
+			i += len(sep)
+		default:
+			b.WriteByte(key[i])
+			i++
+		}
+	}
+	return append(ret, b.String())
+}
+
+func isArrayIndex(s string) (int, bool) {
+	if "" == s || len(s) > 9 {
+		return 0, false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	i, _ := strconv.Atoi(s)
+	return i, true
+}
+
+// pathBuilder sets values by string paths, see set
+type pathBuilder struct {
+	// limit bounds array indexes, so that a single key can not make arrays
+	// of any length
+	limit int
+	// leaves are values given to set, which later paths must not go into
+	leaves map[*JsonValue]bool
+}
+
+func newPathBuilder(limit int) *pathBuilder {
+	return &pathBuilder{limit: limit, leaves: make(map[*JsonValue]bool)}
+}
+
+// set sets v into root, creating missing objects, and arrays for numeric path
+// segments. Missing array elements are filled with null.
+func (b *pathBuilder) set(root *JsonValue, path []string, v *JsonValue) error {
+	curr := root
+	for i, seg := range path {
+		last := i == len(path)-1
+		var next *JsonValue
+		if false == last {
+			if _, isIndex := isArrayIndex(path[i+1]); isIndex {
+				next = NewArray()
+			} else {
+				next = NewObject()
+			}
+		} else {
+			next = v
+		}
+
+		switch curr.valueType {
+		case Object:
+			child, exist := curr.objChildren[seg]
+			if false == exist || last {
+				if exist && last {
+					return KeyConflictError
+				}
+				curr.objChildren[seg] = next
+				child = next
+			} else if child.valueType != next.valueType || b.leaves[child] {
+				return KeyConflictError
+			}
+			curr = child
+		case Array:
+			index, isIndex := isArrayIndex(seg)
+			if false == isIndex {
+				return KeyConflictError
+			}
+			if index >= b.limit {
+				return IndexOutOfBoundsError
+			}
+			for len(curr.arrChildren) <= index {
+				curr.arrChildren = append(curr.arrChildren, NewNull())
+			}
+			child := curr.arrChildren[index]
+			if last || child.IsNull() {
+				if last && false == child.IsNull() {
+					return KeyConflictError
+				}
+				curr.arrChildren[index] = next
+				child = next
+			} else if child.valueType != next.valueType || b.leaves[child] {
+				return KeyConflictError
+			}
+			curr = child
+		default:
+			return KeyConflictError
+		}
+	}
+	b.leaves[v] = true
+	return nil
+}
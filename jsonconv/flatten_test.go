@@ -0,0 +1,86 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func TestFlattenUnflatten(t *testing.T) {
+	obj, _ := NewFromString(`{"a": {"b": [1, {"c": "x"}], "e": {}}, "k.v": "dot", "n": null}`)
+	opt := FlattenOptions{EscapeSeparator: true}
+
+	flat := obj.Flatten(".", opt)
+	for k, expected := range map[string]string{
+		"a.b.0":   "1",
+		"a.b.1.c": `"x"`,
+		"a.e":     "{}",
+		`k\.v`:    `"dot"`,
+		"n":       "null",
+	} {
+		v, exist := flat[k]
+		if false == exist {
+			t.Errorf("key %s not found", k)
+			continue
+		}
+		if s, _ := v.Marshal(); s != expected {
+			t.Errorf("key %s expected %s, got %s", k, expected, s)
+		}
+	}
+	if len(flat) != 5 {
+		t.Errorf("unexpected flatten result size %d", len(flat))
+	}
+
+	back, err := Unflatten(flat, ".", opt)
+	if err != nil {
+		t.Errorf("Unflatten error: %v", err)
+		return
+	}
+	marshalOpt := Option{SortMode: DictAsc, ShowNull: true}
+	expected, _ := obj.Marshal(marshalOpt)
+	got, _ := back.Marshal(marshalOpt)
+	if got != expected {
+		t.Errorf("round trip mismatch:\nexpected %s\ngot      %s", expected, got)
+	}
+}
+
+func TestFlattenMaxDepth(t *testing.T) {
+	obj, _ := NewFromString(`[{"a": {"b": 1}}, 2]`)
+	flat := obj.Flatten("/", FlattenOptions{MaxDepth: 2})
+	if v := flat["0/a"]; nil == v || false == v.IsObject() {
+		t.Errorf("expected object at 0/a, got %v", flat)
+	}
+
+	back, err := Unflatten(flat, "/")
+	if err != nil || false == back.IsArray() || back.Length() != 2 {
+		t.Errorf("expected array of 2, got %v, err %v", back, err)
+	}
+
+	if _, err = Unflatten(map[string]*JsonValue{"a": NewInt(1), "a.b": NewInt(2)}, "."); err != KeyConflictError {
+		t.Errorf("expected KeyConflictError, got %v", err)
+	}
+}
+
+func TestUnflattenUntrusted(t *testing.T) {
+	// a single key must not make a huge array
+	m := map[string]*JsonValue{"a.999999999": NewInt(1)}
+	if _, err := Unflatten(m, "."); err != IndexOutOfBoundsError {
+		t.Errorf("expected IndexOutOfBoundsError, got %v", err)
+	}
+	m = map[string]*JsonValue{"999999999": NewInt(1)}
+	if _, err := Unflatten(m, "."); err != IndexOutOfBoundsError {
+		t.Errorf("expected IndexOutOfBoundsError for root array, got %v", err)
+	}
+	m = map[string]*JsonValue{"a.1": NewInt(1), "a.0": NewInt(0)}
+	if v, err := Unflatten(m, "."); err != nil || v.At("a", 1).IntOr(0) != 1 {
+		t.Errorf("unexpected result %v, err %v", v, err)
+	}
+
+	// values of the map are not modified
+	inner := NewObject()
+	m = map[string]*JsonValue{"a": inner, "a.b": NewInt(1)}
+	if _, err := Unflatten(m, "."); err != KeyConflictError {
+		t.Errorf("expected KeyConflictError, got %v", err)
+	}
+	if inner.Length() != 0 {
+		t.Errorf("value in the map modified: %v", inner)
+	}
+}
@@ -0,0 +1,250 @@
+package jsonconv
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	valuerType    = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonValueType = reflect.TypeOf(JsonValue{})
+)
+
+// NewFromInterface converts a Go value into a JsonValue. Structs become
+// objects keyed the same way as SqlToJson does, i.e. by "json" tag, "db" tag
+// or field name, with Option.FilterMode and FilterList applied on top level
+// fields. Embedded structs without tags are flattened into their parent.
+// Fields tagged with "redact" are redacted as in Marshal.
+//
+// time.Time values are formatted by Option.TimeFormat and TimeLocation.
+// Types implementing driver.Valuer, e.g. sql.NullString and mysql.NullTime,
+// are converted by their value, and null if invalid. Other types implementing
+// json.Marshaler are converted by their JSON form. []byte becomes a base64
+// string. Maps must have string keys. Channels, functions and complex numbers
+// give DataTypeError, and a pointer, map or slice which contains itself gives
+// CycleError.
+func NewFromInterface(v interface{}, opts ...Option) (*JsonValue, error) {
+	opt := dftOption
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	c := &interfaceConverter{opt: &opt, visiting: make(map[visitKey]bool)}
+	if IncludeMode == opt.FilterMode || ExcludeMode == opt.FilterMode {
+		c.filter = make(map[string]int, len(opt.FilterList))
+		for _, key := range opt.FilterList {
+			c.filter[key] = 1
+		}
+	}
+	return c.convert(reflect.ValueOf(v), 0)
+}
+
+type interfaceConverter struct {
+	opt      *Option
+	filter   map[string]int
+	visiting map[visitKey]bool
+}
+
+// visitKey identifies a pointer, map or slice being converted. Type and
+// length are included because a struct and its first field, or a slice and
+// its sub-slices, share the same address.
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// enter marks v as being converted, and fails if it already is, i.e. v
+// contains itself. The returned function must be called when done.
+func (c *interfaceConverter) enter(v reflect.Value) (leave func(), err error) {
+	key := visitKey{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if c.visiting[key] {
+		return nil, CycleError
+	}
+	c.visiting[key] = true
+	return func() { delete(c.visiting, key) }, nil
+}
+
+func (c *interfaceConverter) convert(v reflect.Value, depth int) (*JsonValue, error) {
+	if false == v.IsValid() {
+		return NewNull(), nil
+	}
+
+	// special types
+	t := v.Type()
+	switch {
+	case t == timeType:
+		return timeValueWithOption(v.Interface().(time.Time), c.opt), nil
+	case t == jsonValueType:
+		j := v.Interface().(JsonValue)
+		return j.Clone(), nil
+	case t.Kind() == reflect.Ptr && t.Elem() == jsonValueType:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		return v.Interface().(*JsonValue).Clone(), nil
+	case t.Implements(valuerType):
+		if t.Kind() == reflect.Ptr && v.IsNil() {
+			return NewNull(), nil
+		}
+		value, err := v.Interface().(driver.Valuer).Value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := value.(driver.Valuer); ok {
+			// avoid endless recursion on odd implementations
+			return nil, DataTypeError
+		}
+		return c.convert(reflect.ValueOf(value), depth)
+	case t.Implements(marshalerType):
+		if t.Kind() == reflect.Ptr && v.IsNil() {
+			return NewNull(), nil
+		}
+		b, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return NewFromString(string(b))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NewBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInt64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewUint64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return NewFloat(v.Float()), nil
+	case reflect.String:
+		return NewString(v.String()), nil
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		if v.Kind() == reflect.Ptr {
+			leave, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		return c.convert(v.Elem(), depth)
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NewNull(), nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return NewString(base64.StdEncoding.EncodeToString(b)), nil
+		}
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			leave, err := c.enter(v)
+			if err != nil {
+				return nil, err
+			}
+			defer leave()
+		}
+		arr := NewArray()
+		for i := 0; i < v.Len(); i++ {
+			child, err := c.convert(v.Index(i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			arr.arrChildren = append(arr.arrChildren, child)
+		}
+		return arr, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NewNull(), nil
+		}
+		if t.Key().Kind() != reflect.String {
+			return nil, DataTypeError
+		}
+		leave, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+		obj := NewObject()
+		iter := v.MapRange()
+		for iter.Next() {
+			child, err := c.convert(iter.Value(), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			obj.objChildren[iter.Key().String()] = child
+		}
+		return obj, nil
+
+	case reflect.Struct:
+		obj := NewObject()
+		if err := c.convertStruct(obj, v, depth); err != nil {
+			return nil, err
+		}
+		return obj, nil
+
+	default:
+		return nil, DataTypeError
+	}
+}
+
+func (c *interfaceConverter) convertStruct(obj *JsonValue, v reflect.Value, depth int) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && "" == field.Tag.Get("json") && "" == field.Tag.Get("db") {
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if err := c.convertStruct(obj, fv, depth); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if "" != field.PkgPath {
+			// unexported
+			continue
+		}
+
+		key := fieldTagName(&field)
+		if "" == key {
+			continue
+		}
+		if 0 == depth && nil != c.filter {
+			_, exist := c.filter[key]
+			if (IncludeMode == c.opt.FilterMode) != exist {
+				continue
+			}
+		}
+
+		child, err := c.convert(v.Field(i), depth+1)
+		if err != nil {
+			return err
+		}
+		if rule, exist := redactRuleFromTag(&field); exist {
+			if child = rule.redact(child); nil == child {
+				continue
+			}
+		}
+		obj.objChildren[key] = child
+	}
+	return nil
+}
@@ -0,0 +1,785 @@
+package jq
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Andrew-M-C/go-tools/jsonconv"
+)
+
+// builtin receives its arguments unevaluated, as filters
+type builtin func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error
+
+func builtinKey(name string, arity int) string {
+	return name + "/" + strconv.Itoa(arity)
+}
+
+var builtins = map[string]builtin{
+	"empty/0": func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		return nil
+	},
+	"error/0": func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		return &RuntimeError{Msg: in.String(), Value: in}
+	},
+	"error/1": valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return nil, &RuntimeError{Msg: a[0].String(), Value: a[0]}
+	}),
+	"not/0": valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return jsonconv.NewBool(false == isTruthy(in)), nil
+	}),
+	"type/0": valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return jsonconv.NewString(typeName(in)), nil
+	}),
+	"length/0":        valueFunc(length),
+	"keys/0":          valueFunc(keys),
+	"keys_unsorted/0": valueFunc(keys),
+	"has/1":           valueFunc(has),
+	"add/0":           valueFunc(addAll),
+	"tostring/0":      valueFunc(tostring),
+	"tonumber/0":      valueFunc(tonumber),
+	"tojson/0": valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return jsonconv.NewString(toJSON(in)), nil
+	}),
+	"fromjson/0": valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if false == in.IsString() {
+			return nil, runtimeError(describe(in) + " cannot be parsed as JSON")
+		}
+		v, err := jsonconv.NewFromString(in.String())
+		if err != nil {
+			return nil, runtimeError(in.String() + " cannot be parsed as JSON")
+		}
+		return v, nil
+	}),
+	"ascii_downcase/0": stringFunc(asciiCase('A', 'Z', 'a'-'A')),
+	"ascii_upcase/0":   stringFunc(asciiCase('a', 'z', 'A'-'a')),
+	"ltrimstr/1": valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if in.IsString() && a[0].IsString() {
+			return jsonconv.NewString(strings.TrimPrefix(in.String(), a[0].String())), nil
+		}
+		return in, nil
+	}),
+	"rtrimstr/1": valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if in.IsString() && a[0].IsString() {
+			return jsonconv.NewString(strings.TrimSuffix(in.String(), a[0].String())), nil
+		}
+		return in, nil
+	}),
+	"startswith/1": stringPredicate("startswith", strings.HasPrefix),
+	"endswith/1":   stringPredicate("endswith", strings.HasSuffix),
+	"split/1": valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if false == in.IsString() || false == a[0].IsString() {
+			return nil, runtimeError("split input and separator must be strings")
+		}
+		return splitString(in.String(), a[0].String()), nil
+	}),
+	"join/1": valueFunc(join),
+	"test/1": valueFunc(test),
+	"contains/1": valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if in.Type() != a[0].Type() {
+			return nil, runtimeError(describe(in) + " and " + describe(a[0]) + " cannot have their containment checked")
+		}
+		return jsonconv.NewBool(contains(in, a[0])), nil
+	}),
+	"floor/0": mathFunc(math.Floor),
+	"ceil/0":  mathFunc(math.Ceil),
+	"round/0": mathFunc(math.Round),
+	"sqrt/0":  mathFunc(math.Sqrt),
+	"fabs/0":  mathFunc(math.Abs),
+
+	"sort/0": arrayFunc(func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return newArray(sortValues(elems)), nil
+	}),
+	"unique/0": arrayFunc(func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return newArray(dedupe(sortValues(elems))), nil
+	}),
+	"reverse/0": valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		switch in.Type() {
+		case jsonconv.Null:
+			return jsonconv.NewArray(), nil
+		case jsonconv.String:
+			runes := []rune(in.String())
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return jsonconv.NewString(string(runes)), nil
+		case jsonconv.Array:
+			return in.Reverse()
+		}
+		return nil, runtimeError("Cannot reverse " + describe(in))
+	}),
+	"min/0": arrayFunc(func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return extreme(elems, elems, -1), nil
+	}),
+	"max/0": arrayFunc(func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return extreme(elems, elems, 1), nil
+	}),
+	"flatten/0": arrayFunc(func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return newArray(flatten(elems, -1)), nil
+	}),
+	"flatten/1": valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if false == in.IsArray() {
+			return nil, runtimeError("Cannot flatten " + describe(in))
+		}
+		if false == a[0].IsNumber() || a[0].Float() < 0 {
+			return nil, runtimeError("flatten depth must not be negative")
+		}
+		return newArray(flatten(elements(in), int(a[0].Float()))), nil
+	}),
+	"to_entries/0":   valueFunc(toEntries),
+	"from_entries/0": valueFunc(fromEntries),
+	"first/0": valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return indexValue(in, jsonconv.NewInt(0))
+	}),
+	"last/0": valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return indexValue(in, jsonconv.NewInt(-1))
+	}),
+	"nth/1": valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		return indexValue(in, a[0])
+	}),
+	"any/0": arrayFunc(func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		for _, v := range elems {
+			if isTruthy(v) {
+				return jsonconv.NewBool(true), nil
+			}
+		}
+		return jsonconv.NewBool(false), nil
+	}),
+	"all/0": arrayFunc(func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		for _, v := range elems {
+			if false == isTruthy(v) {
+				return jsonconv.NewBool(false), nil
+			}
+		}
+		return jsonconv.NewBool(true), nil
+	}),
+
+	"arrays/0":    typeFilter(func(v *jsonconv.JsonValue) bool { return v.IsArray() }),
+	"objects/0":   typeFilter(func(v *jsonconv.JsonValue) bool { return v.IsObject() }),
+	"iterables/0": typeFilter(func(v *jsonconv.JsonValue) bool { return v.IsArray() || v.IsObject() }),
+	"scalars/0":   typeFilter(func(v *jsonconv.JsonValue) bool { return false == v.IsArray() && false == v.IsObject() }),
+	"booleans/0":  typeFilter(func(v *jsonconv.JsonValue) bool { return v.IsBool() }),
+	"numbers/0":   typeFilter(func(v *jsonconv.JsonValue) bool { return v.IsNumber() }),
+	"strings/0":   typeFilter(func(v *jsonconv.JsonValue) bool { return v.IsString() }),
+	"nulls/0":     typeFilter(func(v *jsonconv.JsonValue) bool { return v.IsNull() }),
+	"values/0":    typeFilter(func(v *jsonconv.JsonValue) bool { return false == v.IsNull() }),
+
+	"select/1":       selectFunc,
+	"map/1":          mapFunc,
+	"map_values/1":   mapValuesFunc,
+	"with_entries/1": withEntriesFunc,
+	"recurse/0": func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		return recurse(in, out)
+	},
+	"recurse/1":  recurseFunc,
+	"range/1":    rangeFunc,
+	"range/2":    rangeFunc,
+	"limit/2":    limitFunc,
+	"first/1":    firstFunc,
+	"last/1":     lastFunc,
+	"any/1":      anyAllFunc(true),
+	"all/1":      anyAllFunc(false),
+	"sort_by/1":  sortByFunc,
+	"group_by/1": groupByFunc,
+	"unique_by/1": func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		return byFunc(in, args[0], e, out, func(elems, keys []*jsonconv.JsonValue) *jsonconv.JsonValue {
+			res := jsonconv.NewArray()
+			for _, group := range groupSorted(elems, keys) {
+				res.Append(group[0])
+			}
+			return res
+		})
+	},
+	"min_by/1": func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		return byFunc(in, args[0], e, out, func(elems, keys []*jsonconv.JsonValue) *jsonconv.JsonValue {
+			return extreme(elems, keys, -1)
+		})
+	},
+	"max_by/1": func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		return byFunc(in, args[0], e, out, func(elems, keys []*jsonconv.JsonValue) *jsonconv.JsonValue {
+			return extreme(elems, keys, 1)
+		})
+	},
+}
+
+// ====================
+// builtin adaptors
+
+// valueFunc adapts a function of values. Arguments are evaluated against the
+// input, and fn is called with each combination of their outputs.
+func valueFunc(fn func(in *jsonconv.JsonValue, args []*jsonconv.JsonValue) (*jsonconv.JsonValue, error)) builtin {
+	return func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		return product(args, in, e, func(values []*jsonconv.JsonValue) error {
+			v, err := fn(in, values)
+			if err != nil {
+				return err
+			}
+			return out(v)
+		})
+	}
+}
+
+func stringFunc(fn func(string) string) builtin {
+	return valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if false == in.IsString() {
+			return nil, runtimeError(describe(in) + " is not a string")
+		}
+		return jsonconv.NewString(fn(in.String())), nil
+	})
+}
+
+func stringPredicate(name string, fn func(s, x string) bool) builtin {
+	return valueFunc(func(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if false == in.IsString() || false == a[0].IsString() {
+			return nil, runtimeError(name + "() requires string inputs")
+		}
+		return jsonconv.NewBool(fn(in.String(), a[0].String())), nil
+	})
+}
+
+// asciiCase shifts ASCII letters between from and to by delta, leaving other
+// characters untouched
+func asciiCase(from, to rune, delta rune) func(string) string {
+	return func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= from && r <= to {
+				return r + delta
+			}
+			return r
+		}, s)
+	}
+}
+
+func mathFunc(fn func(float64) float64) builtin {
+	return valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if false == in.IsNumber() {
+			return nil, runtimeError(describe(in) + " number required")
+		}
+		return newNumber(fn(in.Float())), nil
+	})
+}
+
+func arrayFunc(fn func(elems []*jsonconv.JsonValue) (*jsonconv.JsonValue, error)) builtin {
+	return valueFunc(func(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		if false == in.IsArray() {
+			return nil, runtimeError(describe(in) + " is not an array")
+		}
+		return fn(elements(in))
+	})
+}
+
+func typeFilter(match func(v *jsonconv.JsonValue) bool) builtin {
+	return func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		if match(in) {
+			return out(in)
+		}
+		return nil
+	}
+}
+
+// ====================
+// value functions
+
+func length(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch in.Type() {
+	case jsonconv.Null:
+		return jsonconv.NewInt(0), nil
+	case jsonconv.Number:
+		return newNumber(math.Abs(in.Float())), nil
+	case jsonconv.String:
+		return jsonconv.NewInt(utf8.RuneCountInString(in.String())), nil
+	case jsonconv.Array, jsonconv.Object:
+		return jsonconv.NewInt(in.Length()), nil
+	}
+	return nil, runtimeError(describe(in) + " has no length")
+}
+
+func keys(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	res := jsonconv.NewArray()
+	switch in.Type() {
+	case jsonconv.Object:
+		for _, k := range keysOf(in) {
+			res.Append(jsonconv.NewString(k))
+		}
+		return res, nil
+	case jsonconv.Array:
+		for i := 0; i < in.Length(); i++ {
+			res.Append(jsonconv.NewInt(i))
+		}
+		return res, nil
+	}
+	return nil, runtimeError(describe(in) + " has no keys")
+}
+
+func has(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	key := a[0]
+	switch {
+	case in.IsObject() && key.IsString():
+		_, err := in.Get(key.String())
+		return jsonconv.NewBool(err == nil), nil
+	case in.IsArray() && key.IsNumber():
+		i := key.Float()
+		return jsonconv.NewBool(i >= 0 && i < float64(in.Length())), nil
+	}
+	return nil, runtimeError("Cannot check whether " + typeName(in) + " has a " + typeName(key) + " key")
+}
+
+func addAll(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if false == in.IsArray() && false == in.IsObject() {
+		return nil, runtimeError("Cannot iterate over " + describe(in))
+	}
+	sum := jsonconv.NewNull()
+	for _, v := range elements(in) {
+		var err error
+		if sum, err = add(sum, v); err != nil {
+			return nil, err
+		}
+	}
+	return sum, nil
+}
+
+func tostring(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if in.IsString() {
+		return in, nil
+	}
+	return jsonconv.NewString(toJSON(in)), nil
+}
+
+func tonumber(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch in.Type() {
+	case jsonconv.Number:
+		return in, nil
+	case jsonconv.String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(in.String()), 64)
+		if err != nil {
+			return nil, runtimeError("Cannot parse '" + in.String() + "' as number")
+		}
+		return newNumber(f), nil
+	}
+	return nil, runtimeError(describe(in) + " cannot be parsed as a number")
+}
+
+func join(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if false == in.IsArray() {
+		return nil, runtimeError("Cannot iterate over " + describe(in))
+	}
+	if false == a[0].IsString() {
+		return nil, runtimeError("join separator must be a string")
+	}
+	parts := make([]string, 0, in.Length())
+	for _, v := range elements(in) {
+		switch v.Type() {
+		case jsonconv.Null:
+			parts = append(parts, "")
+		case jsonconv.String:
+			parts = append(parts, v.String())
+		case jsonconv.Number, jsonconv.Boolean:
+			parts = append(parts, toJSON(v))
+		default:
+			return nil, runtimeError("Cannot join with " + describe(v))
+		}
+	}
+	return jsonconv.NewString(strings.Join(parts, a[0].String())), nil
+}
+
+func test(in *jsonconv.JsonValue, a []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if false == in.IsString() || false == a[0].IsString() {
+		return nil, runtimeError(describe(in) + " cannot be matched, as it is not a string")
+	}
+	re, err := regexp.Compile(a[0].String())
+	if err != nil {
+		return nil, runtimeError(a[0].String() + " (at offset 0) is not a valid regex: " + err.Error())
+	}
+	return jsonconv.NewBool(re.MatchString(in.String())), nil
+}
+
+func contains(a, b *jsonconv.JsonValue) bool {
+	switch {
+	case a.IsObject() && b.IsObject():
+		for _, k := range keysOf(b) {
+			av, err := a.Get(k)
+			if err != nil {
+				return false
+			}
+			bv, _ := b.Get(k)
+			if av.Type() != bv.Type() || false == contains(av, bv) {
+				return false
+			}
+		}
+		return true
+	case a.IsArray() && b.IsArray():
+		for _, bv := range elements(b) {
+			found := false
+			for _, av := range elements(a) {
+				if av.Type() == bv.Type() && contains(av, bv) {
+					found = true
+					break
+				}
+			}
+			if false == found {
+				return false
+			}
+		}
+		return true
+	case a.IsString() && b.IsString():
+		return strings.Contains(a.String(), b.String())
+	}
+	return a.Equal(b)
+}
+
+func sortValues(elems []*jsonconv.JsonValue) []*jsonconv.JsonValue {
+	res := append([]*jsonconv.JsonValue{}, elems...)
+	sort.SliceStable(res, func(i, j int) bool {
+		return jsonconv.Compare(res[i], res[j]) < 0
+	})
+	return res
+}
+
+// dedupe removes adjacent equal values from a sorted list
+func dedupe(sorted []*jsonconv.JsonValue) []*jsonconv.JsonValue {
+	res := make([]*jsonconv.JsonValue, 0, len(sorted))
+	for i, v := range sorted {
+		if 0 == i || 0 != jsonconv.Compare(sorted[i-1], v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// extreme returns the element with the minimum (sign -1) or maximum (sign 1)
+// key, or null for an empty list
+func extreme(elems, keys []*jsonconv.JsonValue, sign int) *jsonconv.JsonValue {
+	if 0 == len(elems) {
+		return jsonconv.NewNull()
+	}
+	best := 0
+	for i := 1; i < len(elems); i++ {
+		c := jsonconv.Compare(keys[i], keys[best])
+		if c == sign || (0 == c && sign > 0) {
+			best = i
+		}
+	}
+	return elems[best]
+}
+
+func flatten(elems []*jsonconv.JsonValue, depth int) []*jsonconv.JsonValue {
+	res := make([]*jsonconv.JsonValue, 0, len(elems))
+	for _, v := range elems {
+		if v.IsArray() && depth != 0 {
+			res = append(res, flatten(elements(v), depth-1)...)
+		} else {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func toEntries(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if false == in.IsObject() {
+		return nil, runtimeError(describe(in) + " has no keys")
+	}
+	res := jsonconv.NewArray()
+	for _, k := range keysOf(in) {
+		v, _ := in.Get(k)
+		entry := jsonconv.NewObject()
+		entry.Set(jsonconv.NewString(k), "key")
+		entry.Set(v, "value")
+		res.Append(entry)
+	}
+	return res, nil
+}
+
+func fromEntries(in *jsonconv.JsonValue, _ []*jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if false == in.IsArray() {
+		return nil, runtimeError("Cannot iterate over " + describe(in))
+	}
+	res := jsonconv.NewObject()
+	for _, entry := range elements(in) {
+		if false == entry.IsObject() {
+			return nil, runtimeError("Cannot index " + typeName(entry) + " with \"key\"")
+		}
+		var key *jsonconv.JsonValue
+		for _, name := range []string{"key", "k", "name", "Name", "Key", "K"} {
+			if k, err := entry.Get(name); err == nil && isTruthy(k) {
+				key = k
+				break
+			}
+		}
+		if nil == key {
+			return nil, runtimeError("Cannot use null (null) as object key")
+		}
+		k, _ := tostring(key, nil)
+
+		value := jsonconv.NewNull()
+		for _, name := range []string{"value", "v", "Value", "V"} {
+			if v, err := entry.Get(name); err == nil {
+				value = v
+				break
+			}
+		}
+		res.Set(value, k.String())
+	}
+	return res, nil
+}
+
+// ====================
+// filter functions
+
+func selectFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	return args[0].eval(in, e, func(c *jsonconv.JsonValue) error {
+		if isTruthy(c) {
+			return out(in)
+		}
+		return nil
+	})
+}
+
+func mapFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	if false == in.IsArray() && false == in.IsObject() {
+		return runtimeError("Cannot iterate over " + describe(in))
+	}
+	res := jsonconv.NewArray()
+	for _, v := range elements(in) {
+		err := args[0].eval(v, e, func(r *jsonconv.JsonValue) error {
+			res.Append(r)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return out(res)
+}
+
+func mapValuesFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	// each value is replaced with the first output of the filter, or removed
+	// if there is none
+	first := func(v *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+		var res *jsonconv.JsonValue
+		stop := &stopSignal{}
+		err := args[0].eval(v, e, func(r *jsonconv.JsonValue) error {
+			res = r
+			return stop
+		})
+		if err != nil && err != stop {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	switch in.Type() {
+	case jsonconv.Object:
+		res := jsonconv.NewObject()
+		for _, k := range keysOf(in) {
+			v, _ := in.Get(k)
+			r, err := first(v)
+			if err != nil {
+				return err
+			}
+			if nil != r {
+				res.Set(r, k)
+			}
+		}
+		return out(res)
+	case jsonconv.Array:
+		res := jsonconv.NewArray()
+		for _, v := range elements(in) {
+			r, err := first(v)
+			if err != nil {
+				return err
+			}
+			if nil != r {
+				res.Append(r)
+			}
+		}
+		return out(res)
+	}
+	return runtimeError("Cannot iterate over " + describe(in))
+}
+
+func withEntriesFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	entries, err := toEntries(in, nil)
+	if err != nil {
+		return err
+	}
+	return mapFunc(entries, args, e, func(mapped *jsonconv.JsonValue) error {
+		res, err := fromEntries(mapped, nil)
+		if err != nil {
+			return err
+		}
+		return out(res)
+	})
+}
+
+func recurseFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	if err := out(in); err != nil {
+		return err
+	}
+	return args[0].eval(in, e, func(v *jsonconv.JsonValue) error {
+		return recurseFunc(v, args, e, out)
+	})
+}
+
+func rangeFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	if 1 == len(args) {
+		args = []node{&literalNode{value: jsonconv.NewInt(0)}, args[0]}
+	}
+	return product(args, in, e, func(values []*jsonconv.JsonValue) error {
+		if false == values[0].IsNumber() || false == values[1].IsNumber() {
+			return runtimeError("Range bounds must be numeric")
+		}
+		for f := values[0].Float(); f < values[1].Float(); f++ {
+			if err := out(newNumber(f)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func limitFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	return args[0].eval(in, e, func(n *jsonconv.JsonValue) error {
+		if false == n.IsNumber() {
+			return runtimeError("Invalid limit " + describe(n))
+		}
+		max := int(n.Float())
+		if max <= 0 {
+			return nil
+		}
+		count := 0
+		stop := &stopSignal{}
+		err := args[1].eval(in, e, func(v *jsonconv.JsonValue) error {
+			if err := out(v); err != nil {
+				return err
+			}
+			count++
+			if count >= max {
+				return stop
+			}
+			return nil
+		})
+		if err == stop {
+			return nil
+		}
+		return err
+	})
+}
+
+func firstFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	var res *jsonconv.JsonValue
+	stop := &stopSignal{}
+	err := args[0].eval(in, e, func(v *jsonconv.JsonValue) error {
+		res = v
+		return stop
+	})
+	if err != nil && err != stop {
+		return err
+	}
+	if nil == res {
+		return nil
+	}
+	return out(res)
+}
+
+func lastFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	var res *jsonconv.JsonValue
+	err := args[0].eval(in, e, func(v *jsonconv.JsonValue) error {
+		res = v
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if nil == res {
+		return nil
+	}
+	return out(res)
+}
+
+// anyAllFunc gives any(f) if isAny is true, or all(f) otherwise
+func anyAllFunc(isAny bool) builtin {
+	return func(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+		if false == in.IsArray() && false == in.IsObject() {
+			return runtimeError("Cannot iterate over " + describe(in))
+		}
+		for _, v := range elements(in) {
+			stop := &stopSignal{}
+			err := args[0].eval(v, e, func(c *jsonconv.JsonValue) error {
+				if isTruthy(c) == isAny {
+					return stop
+				}
+				return nil
+			})
+			if err == stop {
+				return out(jsonconv.NewBool(isAny))
+			} else if err != nil {
+				return err
+			}
+		}
+		return out(jsonconv.NewBool(false == isAny))
+	}
+}
+
+// byFunc evaluates f on each element of the input array as sorting key, all
+// outputs of f forming an array, and then calls fn to build the result
+func byFunc(in *jsonconv.JsonValue, f node, e *env, out emitter, fn func(elems, keys []*jsonconv.JsonValue) *jsonconv.JsonValue) error {
+	if false == in.IsArray() {
+		return runtimeError("Cannot index " + typeName(in) + " with number")
+	}
+	elems := elements(in)
+	keys := make([]*jsonconv.JsonValue, len(elems))
+	for i, v := range elems {
+		values, err := collect(f, v, e)
+		if err != nil {
+			return err
+		}
+		keys[i] = newArray(values)
+	}
+	return out(fn(elems, keys))
+}
+
+// groupSorted sorts elems by keys and groups those with equal keys
+func groupSorted(elems, keys []*jsonconv.JsonValue) [][]*jsonconv.JsonValue {
+	indexes := make([]int, len(elems))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return jsonconv.Compare(keys[indexes[i]], keys[indexes[j]]) < 0
+	})
+
+	var groups [][]*jsonconv.JsonValue
+	for i, index := range indexes {
+		if 0 == i || 0 != jsonconv.Compare(keys[indexes[i-1]], keys[index]) {
+			groups = append(groups, nil)
+		}
+		last := len(groups) - 1
+		groups[last] = append(groups[last], elems[index])
+	}
+	return groups
+}
+
+func sortByFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	return byFunc(in, args[0], e, out, func(elems, keys []*jsonconv.JsonValue) *jsonconv.JsonValue {
+		res := jsonconv.NewArray()
+		for _, group := range groupSorted(elems, keys) {
+			for _, v := range group {
+				res.Append(v)
+			}
+		}
+		return res
+	})
+}
+
+func groupByFunc(in *jsonconv.JsonValue, args []node, e *env, out emitter) error {
+	return byFunc(in, args[0], e, out, func(elems, keys []*jsonconv.JsonValue) *jsonconv.JsonValue {
+		res := jsonconv.NewArray()
+		for _, group := range groupSorted(elems, keys) {
+			res.Append(newArray(group))
+		}
+		return res
+	})
+}
@@ -0,0 +1,668 @@
+package jq
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Andrew-M-C/go-tools/jsonconv"
+)
+
+// emitter receives each output of a filter. Returning an error stops the
+// evaluation.
+type emitter func(v *jsonconv.JsonValue) error
+
+// env is a linked list of variable bindings
+type env struct {
+	name   string
+	value  *jsonconv.JsonValue
+	parent *env
+}
+
+func (e *env) bind(name string, v *jsonconv.JsonValue) *env {
+	return &env{name: name, value: v, parent: e}
+}
+
+func (e *env) lookup(name string) (*jsonconv.JsonValue, bool) {
+	for ; nil != e; e = e.parent {
+		if e.name == name {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// passThrough wraps errors coming from downstream filters, so that try does
+// not catch them
+type passThrough struct {
+	err error
+}
+
+func (p *passThrough) Error() string {
+	return p.err.Error()
+}
+
+// stopSignal is returned to stop a generator early, e.g. by limit and first
+type stopSignal struct{}
+
+func (s *stopSignal) Error() string {
+	return "stop"
+}
+
+// collect runs n and gathers all its outputs
+func collect(n node, in *jsonconv.JsonValue, e *env) ([]*jsonconv.JsonValue, error) {
+	var res []*jsonconv.JsonValue
+	err := n.eval(in, e, func(v *jsonconv.JsonValue) error {
+		res = append(res, v)
+		return nil
+	})
+	return res, err
+}
+
+// ====================
+// helpers
+
+func newNumber(f float64) *jsonconv.JsonValue {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return jsonconv.NewInt64(int64(f))
+	}
+	return jsonconv.NewFloat(f)
+}
+
+func newArray(elems []*jsonconv.JsonValue) *jsonconv.JsonValue {
+	arr := jsonconv.NewArray()
+	for _, v := range elems {
+		arr.Append(v)
+	}
+	return arr
+}
+
+func isTruthy(v *jsonconv.JsonValue) bool {
+	switch v.Type() {
+	case jsonconv.Null:
+		return false
+	case jsonconv.Boolean:
+		return v.Bool()
+	default:
+		return true
+	}
+}
+
+func typeName(v *jsonconv.JsonValue) string {
+	switch v.Type() {
+	case jsonconv.Null:
+		return "null"
+	case jsonconv.Boolean:
+		return "boolean"
+	case jsonconv.Number:
+		return "number"
+	case jsonconv.String:
+		return "string"
+	case jsonconv.Array:
+		return "array"
+	case jsonconv.Object:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+func toJSON(v *jsonconv.JsonValue) string {
+	s, _ := v.Marshal(jsonconv.Option{ShowNull: true, SortMode: jsonconv.DictAsc})
+	return s
+}
+
+// describe gives a short form of v for error messages
+func describe(v *jsonconv.JsonValue) string {
+	s := toJSON(v)
+	if len(s) > 11 {
+		s = s[:10] + "..."
+	}
+	return typeName(v) + " (" + s + ")"
+}
+
+// elements returns array elements, or object values in key order
+func elements(v *jsonconv.JsonValue) []*jsonconv.JsonValue {
+	var res []*jsonconv.JsonValue
+	switch v.Type() {
+	case jsonconv.Array:
+		res = make([]*jsonconv.JsonValue, 0, v.Length())
+		v.ArrayForeach(func(_ int, child *jsonconv.JsonValue) error {
+			res = append(res, child)
+			return nil
+		})
+	case jsonconv.Object:
+		for _, k := range keysOf(v) {
+			child, _ := v.Get(k)
+			res = append(res, child)
+		}
+	}
+	return res
+}
+
+func keysOf(v *jsonconv.JsonValue) []string {
+	keys := make([]string, 0, v.Length())
+	v.ObjectForeach(func(k string, _ *jsonconv.JsonValue) error {
+		keys = append(keys, k)
+		return nil
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// copyObject returns a new object sharing the children of v
+func copyObject(v *jsonconv.JsonValue) *jsonconv.JsonValue {
+	res := jsonconv.NewObject()
+	v.ObjectForeach(func(k string, child *jsonconv.JsonValue) error {
+		res.Set(child, k)
+		return nil
+	})
+	return res
+}
+
+// product evaluates every node in list against in, and calls fn with each
+// combination of outputs
+func product(list []node, in *jsonconv.JsonValue, e *env, fn func(values []*jsonconv.JsonValue) error) error {
+	values := make([]*jsonconv.JsonValue, len(list))
+	var walk func(i int) error
+	walk = func(i int) error {
+		if i == len(list) {
+			return fn(values)
+		}
+		return list[i].eval(in, e, func(v *jsonconv.JsonValue) error {
+			values[i] = v
+			return walk(i + 1)
+		})
+	}
+	return walk(0)
+}
+
+// ====================
+// eval
+
+func (n *identityNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return out(in)
+}
+
+func (n *recurseNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return recurse(in, out)
+}
+
+func recurse(v *jsonconv.JsonValue, out emitter) error {
+	if err := out(v); err != nil {
+		return err
+	}
+	for _, child := range elements(v) {
+		if err := recurse(child, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *literalNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return out(n.value)
+}
+
+func (n *varNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	v, exist := e.lookup(n.name)
+	if false == exist {
+		return runtimeError("$" + n.name + " is not defined")
+	}
+	return out(v)
+}
+
+func (n *indexNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.target.eval(in, e, func(t *jsonconv.JsonValue) error {
+		return n.index.eval(in, e, func(index *jsonconv.JsonValue) error {
+			v, err := indexValue(t, index)
+			if err != nil {
+				return err
+			}
+			return out(v)
+		})
+	})
+}
+
+func indexValue(t, index *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch {
+	case t.IsNull() && (index.IsString() || index.IsNumber() || index.IsNull()):
+		return jsonconv.NewNull(), nil
+
+	case t.IsObject() && index.IsString():
+		if v, err := t.Get(index.String()); err == nil {
+			return v, nil
+		}
+		return jsonconv.NewNull(), nil
+
+	case t.IsArray() && index.IsNumber():
+		i := int(math.Floor(index.Float()))
+		if i < 0 {
+			i += t.Length()
+		}
+		if i < 0 || i >= t.Length() {
+			return jsonconv.NewNull(), nil
+		}
+		return t.Get(i)
+	}
+
+	if index.IsString() {
+		return nil, runtimeError("Cannot index " + typeName(t) + " with \"" + index.String() + "\"")
+	}
+	return nil, runtimeError("Cannot index " + typeName(t) + " with " + typeName(index))
+}
+
+func (n *sliceNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	from, to := n.from, n.to
+	if nil == from {
+		from = &literalNode{value: jsonconv.NewNull()}
+	}
+	if nil == to {
+		to = &literalNode{value: jsonconv.NewNull()}
+	}
+	return n.target.eval(in, e, func(t *jsonconv.JsonValue) error {
+		return product([]node{from, to}, in, e, func(bounds []*jsonconv.JsonValue) error {
+			v, err := sliceValue(t, bounds[0], bounds[1])
+			if err != nil {
+				return err
+			}
+			return out(v)
+		})
+	})
+}
+
+func sliceValue(t, from, to *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if t.IsNull() {
+		return t, nil
+	}
+	if (false == from.IsNull() && false == from.IsNumber()) || (false == to.IsNull() && false == to.IsNumber()) {
+		return nil, runtimeError("Start and end indices of an slice must be numbers")
+	}
+
+	var runes []rune
+	l := 0
+	switch t.Type() {
+	case jsonconv.Array:
+		l = t.Length()
+	case jsonconv.String:
+		runes = []rune(t.String())
+		l = len(runes)
+	default:
+		return nil, runtimeError("Cannot index " + typeName(t) + " with object")
+	}
+
+	clamp := func(b *jsonconv.JsonValue, dft int, round func(float64) float64) int {
+		if b.IsNull() {
+			return dft
+		}
+		i := int(round(b.Float()))
+		if i < 0 {
+			i += l
+		}
+		if i < 0 {
+			return 0
+		} else if i > l {
+			return l
+		}
+		return i
+	}
+	start := clamp(from, 0, math.Floor)
+	end := clamp(to, l, math.Ceil)
+	if end < start {
+		end = start
+	}
+
+	if nil != runes {
+		return jsonconv.NewString(string(runes[start:end])), nil
+	}
+	return newArray(elements(t)[start:end]), nil
+}
+
+func (n *iterateNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.target.eval(in, e, func(t *jsonconv.JsonValue) error {
+		if false == t.IsArray() && false == t.IsObject() {
+			return runtimeError("Cannot iterate over " + describe(t))
+		}
+		for _, v := range elements(t) {
+			if err := out(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (n *tryNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	err := n.body.eval(in, e, func(v *jsonconv.JsonValue) error {
+		if err := out(v); err != nil {
+			return &passThrough{err: err}
+		}
+		return nil
+	})
+	if nil == err {
+		return nil
+	}
+	if p, ok := err.(*passThrough); ok {
+		return p.err
+	}
+	rErr, ok := err.(*RuntimeError)
+	if false == ok {
+		return err
+	}
+	if nil == n.handler {
+		return nil
+	}
+	return n.handler.eval(rErr.value(), e, out)
+}
+
+func (n *stringNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return product(n.parts, in, e, func(values []*jsonconv.JsonValue) error {
+		b := strings.Builder{}
+		for _, v := range values {
+			if v.IsString() {
+				b.WriteString(v.String())
+			} else {
+				b.WriteString(toJSON(v))
+			}
+		}
+		return out(jsonconv.NewString(b.String()))
+	})
+}
+
+func (n *arrayNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	if nil == n.body {
+		return out(jsonconv.NewArray())
+	}
+	values, err := collect(n.body, in, e)
+	if err != nil {
+		return err
+	}
+	return out(newArray(values))
+}
+
+func (n *objectNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	list := make([]node, 0, 2*len(n.entries))
+	for _, entry := range n.entries {
+		list = append(list, entry.key, entry.value)
+	}
+	return product(list, in, e, func(values []*jsonconv.JsonValue) error {
+		obj := jsonconv.NewObject()
+		for i := 0; i < len(values); i += 2 {
+			if false == values[i].IsString() {
+				return runtimeError("Object keys must be strings")
+			}
+			obj.Set(values[i+1], values[i].String())
+		}
+		return out(obj)
+	})
+}
+
+func (n *negNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.x.eval(in, e, func(v *jsonconv.JsonValue) error {
+		if false == v.IsNumber() {
+			return runtimeError(describe(v) + " cannot be negated")
+		}
+		return out(newNumber(-v.Float()))
+	})
+}
+
+func (n *binaryNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.r.eval(in, e, func(r *jsonconv.JsonValue) error {
+		return n.l.eval(in, e, func(l *jsonconv.JsonValue) error {
+			v, err := binaryOp(n.op, l, r)
+			if err != nil {
+				return err
+			}
+			return out(v)
+		})
+	})
+}
+
+func binaryOp(op string, l, r *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch op {
+	case "+":
+		return add(l, r)
+	case "-":
+		return subtract(l, r)
+	case "*":
+		return multiply(l, r)
+	case "/":
+		return divide(l, r)
+	case "%":
+		return modulo(l, r)
+	case "==":
+		return jsonconv.NewBool(0 == jsonconv.Compare(l, r)), nil
+	case "!=":
+		return jsonconv.NewBool(0 != jsonconv.Compare(l, r)), nil
+	case "<":
+		return jsonconv.NewBool(jsonconv.Compare(l, r) < 0), nil
+	case "<=":
+		return jsonconv.NewBool(jsonconv.Compare(l, r) <= 0), nil
+	case ">":
+		return jsonconv.NewBool(jsonconv.Compare(l, r) > 0), nil
+	case ">=":
+		return jsonconv.NewBool(jsonconv.Compare(l, r) >= 0), nil
+	}
+	return nil, runtimeError("unknown operator " + op)
+}
+
+func add(l, r *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch {
+	case l.IsNull():
+		return r, nil
+	case r.IsNull():
+		return l, nil
+	case l.IsNumber() && r.IsNumber():
+		return newNumber(l.Float() + r.Float()), nil
+	case l.IsString() && r.IsString():
+		return jsonconv.NewString(l.String() + r.String()), nil
+	case l.IsArray() && r.IsArray():
+		return newArray(append(elements(l), elements(r)...)), nil
+	case l.IsObject() && r.IsObject():
+		res := copyObject(l)
+		r.ObjectForeach(func(k string, v *jsonconv.JsonValue) error {
+			res.Set(v, k)
+			return nil
+		})
+		return res, nil
+	}
+	return nil, runtimeError(describe(l) + " and " + describe(r) + " cannot be added")
+}
+
+func subtract(l, r *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch {
+	case l.IsNumber() && r.IsNumber():
+		return newNumber(l.Float() - r.Float()), nil
+	case l.IsArray() && r.IsArray():
+		removed := elements(r)
+		res := jsonconv.NewArray()
+		for _, v := range elements(l) {
+			found := false
+			for _, x := range removed {
+				if v.Equal(x) {
+					found = true
+					break
+				}
+			}
+			if false == found {
+				res.Append(v)
+			}
+		}
+		return res, nil
+	}
+	return nil, runtimeError(describe(l) + " and " + describe(r) + " cannot be subtracted")
+}
+
+func multiply(l, r *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch {
+	case l.IsNumber() && r.IsNumber():
+		return newNumber(l.Float() * r.Float()), nil
+	case l.IsString() && r.IsNumber(), l.IsNumber() && r.IsString():
+		s, n := l, r
+		if l.IsNumber() {
+			s, n = r, l
+		}
+		if n.Float() <= 0 {
+			return jsonconv.NewNull(), nil
+		}
+		times := int(math.Ceil(n.Float()))
+		return jsonconv.NewString(strings.Repeat(s.String(), times)), nil
+	case l.IsObject() && r.IsObject():
+		return deepMerge(l, r), nil
+	}
+	return nil, runtimeError(describe(l) + " and " + describe(r) + " cannot be multiplied")
+}
+
+func deepMerge(l, r *jsonconv.JsonValue) *jsonconv.JsonValue {
+	res := copyObject(l)
+	r.ObjectForeach(func(k string, v *jsonconv.JsonValue) error {
+		if old, err := res.Get(k); err == nil && old.IsObject() && v.IsObject() {
+			v = deepMerge(old, v)
+		}
+		res.Set(v, k)
+		return nil
+	})
+	return res
+}
+
+func divide(l, r *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	switch {
+	case l.IsNumber() && r.IsNumber():
+		if 0 == r.Float() {
+			return nil, runtimeError(describe(l) + " and " + describe(r) + " cannot be divided because the divisor is zero")
+		}
+		return newNumber(l.Float() / r.Float()), nil
+	case l.IsString() && r.IsString():
+		return splitString(l.String(), r.String()), nil
+	}
+	return nil, runtimeError(describe(l) + " and " + describe(r) + " cannot be divided")
+}
+
+func modulo(l, r *jsonconv.JsonValue) (*jsonconv.JsonValue, error) {
+	if l.IsNumber() && r.IsNumber() {
+		d := int64(r.Float())
+		if d < 0 {
+			d = -d
+		}
+		if 0 == d {
+			return nil, runtimeError(describe(l) + " and " + describe(r) + " cannot be divided because the divisor is zero")
+		}
+		return newNumber(float64(int64(l.Float()) % d)), nil
+	}
+	return nil, runtimeError(describe(l) + " and " + describe(r) + " cannot be divided")
+}
+
+func splitString(s, sep string) *jsonconv.JsonValue {
+	res := jsonconv.NewArray()
+	if "" == s {
+		return res
+	}
+	for _, part := range strings.Split(s, sep) {
+		res.Append(jsonconv.NewString(part))
+	}
+	return res
+}
+
+func (n *pipeNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.l.eval(in, e, func(v *jsonconv.JsonValue) error {
+		return n.r.eval(v, e, out)
+	})
+}
+
+func (n *commaNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	if err := n.l.eval(in, e, out); err != nil {
+		return err
+	}
+	return n.r.eval(in, e, out)
+}
+
+func (n *altNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	var values []*jsonconv.JsonValue
+	err := n.l.eval(in, e, func(v *jsonconv.JsonValue) error {
+		if isTruthy(v) {
+			values = append(values, v)
+		}
+		return nil
+	})
+	if _, ok := err.(*RuntimeError); false == ok && err != nil {
+		return err
+	}
+	if 0 == len(values) {
+		return n.r.eval(in, e, out)
+	}
+	for _, v := range values {
+		if err := out(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *andNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.l.eval(in, e, func(l *jsonconv.JsonValue) error {
+		if false == isTruthy(l) {
+			return out(jsonconv.NewBool(false))
+		}
+		return n.r.eval(in, e, func(r *jsonconv.JsonValue) error {
+			return out(jsonconv.NewBool(isTruthy(r)))
+		})
+	})
+}
+
+func (n *orNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.l.eval(in, e, func(l *jsonconv.JsonValue) error {
+		if isTruthy(l) {
+			return out(jsonconv.NewBool(true))
+		}
+		return n.r.eval(in, e, func(r *jsonconv.JsonValue) error {
+			return out(jsonconv.NewBool(isTruthy(r)))
+		})
+	})
+}
+
+func (n *bindNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.source.eval(in, e, func(v *jsonconv.JsonValue) error {
+		return n.body.eval(in, e.bind(n.name, v), out)
+	})
+}
+
+func (n *reduceNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.init.eval(in, e, func(acc *jsonconv.JsonValue) error {
+		err := n.source.eval(in, e, func(x *jsonconv.JsonValue) error {
+			var last *jsonconv.JsonValue
+			err := n.update.eval(acc, e.bind(n.name, x), func(v *jsonconv.JsonValue) error {
+				last = v
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if nil == last {
+				last = jsonconv.NewNull()
+			}
+			acc = last
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return out(acc)
+	})
+}
+
+func (n *ifNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	return n.cond.eval(in, e, func(c *jsonconv.JsonValue) error {
+		if isTruthy(c) {
+			return n.then.eval(in, e, out)
+		}
+		if nil == n.otherwise {
+			return out(in)
+		}
+		return n.otherwise.eval(in, e, out)
+	})
+}
+
+func (n *callNode) eval(in *jsonconv.JsonValue, e *env, out emitter) error {
+	fn := builtins[builtinKey(n.name, len(n.args))]
+	return fn(in, n.args, e, out)
+}
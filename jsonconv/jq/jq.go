@@ -0,0 +1,127 @@
+// Package jq evaluates a subset of the jq language over jsonconv values.
+//
+// Supported are paths (.a.b, .["key"], .[0], .[1:3], .[], ..), optional
+// access with ?, pipes, commas, literals, array and object construction,
+// string interpolation, arithmetic and comparison operators, and, or, the
+// alternative operator //, if-elif-else, try-catch, variable binding with
+// `as $x`, reduce, and common builtins such as select, map, keys, length,
+// sort_by, group_by, to_entries and join. User-defined functions, paths
+// assignment and modules are not supported.
+package jq
+
+import (
+	"fmt"
+
+	"github.com/Andrew-M-C/go-tools/jsonconv"
+)
+
+// SyntaxError is returned by Compile for invalid expressions
+type SyntaxError struct {
+	// byte offset in the expression
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("jq: syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+func syntaxError(offset int, msg string) error {
+	return &SyntaxError{Offset: offset, Msg: msg}
+}
+
+// RuntimeError is returned when evaluation fails, either for invalid
+// operations or by the error builtin. Such errors can be caught with try.
+type RuntimeError struct {
+	Msg string
+	// Value is the argument of the error builtin, if raised by it
+	Value *jsonconv.JsonValue
+}
+
+func (e *RuntimeError) Error() string {
+	if nil != e.Value && false == e.Value.IsString() {
+		return "jq: error: " + toJSON(e.Value)
+	}
+	return "jq: error: " + e.Msg
+}
+
+// value returns what catch receives
+func (e *RuntimeError) value() *jsonconv.JsonValue {
+	if nil != e.Value {
+		return e.Value
+	}
+	return jsonconv.NewString(e.Msg)
+}
+
+func runtimeError(msg string) error {
+	return &RuntimeError{Msg: msg}
+}
+
+// Query is a compiled expression. It is immutable and may be run
+// concurrently.
+type Query struct {
+	src  string
+	root node
+}
+
+// Compile parses a jq expression
+func Compile(expr string) (*Query, error) {
+	root, err := parse(expr, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Query{src: expr, root: root}, nil
+}
+
+// MustCompile is like Compile but panics on error
+func MustCompile(expr string) *Query {
+	q, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source expression
+func (q *Query) String() string {
+	return q.src
+}
+
+// Run evaluates the query with input and returns all outputs. A nil input is
+// regarded as null. Input values are never modified, but outputs may share
+// children with them.
+func (q *Query) Run(input *jsonconv.JsonValue) ([]*jsonconv.JsonValue, error) {
+	res := make([]*jsonconv.JsonValue, 0, 1)
+	err := q.Iterate(input, func(v *jsonconv.JsonValue) error {
+		res = append(res, v)
+		return nil
+	})
+	return res, err
+}
+
+// Iterate evaluates the query with input and calls callback with each
+// output. It stops at the first error, including one returned by callback.
+func (q *Query) Iterate(input *jsonconv.JsonValue, callback func(v *jsonconv.JsonValue) error) error {
+	if nil == input {
+		input = jsonconv.NewNull()
+	}
+	err := q.root.eval(input, nil, func(v *jsonconv.JsonValue) error {
+		if err := callback(v); err != nil {
+			return &passThrough{err: err}
+		}
+		return nil
+	})
+	if p, ok := err.(*passThrough); ok {
+		return p.err
+	}
+	return err
+}
+
+// Eval compiles expr and runs it with input
+func Eval(expr string, input *jsonconv.JsonValue) ([]*jsonconv.JsonValue, error) {
+	q, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Run(input)
+}
@@ -0,0 +1,95 @@
+package jq
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Andrew-M-C/go-tools/jsonconv"
+)
+
+const testInput = `{
+	"a": {"b": [1, 2, 3]},
+	"s": "hi",
+	"users": [
+		{"name": "bob", "age": 30},
+		{"name": "al", "age": 20, "tags": ["x"]}
+	]
+}`
+
+func runToString(t *testing.T, expr string) string {
+	in, err := jsonconv.NewFromString(testInput)
+	if err != nil {
+		t.Fatalf("parse input: %v", err)
+	}
+	res, err := Eval(expr, in)
+	if err != nil {
+		t.Errorf("%s: %v", expr, err)
+		return ""
+	}
+	parts := make([]string, 0, len(res))
+	for _, v := range res {
+		s, _ := v.Marshal(jsonconv.Option{ShowNull: true, SortMode: jsonconv.DictAsc})
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestEval(t *testing.T) {
+	cases := []struct {
+		expr   string
+		expect string
+	}{
+		{`.a.b[1], .a.b[-1], .missing.x`, `2 3 null`},
+		{`.a.b[1:], .s[:1]`, `[2,3] "h"`},
+		{`.users[] | select(.age > 25) | .name`, `"bob"`},
+		{`.a.b | map(. * 2)`, `[2,4,6]`},
+		{`{n: .users[0].name, "k": (.a.b | length), (.s): 1}`, `{"hi":1,"k":3,"n":"bob"}`},
+		{`keys, (.users | length)`, `["a","s","users"] 2`},
+		{`1 + 2 * 3 - 4 / 2, 7 % 3, -.a.b[0]`, `5 1 -1`},
+		{`"x=\(.a.b[0] + 1) \(.s) \(.a)"`, `"x=2 hi {\"b\":[1,2,3]}"`},
+		{`reduce .a.b[] as $x (0; . + $x)`, `6`},
+		{`[.a.b[] as $x | $x * $x]`, `[1,4,9]`},
+		{`.users | sort_by(.age) | map(.name) | join(",")`, `"al,bob"`},
+		{`.users | map({(.name): .age}) | add`, `{"al":20,"bob":30}`},
+		{`.users[] | .tags // "none"`, `"none" ["x"]`},
+		{`if .s == "hi" then 1 elif .s then 2 else 3 end`, `1`},
+		{`try error("boom") catch ., (.s[0])?`, `"boom"`},
+		{`[limit(2; .a.b[])], first(.users[].name), [range(1; 3)]`, `[1,2] "bob" [1,2]`},
+		{`.users[1] | to_entries | map(.key)`, `["age","name","tags"]`},
+		{`with_entries(select(.key == "a")), (.a | has("b"))`, `{"a":{"b":[1,2,3]}} true`},
+		{`[..] | length`, `16`},
+		{`{"a": 1} + {"b": 2} | keys`, `["a","b"]`},
+		{`[.a.b[] | tostring] | join("-") | split("-")`, `["1","2","3"]`},
+		{`"é😀a" | length, ascii_upcase`, `3 "é😀A"`},
+	}
+	for _, c := range cases {
+		if s := runToString(t, c.expr); s != c.expect {
+			t.Errorf("%s: expected %s, got %s", c.expr, c.expect, s)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, expr := range []string{`.a |`, `{a: }`, `"abc`, `nosuchfunc(1)`, `.[1`} {
+		_, err := Compile(expr)
+		if _, ok := err.(*SyntaxError); false == ok {
+			t.Errorf("%s: expected SyntaxError, got %v", expr, err)
+		}
+	}
+
+	_, err := Eval(`.s[0]`, jsonconv.NewString("x"))
+	if _, ok := err.(*RuntimeError); false == ok {
+		t.Errorf("expected RuntimeError, got %v", err)
+	}
+
+	// errors from callback are not caught by try
+	q := MustCompile(`try (1, 2)`)
+	count := 0
+	err = q.Iterate(nil, func(v *jsonconv.JsonValue) error {
+		count++
+		return jsonconv.ParaError
+	})
+	if err != jsonconv.ParaError || count != 1 {
+		t.Errorf("unexpected result: %v, %d", err, count)
+	}
+}
@@ -0,0 +1,284 @@
+package jq
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokField  // .name
+	tokDot    // .
+	tokDotDot // ..
+	tokVar    // $name
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+	// number value for tokNumber
+	num float64
+	// parts of tokString: literal strings, and raw interpolated expressions
+	// with their offsets
+	parts []stringPart
+}
+
+type stringPart struct {
+	literal string
+	expr    string
+	offset  int
+	isExpr  bool
+}
+
+// punctuations, longest first
+var puncts = []string{
+	"//", "==", "!=", "<=", ">=", "|", ",", "(", ")", "[", "]", "{", "}",
+	":", ";", "?", "+", "-", "*", "/", "%", "<", ">",
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func lex(src string, base int) ([]token, error) {
+	tokens := make([]token, 0, 16)
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case c == '.':
+			if i+1 < len(src) && src[i+1] == '.' {
+				tokens = append(tokens, token{kind: tokDotDot, text: "..", offset: base + i})
+				i += 2
+			} else if i+1 < len(src) && isIdentStart(src[i+1]) {
+				j := i + 1
+				for j < len(src) && isIdentChar(src[j]) {
+					j++
+				}
+				tokens = append(tokens, token{kind: tokField, text: src[i+1 : j], offset: base + i})
+				i = j
+			} else if i+1 < len(src) && isDigit(src[i+1]) {
+				n, err := lexNumber(src, i, base)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, n)
+				i += len(n.text)
+			} else {
+				tokens = append(tokens, token{kind: tokDot, text: ".", offset: base + i})
+				i++
+			}
+
+		case c == '$':
+			j := i + 1
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, syntaxError(base+i, "variable name expected after $")
+			}
+			tokens = append(tokens, token{kind: tokVar, text: src[i+1 : j], offset: base + i})
+			i = j
+
+		case isIdentStart(c):
+			j := i
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[i:j], offset: base + i})
+			i = j
+
+		case isDigit(c):
+			n, err := lexNumber(src, i, base)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, n)
+			i += len(n.text)
+
+		case c == '"':
+			t, end, err := lexString(src, i, base)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			i = end
+
+		default:
+			matched := false
+			for _, p := range puncts {
+				if strings.HasPrefix(src[i:], p) {
+					tokens = append(tokens, token{kind: tokPunct, text: p, offset: base + i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if false == matched {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, syntaxError(base+i, "unexpected character '"+string(r)+"'")
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tokEOF, offset: base + len(src)})
+	return tokens, nil
+}
+
+func lexNumber(src string, start, base int) (token, error) {
+	j := start
+	for j < len(src) && isDigit(src[j]) {
+		j++
+	}
+	if j < len(src) && src[j] == '.' {
+		j++
+		for j < len(src) && isDigit(src[j]) {
+			j++
+		}
+	}
+	if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+		k := j + 1
+		if k < len(src) && (src[k] == '+' || src[k] == '-') {
+			k++
+		}
+		if k < len(src) && isDigit(src[k]) {
+			for k < len(src) && isDigit(src[k]) {
+				k++
+			}
+			j = k
+		}
+	}
+	text := src[start:j]
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return token{}, syntaxError(base+start, "invalid number "+text)
+	}
+	return token{kind: tokNumber, text: text, offset: base + start, num: f}, nil
+}
+
+// lexString reads a string literal starting at the quote, and returns the
+// token and the offset after the closing quote.
+func lexString(src string, start, base int) (token, int, error) {
+	t := token{kind: tokString, offset: base + start}
+	b := strings.Builder{}
+	i := start + 1
+	for {
+		if i >= len(src) {
+			return t, 0, syntaxError(base+start, "unterminated string")
+		}
+		c := src[i]
+		switch c {
+		case '"':
+			t.parts = append(t.parts, stringPart{literal: b.String()})
+			t.text = src[start : i+1]
+			return t, i + 1, nil
+
+		case '\\':
+			if i+1 >= len(src) {
+				return t, 0, syntaxError(base+i, "unterminated string")
+			}
+			switch e := src[i+1]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+				i += 2
+			case 'b':
+				b.WriteByte('\b')
+				i += 2
+			case 'f':
+				b.WriteByte('\f')
+				i += 2
+			case 'n':
+				b.WriteByte('\n')
+				i += 2
+			case 'r':
+				b.WriteByte('\r')
+				i += 2
+			case 't':
+				b.WriteByte('\t')
+				i += 2
+			case 'u':
+				if i+6 > len(src) {
+					return t, 0, syntaxError(base+i, "invalid \\u escape")
+				}
+				u, err := strconv.ParseUint(src[i+2:i+6], 16, 16)
+				if err != nil {
+					return t, 0, syntaxError(base+i, "invalid \\u escape")
+				}
+				r := rune(u)
+				i += 6
+				if utf16.IsSurrogate(r) && i+6 <= len(src) && strings.HasPrefix(src[i:], "\\u") {
+					if low, err := strconv.ParseUint(src[i+2:i+6], 16, 16); err == nil {
+						if combined := utf16.DecodeRune(r, rune(low)); combined != utf8.RuneError {
+							r = combined
+							i += 6
+						}
+					}
+				}
+				b.WriteRune(r)
+			case '(':
+				end, err := matchParen(src, i+1, base)
+				if err != nil {
+					return t, 0, err
+				}
+				t.parts = append(t.parts, stringPart{literal: b.String()})
+				b.Reset()
+				t.parts = append(t.parts, stringPart{expr: src[i+2 : end], offset: base + i + 2, isExpr: true})
+				i = end + 1
+			default:
+				return t, 0, syntaxError(base+i, "invalid escape \\"+string(e))
+			}
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+}
+
+// matchParen returns the offset of the parenthesis closing the one at start,
+// skipping over nested strings.
+func matchParen(src string, start, base int) (int, error) {
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if 0 == depth {
+				return i, nil
+			}
+		case '"':
+			_, end, err := lexString(src, i, base)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+	return 0, syntaxError(base+start, "unterminated string interpolation")
+}
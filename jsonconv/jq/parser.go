@@ -0,0 +1,720 @@
+package jq
+
+import (
+	"strconv"
+
+	"github.com/Andrew-M-C/go-tools/jsonconv"
+)
+
+// ====================
+// AST
+
+type node interface {
+	eval(in *jsonconv.JsonValue, env *env, out emitter) error
+}
+
+type identityNode struct{}
+
+type recurseNode struct{}
+
+type literalNode struct {
+	value *jsonconv.JsonValue
+}
+
+type indexNode struct {
+	target node
+	index  node
+}
+
+type sliceNode struct {
+	target node
+	from   node // may be nil
+	to     node // may be nil
+}
+
+type iterateNode struct {
+	target node
+}
+
+type tryNode struct {
+	body    node
+	handler node // may be nil
+}
+
+type stringNode struct {
+	parts []node
+}
+
+type arrayNode struct {
+	body node // nil for []
+}
+
+type objectEntry struct {
+	key   node
+	value node
+}
+
+type objectNode struct {
+	entries []objectEntry
+}
+
+type negNode struct {
+	x node
+}
+
+type binaryNode struct {
+	op   string
+	l, r node
+}
+
+type pipeNode struct {
+	l, r node
+}
+
+type commaNode struct {
+	l, r node
+}
+
+type altNode struct {
+	l, r node
+}
+
+type andNode struct {
+	l, r node
+}
+
+type orNode struct {
+	l, r node
+}
+
+type bindNode struct {
+	source node
+	name   string
+	body   node
+}
+
+type reduceNode struct {
+	source node
+	name   string
+	init   node
+	update node
+}
+
+type ifNode struct {
+	cond      node
+	then      node
+	otherwise node // may be nil, meaning identity
+}
+
+type varNode struct {
+	name   string
+	offset int
+}
+
+type callNode struct {
+	name   string
+	args   []node
+	offset int
+}
+
+// ====================
+// parser
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(src string, base int) (node, error) {
+	tokens, err := lex(src, base)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, syntaxError(t.offset, "unexpected '"+t.text+"'")
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) isKeyword(s string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	t := p.next()
+	if t.kind != tokPunct || t.text != s {
+		return syntaxError(t.offset, "expected '"+s+"' but got '"+t.text+"'")
+	}
+	return nil
+}
+
+func (p *parser) expectKeyword(s string) error {
+	t := p.next()
+	if t.kind != tokIdent || t.text != s {
+		return syntaxError(t.offset, "expected '"+s+"' but got '"+t.text+"'")
+	}
+	return nil
+}
+
+// parsePipe parses `a | b`, `a as $x | b` and, unless noComma, `a, b`
+func (p *parser) parsePipe(noComma bool) (node, error) {
+	var l node
+	var err error
+	if noComma {
+		l, err = p.parseAlt()
+	} else {
+		l, err = p.parseComma()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("as") {
+		p.next()
+		v := p.next()
+		if v.kind != tokVar {
+			return nil, syntaxError(v.offset, "variable expected after 'as'")
+		}
+		if err = p.expectPunct("|"); err != nil {
+			return nil, err
+		}
+		body, err := p.parsePipe(noComma)
+		if err != nil {
+			return nil, err
+		}
+		return &bindNode{source: l, name: v.text, body: body}, nil
+	}
+
+	if p.isPunct("|") {
+		p.next()
+		r, err := p.parsePipe(noComma)
+		if err != nil {
+			return nil, err
+		}
+		return &pipeNode{l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parseComma() (node, error) {
+	l, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct(",") {
+		p.next()
+		r, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		l = &commaNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAlt() (node, error) {
+	l, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("//") {
+		p.next()
+		r, err := p.parseAlt()
+		if err != nil {
+			return nil, err
+		}
+		return &altNode{l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &orNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		r, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		l = &andNode{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseCompare() (node, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.isPunct(op) {
+			p.next()
+			r, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryNode{op: op, l: l, r: r}, nil
+		}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isPunct("*") || p.isPunct("/") || p.isPunct("%") {
+		op := p.next().text
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryNode{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isPunct("-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseSuffixes(n)
+}
+
+func (p *parser) parseSuffixes(n node) (node, error) {
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokField:
+			p.next()
+			n = &indexNode{target: n, index: &literalNode{value: jsonconv.NewString(t.text)}}
+
+		case t.kind == tokDot && p.tokens[p.pos+1].kind == tokString:
+			p.next()
+			key, err := p.parseString(p.next())
+			if err != nil {
+				return nil, err
+			}
+			n = &indexNode{target: n, index: key}
+
+		case t.kind == tokDot && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].text == "[":
+			p.next()
+
+		case t.kind == tokPunct && t.text == "[":
+			p.next()
+			var err error
+			if n, err = p.parseBracket(n); err != nil {
+				return nil, err
+			}
+
+		case t.kind == tokPunct && t.text == "?":
+			p.next()
+			n = &tryNode{body: n}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+// parseBracket parses what follows '[' after a term: `[]`, `[i]`, `[a:b]`
+func (p *parser) parseBracket(target node) (node, error) {
+	if p.isPunct("]") {
+		p.next()
+		return &iterateNode{target: target}, nil
+	}
+	if p.isPunct(":") {
+		p.next()
+		to, err := p.parsePipe(false)
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+		return &sliceNode{target: target, to: to}, nil
+	}
+
+	index, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct(":") {
+		p.next()
+		var to node
+		if false == p.isPunct("]") {
+			if to, err = p.parsePipe(false); err != nil {
+				return nil, err
+			}
+		}
+		if err = p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+		return &sliceNode{target: target, from: index, to: to}, nil
+	}
+	if err = p.expectPunct("]"); err != nil {
+		return nil, err
+	}
+	return &indexNode{target: target, index: index}, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokDot:
+		if p.peek().kind == tokString {
+			key, err := p.parseString(p.next())
+			if err != nil {
+				return nil, err
+			}
+			return &indexNode{target: &identityNode{}, index: key}, nil
+		}
+		return &identityNode{}, nil
+
+	case tokDotDot:
+		return &recurseNode{}, nil
+
+	case tokField:
+		return &indexNode{target: &identityNode{}, index: &literalNode{value: jsonconv.NewString(t.text)}}, nil
+
+	case tokNumber:
+		return &literalNode{value: newNumber(t.num)}, nil
+
+	case tokString:
+		return p.parseString(t)
+
+	case tokVar:
+		return &varNode{name: t.text, offset: t.offset}, nil
+
+	case tokPunct:
+		switch t.text {
+		case "(":
+			n, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+
+		case "[":
+			if p.isPunct("]") {
+				p.next()
+				return &arrayNode{}, nil
+			}
+			body, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expectPunct("]"); err != nil {
+				return nil, err
+			}
+			return &arrayNode{body: body}, nil
+
+		case "{":
+			return p.parseObject()
+		}
+
+	case tokIdent:
+		switch t.text {
+		case "null":
+			return &literalNode{value: jsonconv.NewNull()}, nil
+		case "true":
+			return &literalNode{value: jsonconv.NewBool(true)}, nil
+		case "false":
+			return &literalNode{value: jsonconv.NewBool(false)}, nil
+		case "if":
+			return p.parseIf()
+		case "try":
+			return p.parseTry()
+		case "reduce":
+			return p.parseReduce()
+		case "then", "elif", "else", "end", "as", "and", "or", "catch":
+			return nil, syntaxError(t.offset, "unexpected keyword '"+t.text+"'")
+		}
+		return p.parseCall(t)
+	}
+	if t.kind == tokEOF {
+		return nil, syntaxError(t.offset, "unexpected end of expression")
+	}
+	return nil, syntaxError(t.offset, "unexpected '"+t.text+"'")
+}
+
+func (p *parser) parseString(t token) (node, error) {
+	if 1 == len(t.parts) {
+		return &literalNode{value: jsonconv.NewString(t.parts[0].literal)}, nil
+	}
+	s := &stringNode{}
+	for _, part := range t.parts {
+		if false == part.isExpr {
+			if "" != part.literal {
+				s.parts = append(s.parts, &literalNode{value: jsonconv.NewString(part.literal)})
+			}
+			continue
+		}
+		n, err := parse(part.expr, part.offset)
+		if err != nil {
+			return nil, err
+		}
+		s.parts = append(s.parts, n)
+	}
+	return s, nil
+}
+
+func (p *parser) parseObject() (node, error) {
+	obj := &objectNode{}
+	for false == p.isPunct("}") {
+		var entry objectEntry
+		t := p.next()
+		switch t.kind {
+		case tokIdent:
+			entry.key = &literalNode{value: jsonconv.NewString(t.text)}
+			entry.value = &indexNode{target: &identityNode{}, index: entry.key}
+		case tokVar:
+			entry.key = &literalNode{value: jsonconv.NewString(t.text)}
+			entry.value = &varNode{name: t.text, offset: t.offset}
+		case tokString:
+			key, err := p.parseString(t)
+			if err != nil {
+				return nil, err
+			}
+			entry.key = key
+			entry.value = &indexNode{target: &identityNode{}, index: key}
+		case tokNumber:
+			entry.key = &literalNode{value: jsonconv.NewString(t.text)}
+		default:
+			if t.kind == tokPunct && t.text == "(" {
+				key, err := p.parsePipe(false)
+				if err != nil {
+					return nil, err
+				}
+				if err = p.expectPunct(")"); err != nil {
+					return nil, err
+				}
+				entry.key = key
+				break
+			}
+			return nil, syntaxError(t.offset, "invalid object key '"+t.text+"'")
+		}
+
+		if p.isPunct(":") {
+			p.next()
+			value, err := p.parseObjectValue()
+			if err != nil {
+				return nil, err
+			}
+			entry.value = value
+		} else if nil == entry.value {
+			return nil, syntaxError(p.peek().offset, "':' expected in object construction")
+		}
+		obj.entries = append(obj.entries, entry)
+
+		if p.isPunct(",") {
+			p.next()
+		} else if false == p.isPunct("}") {
+			t := p.peek()
+			return nil, syntaxError(t.offset, "expected ',' or '}' but got '"+t.text+"'")
+		}
+	}
+	p.next()
+	return obj, nil
+}
+
+// parseObjectValue parses a value in object construction, where a comma
+// separates entries instead of building a stream.
+func (p *parser) parseObjectValue() (node, error) {
+	l, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("|") {
+		p.next()
+		r, err := p.parseObjectValue()
+		if err != nil {
+			return nil, err
+		}
+		return &pipeNode{l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parseIf() (node, error) {
+	cond, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expectKeyword("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	n := &ifNode{cond: cond, then: then}
+
+	switch t := p.next(); {
+	case t.kind == tokIdent && t.text == "elif":
+		if n.otherwise, err = p.parseIf(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case t.kind == tokIdent && t.text == "else":
+		if n.otherwise, err = p.parsePipe(false); err != nil {
+			return nil, err
+		}
+		if err = p.expectKeyword("end"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case t.kind == tokIdent && t.text == "end":
+		return n, nil
+	default:
+		return nil, syntaxError(t.offset, "expected 'elif', 'else' or 'end' but got '"+t.text+"'")
+	}
+}
+
+func (p *parser) parseTry() (node, error) {
+	body, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	n := &tryNode{body: body}
+	if p.isKeyword("catch") {
+		p.next()
+		if n.handler, err = p.parsePostfix(); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) parseReduce() (node, error) {
+	source, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expectKeyword("as"); err != nil {
+		return nil, err
+	}
+	v := p.next()
+	if v.kind != tokVar {
+		return nil, syntaxError(v.offset, "variable expected after 'as'")
+	}
+	if err = p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	init, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expectPunct(";"); err != nil {
+		return nil, err
+	}
+	update, err := p.parsePipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	return &reduceNode{source: source, name: v.text, init: init, update: update}, nil
+}
+
+func (p *parser) parseCall(t token) (node, error) {
+	call := &callNode{name: t.text, offset: t.offset}
+	if p.isPunct("(") {
+		p.next()
+		for {
+			arg, err := p.parsePipe(false)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.isPunct(";") {
+				p.next()
+				continue
+			}
+			if err = p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if _, exist := builtins[builtinKey(call.name, len(call.args))]; false == exist {
+		return nil, syntaxError(t.offset, call.name+"/"+strconv.Itoa(len(call.args))+" is not defined")
+	}
+	return call, nil
+}
@@ -22,6 +22,23 @@ var (
 	NotABoolError			= errors.New("target is not a bool")
 
 	ObjectNotFoundError = errors.New("object not found")
+	KeyConflictError		= errors.New("key conflicts with an existing value")
+
+	BSONFormatError			= errors.New("bson format error")
+
+	TooLargeError			= errors.New("json input too large")
+	TooDeepError			= errors.New("json nesting too deep")
+	StringTooLongError		= errors.New("json string too long")
+	TooManyKeysError		= errors.New("too many keys in json object")
+	ArrayTooLongError		= errors.New("too many elements in json array")
+	DuplicateKeyError		= errors.New("duplicated key in json object")
+	InvalidUTF8Error		= errors.New("invalid utf-8 in json string")
+
+	TimeFormatError			= errors.New("invalid time format")
+	CycleError				= errors.New("cyclic data structure")
+
+	TransactionConflictError	= errors.New("value modified by others during transaction")
+	TransactionDoneError		= errors.New("transaction already committed or rolled back")
 )
 
 type Filter int
@@ -44,13 +61,24 @@ type Option struct {
 	EnsureAscii	bool
 	FloatDigits	uint8
 	SortMode	Sort
-	// for sql2json
+	// for sql2json and NewFromInterface
 	TimeDigits	uint8
+	// layout of time values, e.g. time.RFC3339, or TimeUnix / TimeUnixMilli
+	// for numbers. Empty means "2006-01-02 15:04:05" with TimeDigits
+	// fractional digits.
+	TimeFormat	string
+	// time zone to convert time values to, nil to keep their own
+	TimeLocation	*time.Location
 	FilterMode	Filter
 	FilterList	[]string
 	// for JsonValue.MergeFrom()
 	OverrideArray	bool
 	OverrideObject	bool
+	// for BSON, use canonical instead of relaxed Extended JSON v2
+	CanonicalBSON	bool
+	// for JsonValue.Marshal() and sql2json, hide sensitive values. Struct
+	// fields may also be tagged like `json:"phone,redact"`, see RedactRule.
+	Redact	[]RedactRule
 }
 
 var dftOption = Option{
@@ -62,6 +90,7 @@ var dftOption = Option{
 	FilterMode:		Normal,
 	OverrideArray:	false,
 	OverrideObject:	false,
+	CanonicalBSON:	false,
 }
 
 func escapeJsonString(s string, ensureAscii bool) string {
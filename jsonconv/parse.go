@@ -0,0 +1,551 @@
+package jsonconv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DuplicateKeyPolicy tells what to do with repeated keys in an object
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyLast keeps the last value of a repeated key
+	DuplicateKeyLast DuplicateKeyPolicy = iota
+	// DuplicateKeyFirst keeps the first value of a repeated key
+	DuplicateKeyFirst
+	// DuplicateKeyReject fails with DuplicateKeyError
+	DuplicateKeyReject
+)
+
+// InvalidUTF8Policy tells what to do with invalid UTF-8 in strings
+type InvalidUTF8Policy int
+
+const (
+	// InvalidUTF8Reject fails with InvalidUTF8Error
+	InvalidUTF8Reject InvalidUTF8Policy = iota
+	// InvalidUTF8Replace replaces each invalid byte with U+FFFD
+	InvalidUTF8Replace
+)
+
+// ParseOptions limits what NewFromString accepts, for untrusted input. Zero
+// values mean no limit, and the zero ParseOptions is what NewFromString uses
+// by default.
+type ParseOptions struct {
+	// MaxBytes limits the length of the whole input
+	MaxBytes int
+	// MaxDepth limits the nesting of objects and arrays, e.g. [[1]] has a
+	// depth of 2
+	MaxDepth int
+	// MaxStringLength limits the length of each string or key, in bytes
+	// before unescaping
+	MaxStringLength int
+	// MaxKeys limits the number of members in each object
+	MaxKeys int
+	// MaxArrayLength limits the number of elements in each array
+	MaxArrayLength int
+
+	DuplicateKeys DuplicateKeyPolicy
+	InvalidUTF8   InvalidUTF8Policy
+}
+
+// ParseError is returned by NewFromString when the input violates
+// ParseOptions. Err is one of TooLargeError, TooDeepError,
+// StringTooLongError, TooManyKeysError, ArrayTooLongError, DuplicateKeyError
+// or InvalidUTF8Error.
+type ParseError struct {
+	// byte offset in the input where the problem is found
+	Offset int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// SyntaxError is returned by NewFromString for input which is not valid
+// JSON text as defined by RFC 8259. It matches JsonFormatError with
+// errors.Is.
+type SyntaxError struct {
+	// byte offset in the input where the problem is found
+	Offset int
+	// 1-based line and column (in bytes) of Offset
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("json syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return JsonFormatError
+}
+
+func newSyntaxError(data []byte, offset int, msg string) *SyntaxError {
+	e := &SyntaxError{Offset: offset, Line: 1, Column: 1, Msg: msg}
+	for i := 0; i < offset && i < len(data); i++ {
+		if '\n' == data[i] {
+			e.Line++
+			e.Column = 1
+		} else {
+			e.Column++
+		}
+	}
+	return e
+}
+
+// quoteChar describes the character at offset for error messages
+func quoteChar(data []byte, offset int) string {
+	if offset >= len(data) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRune(data[offset:])
+	if utf8.RuneError == r || r < 0x20 {
+		return fmt.Sprintf("%#02x", data[offset])
+	}
+	return "'" + string(r) + "'"
+}
+
+func expectMsg(c byte, data []byte, offset int) string {
+	return "expected '" + string(c) + "' but found " + quoteChar(data, offset)
+}
+
+func closingMsg(container *JsonValue, c byte) string {
+	if container.valueType == Object {
+		return "expected ',' or '}' after object member but found " + quoteChar([]byte{c}, 0)
+	}
+	return "expected ',' or ']' after array element but found " + quoteChar([]byte{c}, 0)
+}
+
+// parseFrame is an object or array being filled
+type parseFrame struct {
+	v   *JsonValue
+	key string // pending key for objects
+	// offsets of the pending key and value, for errors
+	keyOffset   int
+	valueOffset int
+}
+
+// jsonParser decodes JSON without recursion, so that the nesting depth is
+// only bounded by memory and MaxDepth
+type jsonParser struct {
+	data  []byte
+	pos   int
+	opt   ParseOptions
+	stack []*parseFrame
+}
+
+func parseWithOptions(s string, opt ParseOptions) (*JsonValue, error) {
+	if opt.MaxBytes > 0 && len(s) > opt.MaxBytes {
+		return nil, &ParseError{Offset: opt.MaxBytes, Err: TooLargeError}
+	}
+	p := &jsonParser{data: []byte(s), opt: opt}
+	return p.parse()
+}
+
+func (p *jsonParser) fail(offset int, err error) error {
+	return &ParseError{Offset: offset, Err: err}
+}
+
+func (p *jsonParser) syntaxError(offset int, msg string) error {
+	return newSyntaxError(p.data, offset, msg)
+}
+
+func (p *jsonParser) skipSpaces() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// expect consumes c after optional spaces
+func (p *jsonParser) expect(c byte) error {
+	p.skipSpaces()
+	if p.pos >= len(p.data) || p.data[p.pos] != c {
+		return p.syntaxError(p.pos, expectMsg(c, p.data, p.pos))
+	}
+	p.pos++
+	return nil
+}
+
+func (p *jsonParser) push(v *JsonValue) error {
+	if p.opt.MaxDepth > 0 && len(p.stack) >= p.opt.MaxDepth {
+		return p.fail(p.pos, TooDeepError)
+	}
+	p.stack = append(p.stack, &parseFrame{v: v})
+	p.pos++
+	return nil
+}
+
+// readKey reads an object key and the following colon into the top frame
+func (p *jsonParser) readKey() error {
+	p.skipSpaces()
+	top := p.stack[len(p.stack)-1]
+	top.keyOffset = p.pos
+	if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+		return p.syntaxError(p.pos, expectMsg('"', p.data, p.pos))
+	}
+	key, err := p.readString()
+	if err != nil {
+		return err
+	}
+	top.key = key
+	return p.expect(':')
+}
+
+func (p *jsonParser) parse() (*JsonValue, error) {
+	for {
+		// read a value, or open a container
+		p.skipSpaces()
+		if p.pos >= len(p.data) {
+			return nil, p.syntaxError(p.pos, "unexpected end of input")
+		}
+		if l := len(p.stack); l > 0 {
+			p.stack[l-1].valueOffset = p.pos
+		}
+
+		var v *JsonValue
+		var err error
+		switch c := p.data[p.pos]; {
+		case '{' == c:
+			obj := NewObject()
+			if err = p.push(obj); err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if p.pos < len(p.data) && '}' == p.data[p.pos] {
+				p.pos++
+				p.stack = p.stack[:len(p.stack)-1]
+				v = obj
+				break
+			}
+			if err = p.readKey(); err != nil {
+				return nil, err
+			}
+			continue
+
+		case '[' == c:
+			arr := NewArray()
+			if err = p.push(arr); err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if p.pos < len(p.data) && ']' == p.data[p.pos] {
+				p.pos++
+				p.stack = p.stack[:len(p.stack)-1]
+				v = arr
+				break
+			}
+			continue
+
+		case '"' == c:
+			var s string
+			if s, err = p.readString(); err != nil {
+				return nil, err
+			}
+			v = NewString(s)
+
+		case '-' == c || (c >= '0' && c <= '9'):
+			if v, err = p.readNumber(); err != nil {
+				return nil, err
+			}
+
+		default:
+			if v, err = p.readLiteral(); err != nil {
+				return nil, err
+			}
+		}
+
+		// attach the value, and close finished containers
+		for {
+			if 0 == len(p.stack) {
+				p.skipSpaces()
+				if p.pos < len(p.data) {
+					return nil, p.syntaxError(p.pos, "unexpected data after top-level value")
+				}
+				return v, nil
+			}
+
+			top := p.stack[len(p.stack)-1]
+			if err = p.attach(top, v); err != nil {
+				return nil, err
+			}
+
+			p.skipSpaces()
+			if p.pos >= len(p.data) {
+				return nil, p.syntaxError(p.pos, "unexpected end of input")
+			}
+			c := p.data[p.pos]
+			if ',' == c {
+				p.pos++
+				if top.v.valueType == Object {
+					if err = p.readKey(); err != nil {
+						return nil, err
+					}
+				}
+				break
+			}
+			if (top.v.valueType == Object && '}' == c) || (top.v.valueType == Array && ']' == c) {
+				p.pos++
+				p.stack = p.stack[:len(p.stack)-1]
+				v = top.v
+				continue
+			}
+			return nil, p.syntaxError(p.pos, closingMsg(top.v, p.data[p.pos]))
+		}
+	}
+}
+
+func (p *jsonParser) attach(top *parseFrame, v *JsonValue) error {
+	if top.v.valueType == Array {
+		if p.opt.MaxArrayLength > 0 && len(top.v.arrChildren) >= p.opt.MaxArrayLength {
+			return p.fail(top.valueOffset, ArrayTooLongError)
+		}
+		top.v.arrChildren = append(top.v.arrChildren, v)
+		return nil
+	}
+
+	if _, exist := top.v.objChildren[top.key]; exist {
+		switch p.opt.DuplicateKeys {
+		case DuplicateKeyReject:
+			return p.fail(top.keyOffset, DuplicateKeyError)
+		case DuplicateKeyFirst:
+			return nil
+		}
+	} else if p.opt.MaxKeys > 0 && len(top.v.objChildren) >= p.opt.MaxKeys {
+		return p.fail(top.keyOffset, TooManyKeysError)
+	}
+	top.v.objChildren[top.key] = v
+	return nil
+}
+
+func (p *jsonParser) readLiteral() (*JsonValue, error) {
+	rest := p.data[p.pos:]
+	for _, lit := range []string{"true", "false", "null"} {
+		if len(rest) >= len(lit) && string(rest[:len(lit)]) == lit {
+			p.pos += len(lit)
+			switch lit {
+			case "true":
+				return NewBool(true), nil
+			case "false":
+				return NewBool(false), nil
+			default:
+				return NewNull(), nil
+			}
+		}
+	}
+	return nil, p.syntaxError(p.pos, "invalid character "+quoteChar(p.data, p.pos)+" looking for beginning of value")
+}
+
+func (p *jsonParser) readNumber() (*JsonValue, error) {
+	start := p.pos
+	data := p.data
+	i := p.pos
+	digits := func() int {
+		n := 0
+		for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+
+	if '-' == data[i] {
+		i++
+	}
+	if i < len(data) && '0' == data[i] {
+		i++
+		if i < len(data) && data[i] >= '0' && data[i] <= '9' {
+			return nil, p.syntaxError(i-1, "invalid number: leading zero")
+		}
+	} else if 0 == digits() {
+		return nil, p.syntaxError(i, "invalid number: digit expected")
+	}
+	isFloat := false
+	if i < len(data) && '.' == data[i] {
+		i++
+		isFloat = true
+		if 0 == digits() {
+			return nil, p.syntaxError(i, "invalid number: digit expected after decimal point")
+		}
+	}
+	if i < len(data) && ('e' == data[i] || 'E' == data[i]) {
+		i++
+		isFloat = true
+		if i < len(data) && ('+' == data[i] || '-' == data[i]) {
+			i++
+		}
+		if 0 == digits() {
+			return nil, p.syntaxError(i, "invalid number: digit expected in exponent")
+		}
+	}
+	v, ok := numberFromLiteral(string(data[start:i]), isFloat)
+	if false == ok {
+		return nil, p.syntaxError(start, "number out of range")
+	}
+	p.pos = i
+	return v, nil
+}
+
+// numberFromLiteral builds a number from a valid JSON number literal. It
+// returns false if the value does not fit in a float64.
+func numberFromLiteral(s string, isFloat bool) (*JsonValue, bool) {
+	if false == isFloat {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			v := NewInt64(i)
+			v.mustUnsigned = false
+			v.mustSigned = i < 0
+			return v, true
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return NewUint64(u), true
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && math.IsInf(f, 0) {
+		return nil, false
+	}
+	return NewFloat(f), true
+}
+
+// readString reads a quoted string at the current position
+func (p *jsonParser) readString() (string, error) {
+	start := p.pos
+	data := p.data
+	i := p.pos + 1
+
+	// fast path without escapes
+	for i < len(data) {
+		c := data[i]
+		if '"' == c {
+			if err := p.checkStringLength(start, i); err != nil {
+				return "", err
+			}
+			s := data[start+1 : i]
+			if utf8.Valid(s) {
+				p.pos = i + 1
+				return string(s), nil
+			}
+			i = start + 1
+			break
+		}
+		if '\\' == c || c < 0x20 || c >= utf8.RuneSelf {
+			break
+		}
+		i++
+	}
+
+	b := strings.Builder{}
+	b.Write(data[start+1 : i])
+	for {
+		if i >= len(data) {
+			return "", p.syntaxError(start, "unterminated string")
+		}
+		c := data[i]
+		switch {
+		case '"' == c:
+			if err := p.checkStringLength(start, i); err != nil {
+				return "", err
+			}
+			p.pos = i + 1
+			return b.String(), nil
+
+		case c < 0x20:
+			return "", p.syntaxError(i, fmt.Sprintf("invalid control character %#02x in string", c))
+
+		case '\\' == c:
+			if i+1 >= len(data) {
+				return "", p.syntaxError(i, "unterminated string")
+			}
+			switch e := data[i+1]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				r, n, ok := decodeUnicodeEscape(data[i:])
+				if false == ok {
+					return "", p.syntaxError(i, "invalid \\u escape in string")
+				}
+				b.WriteRune(r)
+				i += n
+				continue
+			default:
+				return "", p.syntaxError(i, "invalid escape "+quoteChar(data, i+1)+" in string")
+			}
+			i += 2
+
+		case c < utf8.RuneSelf:
+			b.WriteByte(c)
+			i++
+
+		default:
+			r, size := utf8.DecodeRune(data[i:])
+			if utf8.RuneError == r && 1 == size {
+				if p.opt.InvalidUTF8 != InvalidUTF8Replace {
+					return "", p.fail(i, InvalidUTF8Error)
+				}
+			}
+			b.WriteRune(r)
+			i += size
+		}
+	}
+}
+
+func (p *jsonParser) checkStringLength(start, end int) error {
+	if p.opt.MaxStringLength > 0 && end-start-1 > p.opt.MaxStringLength {
+		return p.fail(start, StringTooLongError)
+	}
+	return nil
+}
+
+// decodeUnicodeEscape decodes "\uXXXX" at the beginning of b, combined with a
+// following low surrogate escape if any. Unpaired surrogates give U+FFFD.
+// It returns the rune and the number of bytes consumed.
+func decodeUnicodeEscape(b []byte) (rune, int, bool) {
+	hex4 := func(b []byte) (rune, bool) {
+		if len(b) < 6 || '\\' != b[0] || 'u' != b[1] {
+			return 0, false
+		}
+		u, err := strconv.ParseUint(string(b[2:6]), 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		return rune(u), true
+	}
+
+	r, ok := hex4(b)
+	if false == ok {
+		return 0, 0, false
+	}
+	if false == utf16.IsSurrogate(r) {
+		return r, 6, true
+	}
+	if low, ok := hex4(b[6:]); ok {
+		if combined := utf16.DecodeRune(r, low); combined != utf8.RuneError {
+			return combined, 12, true
+		}
+	}
+	return utf8.RuneError, 6, true
+}
@@ -0,0 +1,124 @@
+package jsonconv
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	cases := []struct {
+		input  string
+		opt    ParseOptions
+		err    error
+		offset int
+	}{
+		{`[1, 2, 3]`, ParseOptions{MaxBytes: 5}, TooLargeError, 5},
+		{`{"a": [[1]]}`, ParseOptions{MaxDepth: 2}, TooDeepError, 7},
+		{`{"a": "hello"}`, ParseOptions{MaxStringLength: 4}, StringTooLongError, 6},
+		{`{"a": 1, "b": 2, "c": 3}`, ParseOptions{MaxKeys: 2}, TooManyKeysError, 17},
+		{`[1, 2, 3]`, ParseOptions{MaxArrayLength: 2}, ArrayTooLongError, 7},
+		{`{"a": 1, "a": 2}`, ParseOptions{DuplicateKeys: DuplicateKeyReject}, DuplicateKeyError, 9},
+		{"[\"a\xffb\"]", ParseOptions{}, InvalidUTF8Error, 3},
+	}
+	for _, c := range cases {
+		_, err := NewFromString(c.input, c.opt)
+		pErr, ok := err.(*ParseError)
+		if false == ok {
+			t.Errorf("%s: expected ParseError, got %v", c.input, err)
+			continue
+		}
+		if false == errors.Is(err, c.err) || pErr.Offset != c.offset {
+			t.Errorf("%s: expected %v at %d, got %v", c.input, c.err, c.offset, err)
+		}
+	}
+
+	v, err := NewFromString(`{"a": 1, "a": 2}`, ParseOptions{DuplicateKeys: DuplicateKeyFirst})
+	if err != nil || v.At("a").IntOr(0) != 1 {
+		t.Errorf("unexpected first key result: %v", err)
+	}
+	v, err = NewFromString("[\"a\xffb\"]", ParseOptions{InvalidUTF8: InvalidUTF8Replace})
+	if err != nil || v.At(0).StrOr("") != "a�b" {
+		t.Errorf("unexpected replaced string: %v", err)
+	}
+
+	// deep nesting does not overflow the stack
+	depth := 100000
+	deep := strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	if _, err := NewFromString(deep, ParseOptions{}); err != nil {
+		t.Errorf("unexpected error for deep input: %v", err)
+	}
+	if _, err := NewFromString(deep, ParseOptions{MaxDepth: 64}); false == errors.Is(err, TooDeepError) {
+		t.Errorf("expected TooDeepError, got %v", err)
+	}
+
+	v, err = NewFromString(`{"s": "a\"\\\/\b\f\n\r\té😀", "n": [-0, 1.5e2, 18446744073709551615]}`, ParseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := v.At("s").StrOr(""); s != "a\"\\/\b\f\n\r\té😀" {
+		t.Errorf("unexpected string: %q", s)
+	}
+	if v.At("n", 1).FloatOr(0) != 150 || v.At("n", 2).Uint64Or(0) != 18446744073709551615 {
+		t.Errorf("unexpected numbers: %s", marshalSorted(v))
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	cases := []struct {
+		input        string
+		line, column int
+		msg          string
+	}{
+		{`{"a": 1,}`, 1, 9, `expected '"' but found '}'`},
+		{"[1,\n  2\n  x]", 3, 3, `expected ',' or ']' after array element but found 'x'`},
+		{"[1] x", 1, 5, "unexpected data after top-level value"},
+		{`[012]`, 1, 2, "invalid number: leading zero"},
+		{`[1, -1e400]`, 1, 5, "number out of range"},
+		{`["a\qb"]`, 1, 4, `invalid escape 'q' in string`},
+		{"[\"a\tb\"]", 1, 4, "invalid control character 0x09 in string"},
+		{"", 1, 1, "unexpected end of input"},
+	}
+	for _, c := range cases {
+		_, err := NewFromString(c.input)
+		sErr, ok := err.(*SyntaxError)
+		if false == ok {
+			t.Errorf("%q: expected SyntaxError, got %v", c.input, err)
+			continue
+		}
+		if sErr.Line != c.line || sErr.Column != c.column || sErr.Msg != c.msg {
+			t.Errorf("%q: unexpected error %+v", c.input, sErr)
+		}
+		if false == errors.Is(err, JsonFormatError) {
+			t.Errorf("%q: SyntaxError should match JsonFormatError", c.input)
+		}
+	}
+}
+
+func TestJSONTestSuite(t *testing.T) {
+	dir := filepath.Join("testdata", "JSONTestSuite", "test_parsing")
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	for _, f := range files {
+		name := f.Name()
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		_, err = NewFromString(string(b))
+		switch name[0] {
+		case 'y':
+			if err != nil {
+				t.Errorf("%s should be accepted: %v", name, err)
+			}
+		case 'n':
+			if err == nil {
+				t.Errorf("%s should be rejected", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package jsonconv
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Path is a list of object keys (string) and array indexes (integers), as
+// accepted by Get and its variants.
+type Path []interface{}
+
+// String returns the path in a readable form like `a.b[0]["key.with.dot"]`
+func (p Path) String() string {
+	b := bytes.Buffer{}
+	for i, seg := range p {
+		switch seg := seg.(type) {
+		case string:
+			if isPlainPathKey(seg) {
+				if i > 0 {
+					b.WriteByte('.')
+				}
+				b.WriteString(seg)
+			} else {
+				b.WriteByte('[')
+				b.WriteString(strconv.Quote(seg))
+				b.WriteByte(']')
+			}
+		default:
+			if index, ok := intFromInterface(seg); ok {
+				b.WriteByte('[')
+				b.WriteString(strconv.Itoa(index))
+				b.WriteByte(']')
+			} else {
+				b.WriteString(fmt.Sprintf("[%v]", seg))
+			}
+		}
+	}
+	return b.String()
+}
+
+func isPlainPathKey(s string) bool {
+	if "" == s {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '$':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// PathError describes a failed access in a deep path. It wraps one of the
+// sentinel errors such as ObjectNotFoundError, so errors.Is still works.
+type PathError struct {
+	// Path is the full requested path
+	Path Path
+	// Index is the position of the failing segment in Path
+	Index int
+	// Segment is the failing key or array index
+	Segment interface{}
+	// Expected is the value type required at the failing segment, Unknown if
+	// any type is acceptable
+	Expected ValueType
+	// Actual is the value type found, NotExist if nothing was there
+	Actual ValueType
+	// Err is the underlying sentinel error
+	Err error
+}
+
+func (e *PathError) Error() string {
+	path := "<root>"
+	if e.Index >= 0 && e.Index < len(e.Path) {
+		path = e.Path[:e.Index+1].String()
+	}
+	if Unknown == e.Expected {
+		return fmt.Sprintf("%v at %s", e.Err, path)
+	}
+	return fmt.Sprintf("%v at %s: expected %s, got %s", e.Err, path, typeName(e.Expected), typeName(e.Actual))
+}
+
+// Unwrap returns the underlying sentinel error
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+func typeName(t ValueType) string {
+	if NotExist == t {
+		return "nothing"
+	}
+	v := JsonValue{valueType: t}
+	return v.TypeString()
+}
+
+func intFromInterface(v interface{}) (int, bool) {
+	switch v.(type) {
+	case int8, int16, int32, int64, int:
+		return int(reflect.ValueOf(v).Int()), true
+	case uint8, uint16, uint32, uint64, uint:
+		return int(reflect.ValueOf(v).Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+// getByPath resolves path step by step, reporting the failing segment in a
+// *PathError.
+func (obj *JsonValue) getByPath(path Path) (*JsonValue, error) {
+	curr := obj
+	for i, seg := range path {
+		if key, ok := seg.(string); ok {
+			if curr.valueType != Object {
+				return nil, &PathError{Path: path, Index: i, Segment: seg, Expected: Object, Actual: curr.valueType, Err: NotAnObjectError}
+			}
+			child, exist := curr.objChildren[key]
+			if false == exist {
+				return nil, &PathError{Path: path, Index: i, Segment: seg, Expected: Unknown, Actual: NotExist, Err: ObjectNotFoundError}
+			}
+			curr = child
+			continue
+		}
+
+		index, ok := intFromInterface(seg)
+		if false == ok {
+			return nil, &PathError{Path: path, Index: i, Segment: seg, Expected: Unknown, Actual: curr.valueType, Err: ParaError}
+		}
+		if curr.valueType != Array {
+			return nil, &PathError{Path: path, Index: i, Segment: seg, Expected: Array, Actual: curr.valueType, Err: NotAnArrayError}
+		}
+		if index < 0 || index >= len(curr.arrChildren) {
+			return nil, &PathError{Path: path, Index: i, Segment: seg, Expected: Unknown, Actual: NotExist, Err: IndexOutOfBoundsError}
+		}
+		curr = curr.arrChildren[index]
+	}
+	return curr, nil
+}
+
+// getTyped gets the child at path for GetXxx functions, and reads it with
+// read. Coercion flags given after the keys are passed to read, and an error
+// from read is reported as a PathError expecting the given type.
+func (obj *JsonValue) getTyped(expected ValueType, first interface{}, keys []interface{}, read func(v *JsonValue, c Coercion) error) error {
+	var c Coercion
+	for len(keys) > 0 {
+		flags, ok := keys[len(keys)-1].(Coercion)
+		if false == ok {
+			break
+		}
+		c |= flags
+		keys = keys[:len(keys)-1]
+	}
+	path := append(Path{first}, keys...)
+	child, err := obj.getByPath(path)
+	if err != nil {
+		return err
+	}
+	if err := read(child, c); err != nil {
+		last := len(path) - 1
+		return &PathError{Path: path, Index: last, Segment: path[last], Expected: expected, Actual: child.valueType, Err: err}
+	}
+	return nil
+}
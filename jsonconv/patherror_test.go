@@ -0,0 +1,48 @@
+package jsonconv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPathError(t *testing.T) {
+	obj, _ := NewFromString(`{"a": {"b": [1, {"c": "x"}]}, "s": "str"}`)
+
+	_, err := obj.Get("a", "b", 1, "d")
+	pe, ok := err.(*PathError)
+	if false == ok {
+		t.Errorf("expected *PathError, got %v", err)
+		return
+	}
+	if false == errors.Is(err, ObjectNotFoundError) {
+		t.Errorf("errors.Is should match ObjectNotFoundError")
+	}
+	if pe.Index != 3 || pe.Segment != "d" || pe.Actual != NotExist {
+		t.Errorf("unexpected error detail: %+v", pe)
+	}
+	if s := err.Error(); s != "object not found at a.b[1].d" {
+		t.Errorf("unexpected message: %s", s)
+	}
+
+	_, err = obj.GetInt("a", "b", 1, "c")
+	if false == errors.Is(err, NotANumberError) {
+		t.Errorf("expected NotANumberError, got %v", err)
+	}
+	if s := err.Error(); s != "target is not a number at a.b[1].c: expected number, got string" {
+		t.Errorf("unexpected message: %s", s)
+	}
+
+	_, err = obj.Get("s", 0)
+	if pe, ok = err.(*PathError); false == ok || pe.Expected != Array || pe.Actual != String {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = obj.Get("a", "b", uint(5))
+	if false == errors.Is(err, IndexOutOfBoundsError) {
+		t.Errorf("expected IndexOutOfBoundsError, got %v", err)
+	}
+
+	if s := (Path{"a", 0, "key.with dot"}).String(); s != `a[0]["key.with dot"]` {
+		t.Errorf("unexpected path string: %s", s)
+	}
+}
@@ -0,0 +1,229 @@
+package jsonconv
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RedactStrategy tells how a sensitive value is hidden
+type RedactStrategy int
+
+const (
+	// RedactMask replaces the value with a fixed mask, "******" by default
+	RedactMask RedactStrategy = iota
+	// RedactKeepLast masks all but the last KeepLast characters, with one
+	// "*" for each masked character
+	RedactKeepLast
+	// RedactHash replaces the value with "sha256:" and the hex digest of
+	// Salt followed by the value, so that equal values can still be
+	// correlated
+	RedactHash
+	// RedactDrop removes the value, together with its key
+	RedactDrop
+)
+
+// RedactRule selects values to hide by Key and/or Path. A rule with both
+// matches only values satisfying both, and a rule with neither matches
+// nothing.
+//
+// Objects and arrays matched by a rule are redacted as a whole. Other values
+// are redacted in their text form, i.e. the string itself, or the JSON form
+// of numbers and booleans. Null values are left untouched.
+type RedactRule struct {
+	// Key matches the last key of the value, case-insensitively
+	Key string
+	// Path matches the full path, with the same syntax as SyncValue.Watch,
+	// e.g. "users.*.phone" or "**.token"
+	Path     string
+	Strategy RedactStrategy
+	// KeepLast is the number of trailing characters kept by RedactKeepLast
+	KeepLast int
+	// Mask replaces the value for RedactMask, "******" if empty
+	Mask string
+	// Salt is prepended to the value before hashing with RedactHash
+	Salt string
+}
+
+const defaultRedactMask = "******"
+
+func (r *RedactRule) match(path Path, pattern []string) bool {
+	if "" == r.Key && "" == r.Path {
+		return false
+	}
+	if "" != r.Key {
+		if 0 == len(path) {
+			return false
+		}
+		key, ok := path[len(path)-1].(string)
+		if false == ok || false == strings.EqualFold(key, r.Key) {
+			return false
+		}
+	}
+	if "" != r.Path && false == matchPathPattern(pattern, path) {
+		return false
+	}
+	return true
+}
+
+// redactor applies a list of rules with pre-split path patterns
+type redactor struct {
+	rules    []RedactRule
+	patterns [][]string
+}
+
+func newRedactor(rules []RedactRule) *redactor {
+	if 0 == len(rules) {
+		return nil
+	}
+	r := &redactor{rules: rules, patterns: make([][]string, len(rules))}
+	for i, rule := range rules {
+		if "" != rule.Path {
+			r.patterns[i] = splitEscapedFlattenKey(rule.Path, ".")
+		}
+	}
+	return r
+}
+
+// find returns the first rule matching path
+func (r *redactor) find(path Path) *RedactRule {
+	for i := range r.rules {
+		if r.rules[i].match(path, r.patterns[i]) {
+			return &r.rules[i]
+		}
+	}
+	return nil
+}
+
+// apply returns a copy of v with matching values redacted, sharing untouched
+// subtrees with v. A nil return means v itself is dropped.
+func (r *redactor) apply(path Path, v *JsonValue) *JsonValue {
+	if rule := r.find(path); nil != rule {
+		return rule.redact(v)
+	}
+
+	switch v.valueType {
+	case Object:
+		var ret *JsonValue
+		for k, child := range v.objChildren {
+			redacted := r.apply(childPath(path, k), child)
+			if redacted == child {
+				continue
+			}
+			if nil == ret {
+				ret = v.shallowCopy()
+			}
+			if nil == redacted {
+				delete(ret.objChildren, k)
+			} else {
+				ret.objChildren[k] = redacted
+			}
+		}
+		if nil == ret {
+			return v
+		}
+		return ret
+
+	case Array:
+		changed := false
+		children := make([]*JsonValue, 0, len(v.arrChildren))
+		for i, child := range v.arrChildren {
+			redacted := r.apply(childPath(path, i), child)
+			if redacted != child {
+				changed = true
+			}
+			if nil != redacted {
+				children = append(children, redacted)
+			}
+		}
+		if false == changed {
+			return v
+		}
+		ret := NewArray()
+		ret.arrChildren = children
+		return ret
+
+	default:
+		return v
+	}
+}
+
+// redact hides v according to the rule, returning nil for RedactDrop
+func (r *RedactRule) redact(v *JsonValue) *JsonValue {
+	if RedactDrop == r.Strategy {
+		return nil
+	}
+	if v.IsNull() {
+		return v
+	}
+	return NewString(r.redactString(redactText(v)))
+}
+
+func (r *RedactRule) redactString(s string) string {
+	switch r.Strategy {
+	case RedactKeepLast:
+		n := utf8.RuneCountInString(s)
+		keep := r.KeepLast
+		if keep < 0 {
+			keep = 0
+		}
+		if keep >= n {
+			return s
+		}
+		runes := []rune(s)
+		return strings.Repeat("*", n-keep) + string(runes[n-keep:])
+	case RedactHash:
+		sum := sha256.Sum256([]byte(r.Salt + s))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	default:
+		if "" != r.Mask {
+			return r.Mask
+		}
+		return defaultRedactMask
+	}
+}
+
+func redactText(v *JsonValue) string {
+	if v.IsString() {
+		return v.stringValue
+	}
+	s, _ := v.Marshal(Option{ShowNull: true, SortMode: DictAsc})
+	return s
+}
+
+// ====================
+// struct tags
+
+// redactRuleFromTag reads the redact option of a json tag: "redact" for a
+// mask, or "redact=last4", "redact=hash" and "redact=drop".
+func redactRuleFromTag(field *reflect.StructField) (*RedactRule, bool) {
+	opts := strings.Split(field.Tag.Get("json"), ",")
+	for _, o := range opts[1:] {
+		o = strings.TrimSpace(o)
+		if "redact" == o {
+			return &RedactRule{Strategy: RedactMask}, true
+		}
+		if false == strings.HasPrefix(o, "redact=") {
+			continue
+		}
+		switch arg := o[len("redact="):]; {
+		case "mask" == arg:
+			return &RedactRule{Strategy: RedactMask}, true
+		case "hash" == arg:
+			return &RedactRule{Strategy: RedactHash}, true
+		case "drop" == arg:
+			return &RedactRule{Strategy: RedactDrop}, true
+		case strings.HasPrefix(arg, "last"):
+			n, err := strconv.Atoi(arg[len("last"):])
+			if err == nil {
+				return &RedactRule{Strategy: RedactKeepLast, KeepLast: n}, true
+			}
+		}
+		// unknown argument, hide the value anyway
+		return &RedactRule{Strategy: RedactMask}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,65 @@
+package jsonconv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactMarshal(t *testing.T) {
+	v, _ := NewFromString(`{
+		"Phone": "13800138000",
+		"token": "abc",
+		"users": [{"name": "bob", "id": 12345}, {"name": "al", "id": 7}],
+		"auth": {"password": "secret", "user": "x"}
+	}`)
+
+	opt := Option{
+		SortMode: DictAsc,
+		Redact: []RedactRule{
+			{Key: "phone", Strategy: RedactKeepLast, KeepLast: 4},
+			{Key: "TOKEN", Strategy: RedactHash},
+			{Path: "users.*.id", Strategy: RedactMask, Mask: "?"},
+			{Path: "**.password", Strategy: RedactDrop},
+		},
+	}
+	s, err := v.Marshal(opt)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	expected := `{"Phone":"*******8000","auth":{"user":"x"},` +
+		`"token":"sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",` +
+		`"users":[{"id":"?","name":"bob"},{"id":"?","name":"al"}]}`
+	if s != expected {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	// the original value is not modified
+	if p, _ := v.GetString("Phone"); p != "13800138000" {
+		t.Errorf("original value modified: %s", p)
+	}
+	if _, err := v.Get("auth", "password"); err != nil {
+		t.Errorf("original value modified: %v", err)
+	}
+}
+
+func TestRedactSqlToJson(t *testing.T) {
+	type row struct {
+		ID     int64  `db:"id"`
+		Phone  string `json:"phone,redact=last4"`
+		Email  string `json:"email,redact"`
+		Secret string `json:"secret,redact=drop"`
+		Token  string `db:"token"`
+	}
+	r := row{ID: 1, Phone: "13800138000", Email: "a@b.c", Secret: "x", Token: "t0k3n"}
+
+	s, err := SqlToJson(r, Option{Redact: []RedactRule{{Key: "Token"}}})
+	if err != nil {
+		t.Fatalf("SqlToJson error: %v", err)
+	}
+	if s != `{"id":1,"phone":"*******8000","email":"******","token":"******"}` {
+		t.Errorf("unexpected result: %s", s)
+	}
+	if strings.Contains(s, "secret") {
+		t.Errorf("dropped field found: %s", s)
+	}
+}
@@ -0,0 +1,478 @@
+package jsonconv
+
+import (
+	"bytes"
+	"go/format"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// strings with at most this many distinct values are enum candidates
+	schemaEnumMaxValues = 8
+	// ... if each value appears at least this many times on average
+	schemaEnumMinRepeat = 2
+)
+
+// schemaStats accumulates what has been seen at one position of the samples
+type schemaStats struct {
+	count int
+	types map[string]bool
+
+	// strings
+	strCount  int
+	strValues map[string]bool // nil once there are too many
+	allTimes  bool
+
+	// numbers
+	min, max float64
+
+	// objects
+	objCount   int
+	properties map[string]*schemaStats
+	presence   map[string]int
+
+	// arrays
+	items *schemaStats
+}
+
+func newSchemaStats() *schemaStats {
+	return &schemaStats{
+		types:     make(map[string]bool),
+		strValues: make(map[string]bool),
+		allTimes:  true,
+	}
+}
+
+func (s *schemaStats) add(v *JsonValue) {
+	s.count++
+	switch v.valueType {
+	case Null:
+		s.types["null"] = true
+
+	case Boolean:
+		s.types["boolean"] = true
+
+	case Number:
+		f := v.floatValue
+		if numberIsUint == v.numberKind() {
+			f = float64(v.uintValue)
+		}
+		if false == s.types["integer"] && false == s.types["number"] {
+			s.min, s.max = f, f
+		} else {
+			s.min, s.max = math.Min(s.min, f), math.Max(s.max, f)
+		}
+		if numberIsFloat == v.numberKind() && f != math.Trunc(f) {
+			s.types["number"] = true
+		} else {
+			s.types["integer"] = true
+		}
+
+	case String:
+		s.types["string"] = true
+		s.strCount++
+		if nil != s.strValues {
+			s.strValues[v.stringValue] = true
+			if len(s.strValues) > schemaEnumMaxValues {
+				s.strValues = nil
+			}
+		}
+		if s.allTimes {
+			if _, err := time.Parse(time.RFC3339Nano, v.stringValue); err != nil {
+				s.allTimes = false
+			}
+		}
+
+	case Object:
+		s.types["object"] = true
+		s.objCount++
+		if nil == s.properties {
+			s.properties = make(map[string]*schemaStats)
+			s.presence = make(map[string]int)
+		}
+		for k, child := range v.objChildren {
+			p, exist := s.properties[k]
+			if false == exist {
+				p = newSchemaStats()
+				s.properties[k] = p
+			}
+			p.add(child)
+			s.presence[k]++
+		}
+
+	case Array:
+		s.types["array"] = true
+		if nil == s.items {
+			s.items = newSchemaStats()
+		}
+		for _, child := range v.arrChildren {
+			s.items.add(child)
+		}
+	}
+}
+
+// typeList returns the JSON Schema type names, "integer" being merged into
+// "number" if both are seen
+func (s *schemaStats) typeList() []string {
+	ret := make([]string, 0, len(s.types))
+	for _, t := range []string{"object", "array", "string", "number", "integer", "boolean", "null"} {
+		if false == s.types[t] {
+			continue
+		}
+		if "integer" == t && s.types["number"] {
+			continue
+		}
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+func (s *schemaStats) schema() *JsonValue {
+	ret := NewObject()
+	types := s.typeList()
+	switch len(types) {
+	case 0:
+		// nothing seen, e.g. items of empty arrays: any value
+		return ret
+	case 1:
+		ret.objChildren["type"] = NewString(types[0])
+	default:
+		arr := NewArray()
+		for _, t := range types {
+			arr.arrChildren = append(arr.arrChildren, NewString(t))
+		}
+		ret.objChildren["type"] = arr
+	}
+
+	if s.types["string"] {
+		if s.allTimes {
+			ret.objChildren["format"] = NewString("date-time")
+		} else if s.isEnum(types) {
+			enum := NewArray()
+			for _, str := range sortedStringSet(s.strValues) {
+				enum.arrChildren = append(enum.arrChildren, NewString(str))
+			}
+			if s.types["null"] {
+				enum.arrChildren = append(enum.arrChildren, NewNull())
+			}
+			ret.objChildren["enum"] = enum
+		}
+	}
+
+	if s.types["integer"] || s.types["number"] {
+		ret.objChildren["minimum"] = schemaNumber(s.min)
+		ret.objChildren["maximum"] = schemaNumber(s.max)
+	}
+
+	if s.types["object"] {
+		props := NewObject()
+		required := NewArray()
+		for _, k := range sortedStatKeys(s.properties) {
+			props.objChildren[k] = s.properties[k].schema()
+			if s.presence[k] == s.objCount {
+				required.arrChildren = append(required.arrChildren, NewString(k))
+			}
+		}
+		ret.objChildren["properties"] = props
+		if len(required.arrChildren) > 0 {
+			ret.objChildren["required"] = required
+		}
+	}
+
+	if s.types["array"] {
+		ret.objChildren["items"] = s.items.schema()
+	}
+	return ret
+}
+
+// isEnum tells whether strings here look like a small set of constants
+func (s *schemaStats) isEnum(types []string) bool {
+	if nil == s.strValues || 0 == len(s.strValues) {
+		return false
+	}
+	for _, t := range types {
+		if "string" != t && "null" != t {
+			return false
+		}
+	}
+	return s.strCount >= schemaEnumMinRepeat*len(s.strValues)
+}
+
+func schemaNumber(f float64) *JsonValue {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return NewInt64(int64(f))
+	}
+	return NewFloat(f)
+}
+
+func sortedStringSet(set map[string]bool) []string {
+	ret := make([]string, 0, len(set))
+	for k := range set {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func sortedStatKeys(m map[string]*schemaStats) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// InferSchema derives a JSON Schema (draft-07) from sample documents. It
+// records the types seen at each position, which object keys appear in every
+// sample ("required"), minimum and maximum of numbers, item schemas of arrays,
+// "date-time" format for strings which are all RFC 3339 times, and an "enum"
+// for strings which repeat a few distinct values. Nil samples are ignored.
+func InferSchema(samples ...*JsonValue) *JsonValue {
+	stats := newSchemaStats()
+	for _, v := range samples {
+		if nil != v {
+			stats.add(v)
+		}
+	}
+	ret := stats.schema()
+	ret.objChildren["$schema"] = NewString("http://json-schema.org/draft-07/schema#")
+	return ret
+}
+
+// ====================
+// Go struct generation
+
+// GoStructFromSchema generates Go type definitions for an object schema, as
+// returned by InferSchema. Fields carry both `json` and `db` tags. Scalar
+// fields which are optional or nullable use the sql.NullXxx types, and
+// mysql.NullTime for date-time strings, so that the struct can be read from
+// a database. Nested objects become separate types named after their parent
+// and field, embedded by value if required and non-nullable and referenced by
+// pointer otherwise, and arrays become slices. The returned source contains
+// the import declaration if needed, without package clause.
+//
+// SqlToJson only converts scalar fields and skips nested structs, pointers
+// and slices. Use NewFromInterface to convert a generated struct as a whole.
+func GoStructFromSchema(schema *JsonValue, typeName string) (string, error) {
+	if nil == schema || false == schema.IsObject() {
+		return "", NotAnObjectError
+	}
+	if types := schemaTypes(schema); len(types) != 1 || "object" != types[0] {
+		return "", NotAnObjectError
+	}
+	if "" == typeName {
+		typeName = "Record"
+	}
+
+	g := &goStructGen{imports: make(map[string]bool)}
+	g.genStruct(schema, goIdentifier(typeName))
+
+	b := bytes.Buffer{}
+	if len(g.imports) > 0 {
+		b.WriteString("import (\n")
+		for _, imp := range sortedStringSet(g.imports) {
+			b.WriteString("\t\"" + imp + "\"\n")
+		}
+		b.WriteString(")\n\n")
+	}
+	for i, def := range g.defs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(def)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return string(src), nil
+}
+
+type goStructGen struct {
+	imports map[string]bool
+	defs    []string
+	names   map[string]bool
+}
+
+// schemaTypes returns the "type" of a schema, without "null"
+func schemaTypes(schema *JsonValue) []string {
+	var ret []string
+	t, exist := schema.objChildren["type"]
+	if false == exist {
+		return nil
+	}
+	if t.IsString() {
+		if "null" != t.stringValue {
+			ret = append(ret, t.stringValue)
+		}
+		return ret
+	}
+	for _, child := range t.arrChildren {
+		if child.IsString() && "null" != child.stringValue {
+			ret = append(ret, child.stringValue)
+		}
+	}
+	return ret
+}
+
+func schemaNullable(schema *JsonValue) bool {
+	t := schema.objChildren["type"]
+	if nil == t {
+		return true
+	}
+	if t.IsString() {
+		return "null" == t.stringValue
+	}
+	for _, child := range t.arrChildren {
+		if "null" == child.stringValue {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *goStructGen) uniqueName(name string) string {
+	if nil == g.names {
+		g.names = make(map[string]bool)
+	}
+	ret := name
+	for i := 2; g.names[ret]; i++ {
+		ret = name + strconv.Itoa(i)
+	}
+	g.names[ret] = true
+	return ret
+}
+
+func (g *goStructGen) genStruct(schema *JsonValue, name string) string {
+	name = g.uniqueName(name)
+	index := len(g.defs)
+	g.defs = append(g.defs, "")
+
+	required := make(map[string]bool)
+	if r, exist := schema.objChildren["required"]; exist {
+		for _, k := range r.arrChildren {
+			required[k.stringValue] = true
+		}
+	}
+
+	b := bytes.Buffer{}
+	b.WriteString("type " + name + " struct {\n")
+	props := schema.objChildren["properties"]
+	fields := make(map[string]bool)
+	if nil != props {
+		for _, k := range sortedKeys(props) {
+			field := goIdentifier(k)
+			for i := 2; fields[field]; i++ {
+				field = goIdentifier(k) + strconv.Itoa(i)
+			}
+			fields[field] = true
+
+			optional := false == required[k]
+			typ := g.goType(props.objChildren[k], name+field, optional)
+			tag := strconv.Quote(k)
+			b.WriteString("\t" + field + " " + typ + " `json:" + tag + " db:" + tag + "`\n")
+		}
+	}
+	b.WriteString("}\n")
+
+	g.defs[index] = b.String()
+	return name
+}
+
+func (g *goStructGen) goType(schema *JsonValue, nestedName string, optional bool) string {
+	types := schemaTypes(schema)
+	if len(types) != 1 {
+		return "interface{}"
+	}
+	nullable := optional || schemaNullable(schema)
+
+	switch types[0] {
+	case "string":
+		format := schema.objChildren["format"]
+		isTime := nil != format && "date-time" == format.stringValue
+		switch {
+		case isTime && nullable:
+			g.imports["github.com/go-sql-driver/mysql"] = true
+			return "mysql.NullTime"
+		case isTime:
+			g.imports["time"] = true
+			return "time.Time"
+		case nullable:
+			g.imports["database/sql"] = true
+			return "sql.NullString"
+		default:
+			return "string"
+		}
+	case "integer":
+		if nullable {
+			g.imports["database/sql"] = true
+			return "sql.NullInt64"
+		}
+		return "int64"
+	case "number":
+		if nullable {
+			g.imports["database/sql"] = true
+			return "sql.NullFloat64"
+		}
+		return "float64"
+	case "boolean":
+		if nullable {
+			g.imports["database/sql"] = true
+			return "sql.NullBool"
+		}
+		return "bool"
+	case "object":
+		name := g.genStruct(schema, nestedName)
+		if nullable {
+			return "*" + name
+		}
+		return name
+	case "array":
+		items := schema.objChildren["items"]
+		if nil == items {
+			return "[]interface{}"
+		}
+		return "[]" + g.goType(items, nestedName+"Item", schemaNullable(items))
+	default:
+		return "interface{}"
+	}
+}
+
+// goIdentifier converts a JSON key to an exported Go identifier, e.g.
+// "user_id" to "UserID"
+func goIdentifier(key string) string {
+	initialisms := map[string]bool{
+		"ID": true, "URL": true, "URI": true, "IP": true, "HTTP": true,
+		"JSON": true, "XML": true, "SQL": true, "UUID": true, "API": true,
+	}
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return false == unicode.IsLetter(r) && false == unicode.IsDigit(r)
+	})
+
+	b := strings.Builder{}
+	for _, w := range words {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	ret := b.String()
+	if "" == ret {
+		return "Field"
+	}
+	if r := []rune(ret)[0]; false == unicode.IsLetter(r) {
+		ret = "F" + ret
+	}
+	return ret
+}
@@ -0,0 +1,61 @@
+package jsonconv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	var samples []*JsonValue
+	for _, s := range []string{
+		`{"id": 1, "status": "active", "price": 9.5, "tags": ["a"], "created": "2020-01-02T03:04:05Z", "owner": {"name": "bob"}}`,
+		`{"id": 2, "status": "inactive", "price": 3, "tags": [], "created": "2020-02-02T03:04:05+08:00", "note": null}`,
+		`{"id": 30, "status": "active", "price": 12, "tags": ["b", "c"], "created": "2021-01-02T03:04:05Z", "note": "x"}`,
+		`{"id": 4, "status": "inactive", "price": 1, "tags": ["d"], "created": "2022-01-02T03:04:05Z", "owner": {"name": "al"}}`,
+	} {
+		v, err := NewFromString(s)
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		samples = append(samples, v)
+	}
+
+	schema := InferSchema(samples...)
+	expected := `{"$schema":"http:\/\/json-schema.org\/draft-07\/schema#",` +
+		`"properties":{` +
+		`"created":{"format":"date-time","type":"string"},` +
+		`"id":{"maximum":30,"minimum":1,"type":"integer"},` +
+		`"note":{"type":["string","null"]},` +
+		`"owner":{"properties":{"name":{"type":"string"}},"required":["name"],"type":"object"},` +
+		`"price":{"maximum":12,"minimum":1,"type":"number"},` +
+		`"status":{"enum":["active","inactive"],"type":"string"},` +
+		`"tags":{"items":{"type":"string"},"type":"array"}},` +
+		`"required":["created","id","price","status","tags"],"type":"object"}`
+	if s := marshalSorted(schema); s != expected {
+		t.Errorf("unexpected schema:\n%s", s)
+	}
+
+	src, err := GoStructFromSchema(schema, "product")
+	if err != nil {
+		t.Fatalf("GoStructFromSchema error: %v", err)
+	}
+	for _, line := range []string{
+		`"database/sql"`,
+		`"time"`,
+		"type Product struct {",
+		"Created time.Time      `json:\"created\" db:\"created\"`",
+		"ID      int64          `json:\"id\" db:\"id\"`",
+		"Note    sql.NullString `json:\"note\" db:\"note\"`",
+		"Owner   *ProductOwner  `json:\"owner\" db:\"owner\"`",
+		"Tags    []string       `json:\"tags\" db:\"tags\"`",
+		"type ProductOwner struct {",
+	} {
+		if false == strings.Contains(src, line) {
+			t.Errorf("missing %q in generated source:\n%s", line, src)
+		}
+	}
+
+	if _, err := GoStructFromSchema(InferSchema(NewString("x")), "X"); err != NotAnObjectError {
+		t.Errorf("expected NotAnObjectError, got %v", err)
+	}
+}
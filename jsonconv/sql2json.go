@@ -12,7 +12,9 @@ import (
 	// "github.com/go-sql-driver/mysql"
 )
 
-func getFieldTag(field *reflect.StructField, filterMode Filter, filterMap map[string]int, ensureAscii bool) string {
+// fieldTagName returns the key of a struct field, from its "json" or "db"
+// tag, or its name. An empty string means the field is ignored.
+func fieldTagName(field *reflect.StructField) string {
 	tag := ""
 
 	// read from "json"
@@ -38,6 +40,14 @@ func getFieldTag(field *reflect.StructField, filterMode Filter, filterMap map[st
 	if str.Empty(tag) {
 		tag = field.Name
 	}
+	return tag
+}
+
+func getFieldTag(field *reflect.StructField, filterMode Filter, filterMap map[string]int, ensureAscii bool) string {
+	tag := fieldTagName(field)
+	if str.Empty(tag) {
+		return ""
+	}
 
 	// filter
 	switch filterMode {
@@ -114,7 +124,7 @@ func processFieldFloat64(tag string, f float64, valid bool, opt Option, keyList
 func processFieldTime(tag string, t time.Time, valid bool, opt Option, keyList *[]string, valList *[]string) {
 	if valid {
 		*keyList = append(*keyList, tag)
-		*valList = append(*valList, `"` + convertTimeToString(t, opt.TimeDigits) + `"`)
+		*valList = append(*valList, timeToJsonString(t, &opt))
 	} else {
 		if opt.ShowNull {
 			*keyList = append(*keyList, tag)
@@ -123,11 +133,17 @@ func processFieldTime(tag string, t time.Time, valid bool, opt Option, keyList *
 	}
 }
 
-func processField(field *reflect.StructField, value reflect.Value, opt Option, filterMap map[string]int, keyList *[]string, valList *[]string) {
+func processField(field *reflect.StructField, value reflect.Value, opt Option, filterMap map[string]int, redact *redactor, keyList *[]string, valList *[]string) {
 	tag := getFieldTag(field, opt.FilterMode, filterMap, opt.EnsureAscii)
 	if str.Empty(tag) {		// skip ignored fields
 		return
 	}
+	count := len(*valList)
+	defer func() {
+		if len(*valList) > count {
+			redactField(field, tag, opt, redact, keyList, valList)
+		}
+	}()
 
 	// log.Debug("Tag: %s - %s", field.Name, tag)
 	switch field.Type.Kind() {
@@ -167,6 +183,30 @@ func processField(field *reflect.StructField, value reflect.Value, opt Option, f
 	}
 }
 
+// redactField hides the value just added for field, if the field is tagged
+// with "redact" or matches a rule in opt.Redact
+func redactField(field *reflect.StructField, tag string, opt Option, redact *redactor, keyList *[]string, valList *[]string) {
+	rule, exist := redactRuleFromTag(field)
+	if false == exist && redact != nil {
+		rule = redact.find(Path{tag})
+	}
+	if nil == rule {
+		return
+	}
+
+	last := len(*valList) - 1
+	if RedactDrop == rule.Strategy {
+		*keyList = (*keyList)[:last]
+		*valList = (*valList)[:last]
+		return
+	}
+	v, err := NewFromString((*valList)[last])
+	if err != nil || v.IsNull() {
+		return
+	}
+	(*valList)[last] = `"` + escapeJsonString(rule.redactString(redactText(v)), opt.EnsureAscii) + `"`
+}
+
 func sqlTypeToJson(t reflect.Type, v reflect.Value, opt Option) (string, error) {
 	num_field := t.NumField()
 	key_list := make([]string, 0, num_field)
@@ -180,10 +220,12 @@ func sqlTypeToJson(t reflect.Type, v reflect.Value, opt Option) (string, error)
 		}
 	}
 
+	redact := newRedactor(opt.Redact)
+
 	// parse struct
 	for i := 0; i < num_field; i ++ {
 		field := t.Field(i)
-		processField(&field, v.Field(i), opt, filter_map, &key_list, &value_list)
+		processField(&field, v.Field(i), opt, filter_map, redact, &key_list, &value_list)
 	}
 
 	// get json string
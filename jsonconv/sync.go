@@ -0,0 +1,274 @@
+package jsonconv
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SyncValue holds a JsonValue shared between goroutines. Readers get
+// consistent snapshots without locking, while writers are serialized and
+// never touch a published snapshot: every update builds a new tree which
+// shares untouched subtrees with the previous one, and then replaces it
+// atomically.
+//
+// Values returned by Load, Get and At are snapshots and must be treated as
+// read-only.
+type SyncValue struct {
+	lock  sync.Mutex
+	value atomic.Value
+
+	watchLock  sync.RWMutex
+	watchers   map[int]*watcher
+	watcherSeq int
+
+	// published snapshots waiting for dispatch in commit order, guarded by
+	// lock
+	pending     []publication
+	dispatching bool
+}
+
+type publication struct {
+	old, new *JsonValue
+}
+
+// NewSyncValue returns a SyncValue holding a deep copy of v. A nil v gives an
+// empty object.
+func NewSyncValue(v *JsonValue) *SyncValue {
+	s := &SyncValue{}
+	if nil == v {
+		v = NewObject()
+	}
+	s.value.Store(v.Clone())
+	return s
+}
+
+// Load returns the current snapshot
+func (s *SyncValue) Load() *JsonValue {
+	if v, ok := s.value.Load().(*JsonValue); ok {
+		return v
+	}
+	return NewObject()
+}
+
+// Store replaces the whole value with a deep copy of v
+func (s *SyncValue) Store(v *JsonValue) {
+	if nil == v {
+		return
+	}
+	v = v.Clone()
+	s.lock.Lock()
+	old := s.Load()
+	s.publish(old, shareUnchanged(old, v))
+}
+
+// Update applies fn on a private deep copy of the current value, and
+// publishes it if fn succeeds. All changes made in fn become visible to
+// readers at once, and watchers get them as a single set of events.
+// Subtrees left unchanged by fn stay shared with the previous snapshot.
+func (s *SyncValue) Update(fn func(v *JsonValue) error) error {
+	s.lock.Lock()
+	old := s.Load()
+	next := old.Clone()
+	if err := fn(next); err != nil {
+		s.lock.Unlock()
+		return err
+	}
+	s.publish(old, shareUnchanged(old, next))
+	return nil
+}
+
+// publish stores next as the current snapshot and then dispatches events.
+// It must be called with s.lock held, and releases it.
+func (s *SyncValue) publish(old, next *JsonValue) {
+	s.value.Store(next)
+	if old != next && s.hasWatchers() {
+		s.pending = append(s.pending, publication{old, next})
+	}
+	if s.dispatching {
+		// the running dispatcher picks it up, keeping events in commit order
+		s.lock.Unlock()
+		return
+	}
+	s.dispatching = true
+	locked := true
+	defer func() {
+		// also reached when a watcher panics, in which case the remaining
+		// events are dropped so that later updates are still delivered
+		if false == locked {
+			s.lock.Lock()
+		}
+		s.pending = nil
+		s.dispatching = false
+		s.lock.Unlock()
+	}()
+	for len(s.pending) > 0 {
+		p := s.pending[0]
+		s.pending[0] = publication{}
+		s.pending = s.pending[1:]
+		s.lock.Unlock()
+		locked = false
+		s.notify(p.old, p.new)
+		s.lock.Lock()
+		locked = true
+	}
+}
+
+// shareUnchanged replaces subtrees of next which are identical to those at
+// the same place in old with the old ones, so that snapshots share memory and
+// diffValues can skip them. It returns old if nothing differs at all.
+func shareUnchanged(old, next *JsonValue) *JsonValue {
+	if nil == old || nil == next || old.valueType != next.valueType {
+		return next
+	}
+	switch next.valueType {
+	case Object:
+		same := len(old.objChildren) == len(next.objChildren)
+		for k, child := range next.objChildren {
+			o, exist := old.objChildren[k]
+			if false == exist {
+				same = false
+				continue
+			}
+			next.objChildren[k] = shareUnchanged(o, child)
+			if next.objChildren[k] != o {
+				same = false
+			}
+		}
+		if same {
+			return old
+		}
+	case Array:
+		same := len(old.arrChildren) == len(next.arrChildren)
+		for i, child := range next.arrChildren {
+			if i >= len(old.arrChildren) {
+				break
+			}
+			next.arrChildren[i] = shareUnchanged(old.arrChildren[i], child)
+			if next.arrChildren[i] != old.arrChildren[i] {
+				same = false
+			}
+		}
+		if same {
+			return old
+		}
+	default:
+		if old.stringValue == next.stringValue && old.intValue == next.intValue &&
+			old.uintValue == next.uintValue && old.floatValue == next.floatValue &&
+			old.boolValue == next.boolValue && old.mustSigned == next.mustSigned &&
+			old.mustUnsigned == next.mustUnsigned && old.mustFloat == next.mustFloat {
+			return old
+		}
+	}
+	return next
+}
+
+// modify copies containers along path, calls fn with the copy of the last
+// one, and publishes the new root if fn succeeds.
+func (s *SyncValue) modify(path Path, fn func(target *JsonValue) error) error {
+	s.lock.Lock()
+	root := s.Load()
+	next, err := copyAndModify(root, path, fn)
+	if err != nil {
+		s.lock.Unlock()
+		return err
+	}
+	s.publish(root, next)
+	return nil
+}
+
+func copyAndModify(root *JsonValue, path Path, fn func(target *JsonValue) error) (*JsonValue, error) {
+	if _, err := root.getByPath(path); err != nil {
+		return nil, err
+	}
+
+	next := root.shallowCopy()
+	curr := next
+	for _, seg := range path {
+		if key, ok := seg.(string); ok {
+			child := curr.objChildren[key].shallowCopy()
+			curr.objChildren[key] = child
+			curr = child
+		} else {
+			index, _ := intFromInterface(seg)
+			child := curr.arrChildren[index].shallowCopy()
+			curr.arrChildren[index] = child
+			curr = child
+		}
+	}
+
+	if err := fn(curr); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Get reads from the current snapshot
+func (s *SyncValue) Get(first interface{}, keys ...interface{}) (*JsonValue, error) {
+	return s.Load().Get(first, keys...)
+}
+
+// At navigates in the current snapshot
+func (s *SyncValue) At(first interface{}, keys ...interface{}) *Cursor {
+	return s.Load().At(first, keys...)
+}
+
+// Set works like JsonValue.Set with a deep copy of newOne
+func (s *SyncValue) Set(newOne *JsonValue, first interface{}, keys ...interface{}) error {
+	if nil == newOne {
+		return ParaError
+	}
+	newOne = newOne.Clone()
+	path := append(Path{first}, keys...)
+	last := len(path) - 1
+	return s.modify(path[:last], func(parent *JsonValue) error {
+		_, err := parent.Set(newOne, path[last])
+		return err
+	})
+}
+
+// Delete works like JsonValue.Delete
+func (s *SyncValue) Delete(first interface{}, keys ...interface{}) error {
+	path := append(Path{first}, keys...)
+	last := len(path) - 1
+	return s.modify(path[:last], func(parent *JsonValue) error {
+		return parent.Delete(path[last])
+	})
+}
+
+// Append works like JsonValue.Append with a deep copy of newOne
+func (s *SyncValue) Append(newOne *JsonValue, keys ...interface{}) error {
+	if nil == newOne {
+		return ParaError
+	}
+	newOne = newOne.Clone()
+	return s.modify(Path(keys), func(arr *JsonValue) error {
+		_, err := arr.Append(newOne)
+		return err
+	})
+}
+
+// Insert works like JsonValue.Insert with a deep copy of newOne. The last
+// element of the path is the index to insert at.
+func (s *SyncValue) Insert(newOne *JsonValue, first interface{}, keys ...interface{}) error {
+	if nil == newOne {
+		return ParaError
+	}
+	newOne = newOne.Clone()
+	path := append(Path{first}, keys...)
+	last := len(path) - 1
+	return s.modify(path[:last], func(arr *JsonValue) error {
+		_, err := arr.Insert(newOne, path[last])
+		return err
+	})
+}
+
+// MergeFrom works like JsonValue.MergeFrom with a deep copy of from
+func (s *SyncValue) MergeFrom(from *JsonValue, optList ...Option) error {
+	if nil == from {
+		return nil
+	}
+	from = from.Clone()
+	return s.Update(func(v *JsonValue) error {
+		return v.MergeFrom(from, optList...)
+	})
+}
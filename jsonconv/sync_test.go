@@ -0,0 +1,91 @@
+package jsonconv
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSyncValueSnapshot(t *testing.T) {
+	s := NewSyncValue(nil)
+	if err := s.Set(NewObject(), "db"); err != nil {
+		t.Errorf("Set error: %v", err)
+		return
+	}
+	if err := s.Set(NewInt(1), "db", "timeout"); err != nil {
+		t.Errorf("Set error: %v", err)
+		return
+	}
+	snapshot := s.Load()
+
+	s.Set(NewInt(2), "db", "timeout")
+	s.Set(NewArray(), "list")
+	s.Append(NewString("a"), "list")
+	s.Insert(NewString("b"), "list", 0)
+
+	if i, _ := snapshot.GetInt("db", "timeout"); i != 1 {
+		t.Errorf("old snapshot changed, timeout = %d", i)
+	}
+	if _, err := snapshot.Get("list"); err == nil {
+		t.Errorf("old snapshot should not have list")
+	}
+	if i := s.At("db", "timeout").IntOr(0); i != 2 {
+		t.Errorf("expected timeout 2, got %d", i)
+	}
+	if str, _ := s.Load().Marshal(Option{SortMode: DictAsc}); str != `{"db":{"timeout":2},"list":["b","a"]}` {
+		t.Errorf("unexpected value: %s", str)
+	}
+
+	if err := s.Delete("list", 0); err != nil {
+		t.Errorf("Delete error: %v", err)
+	}
+	if err := s.Set(NewInt(1), "missing", "key"); err == nil {
+		t.Errorf("Set on missing parent should fail")
+	}
+}
+
+func TestSyncValueRace(t *testing.T) {
+	s := NewSyncValue(nil)
+	s.Set(NewObject(), "db")
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := "k" + strconv.Itoa(i)
+				s.Set(NewInt(j), "db", key)
+				patch, _ := NewFromString(`{"db": {"merged": ` + strconv.Itoa(j) + `}}`)
+				s.MergeFrom(patch)
+				s.Update(func(v *JsonValue) error {
+					_, err := v.SetInt(j, "counter")
+					return err
+				})
+			}
+		}(i)
+	}
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				snapshot := s.Load()
+				snapshot.Marshal()
+				snapshot.At("db", "merged").IntOr(0)
+				snapshot.ObjectForeach(func(k string, v *JsonValue) error {
+					v.Length()
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 4; i++ {
+		if v := s.At("db", "k"+strconv.Itoa(i)).IntOr(-1); v != 199 {
+			t.Errorf("expected k%d = 199, got %d", i, v)
+		}
+	}
+}
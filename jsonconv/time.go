@@ -0,0 +1,99 @@
+package jsonconv
+
+import (
+	"math"
+	"time"
+)
+
+// Special layouts for Option.TimeFormat, SetTime and GetTime. Times in these
+// formats are JSON numbers instead of strings.
+const (
+	// TimeUnix is seconds since the Unix epoch
+	TimeUnix = "unix"
+	// TimeUnixMilli is milliseconds since the Unix epoch
+	TimeUnixMilli = "unixmilli"
+)
+
+// DefaultTimeLayouts are tried by GetTime when no layout is given
+var DefaultTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	TimeUnix,
+}
+
+// newTimeValue formats t with layout, which may be TimeUnix or TimeUnixMilli
+func newTimeValue(t time.Time, layout string) *JsonValue {
+	switch layout {
+	case TimeUnix:
+		return NewInt64(t.Unix())
+	case TimeUnixMilli:
+		return NewInt64(t.UnixNano() / int64(time.Millisecond))
+	default:
+		return NewString(t.Format(layout))
+	}
+}
+
+// timeValueWithOption formats t as Option.TimeFormat and TimeLocation say
+func timeValueWithOption(t time.Time, opt *Option) *JsonValue {
+	if nil != opt.TimeLocation {
+		t = t.In(opt.TimeLocation)
+	}
+	if "" == opt.TimeFormat {
+		return NewString(convertTimeToString(t, opt.TimeDigits))
+	}
+	return newTimeValue(t, opt.TimeFormat)
+}
+
+// timeToJsonString is timeValueWithOption in JSON text
+func timeToJsonString(t time.Time, opt *Option) string {
+	s, _ := timeValueWithOption(t, opt).Marshal(Option{EnsureAscii: opt.EnsureAscii})
+	return s
+}
+
+// SetTime sets t formatted with layout, which may also be TimeUnix or
+// TimeUnixMilli. An empty layout means time.RFC3339Nano.
+func (obj *JsonValue) SetTime(t time.Time, layout string, first interface{}, keys ...interface{}) (*JsonValue, error) {
+	if "" == layout {
+		layout = time.RFC3339Nano
+	}
+	return obj.Set(newTimeValue(t, layout), first, keys...)
+}
+
+// GetTime reads a time, trying layouts in order, or DefaultTimeLayouts if
+// layouts is empty. Strings are parsed with the string layouts, and numbers
+// with TimeUnix or TimeUnixMilli, whichever comes first in layouts. Strings
+// without time zone are regarded as UTC. A value matching no layout gives a
+// *PathError wrapping TimeFormatError.
+func (obj *JsonValue) GetTime(layouts []string, first interface{}, keys ...interface{}) (time.Time, error) {
+	path := append(Path{first}, keys...)
+	child, err := obj.getByPath(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if 0 == len(layouts) {
+		layouts = DefaultTimeLayouts
+	}
+
+	for _, layout := range layouts {
+		switch {
+		case TimeUnix == layout && child.IsNumber():
+			sec, frac := math.Modf(child.Float())
+			return time.Unix(int64(sec), int64(frac*float64(time.Second))), nil
+		case TimeUnixMilli == layout && child.IsNumber():
+			return time.Unix(0, child.Int64()*int64(time.Millisecond)), nil
+		case TimeUnix == layout || TimeUnixMilli == layout:
+			continue
+		case child.IsString():
+			if t, err := time.Parse(layout, child.stringValue); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	last := len(path) - 1
+	if child.IsString() || child.IsNumber() {
+		return time.Time{}, &PathError{Path: path, Index: last, Segment: path[last], Expected: Unknown, Actual: child.valueType, Err: TimeFormatError}
+	}
+	return time.Time{}, &PathError{Path: path, Index: last, Segment: path[last], Expected: String, Actual: child.valueType, Err: TimeFormatError}
+}
@@ -0,0 +1,145 @@
+package jsonconv
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestTimeFormatSqlToJson(t *testing.T) {
+	type row struct {
+		ID      int64     `db:"id"`
+		Created time.Time `db:"created"`
+	}
+	tm := time.Date(2021, 3, 4, 5, 6, 7, 800000000, time.UTC)
+	r := row{ID: 1, Created: tm}
+	shanghai := time.FixedZone("CST", 8*3600)
+
+	cases := []struct {
+		opt      Option
+		expected string
+	}{
+		{Option{}, `{"id":1,"created":"2021-03-04 05:06:07"}`},
+		{Option{TimeFormat: time.RFC3339}, `{"id":1,"created":"2021-03-04T05:06:07Z"}`},
+		{Option{TimeFormat: time.RFC3339, TimeLocation: shanghai}, `{"id":1,"created":"2021-03-04T13:06:07+08:00"}`},
+		{Option{TimeFormat: TimeUnix}, `{"id":1,"created":1614834367}`},
+		{Option{TimeFormat: TimeUnixMilli}, `{"id":1,"created":1614834367800}`},
+		{Option{TimeFormat: "2006/01/02"}, `{"id":1,"created":"2021\/03\/04"}`},
+	}
+	for _, c := range cases {
+		s, err := SqlToJson(r, c.opt)
+		if err != nil {
+			t.Fatalf("SqlToJson error: %v", err)
+		}
+		if s != c.expected {
+			t.Errorf("%q: expected %s, got %s", c.opt.TimeFormat, c.expected, s)
+		}
+	}
+}
+
+func TestNewFromInterface(t *testing.T) {
+	type base struct {
+		ID int64 `json:"id"`
+	}
+	type item struct {
+		base
+		Name    string            `json:"name"`
+		Created time.Time         `json:"created"`
+		Note    sql.NullString    `json:"note"`
+		Tags    []string          `json:"tags"`
+		Attrs   map[string]int    `json:"attrs"`
+		Raw     []byte            `json:"raw"`
+		Token   string            `json:"token,redact"`
+		Skip    string            `json:"-"`
+		Extra   *JsonValue        `json:"extra"`
+		Nested  *item             `json:"nested"`
+		Labels  map[string]string `db:"labels"`
+		hidden  int
+	}
+	tm := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	extra, _ := NewFromString(`{"a":[1]}`)
+	v := item{
+		base:    base{ID: 3},
+		Name:    "x",
+		Created: tm,
+		Tags:    []string{"a", "b"},
+		Attrs:   map[string]int{"k": 1},
+		Raw:     []byte("hi"),
+		Token:   "secret",
+		Skip:    "skip",
+		Extra:   extra,
+		hidden:  1,
+	}
+
+	j, err := NewFromInterface(&v, Option{TimeFormat: TimeUnix})
+	if err != nil {
+		t.Fatalf("NewFromInterface error: %v", err)
+	}
+	s := marshalSorted(j)
+	expected := `{"attrs":{"k":1},"created":1614834367,"extra":{"a":[1]},"id":3,"labels":null,` +
+		`"name":"x","nested":null,"note":null,"raw":"aGk=","tags":["a","b"],"token":"******"}`
+	if s != expected {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	// filter on top level keys
+	j, _ = NewFromInterface(v, Option{FilterMode: IncludeMode, FilterList: []string{"id", "name"}})
+	if s := marshalSorted(j); s != `{"id":3,"name":"x"}` {
+		t.Errorf("unexpected filtered result: %s", s)
+	}
+
+	if _, err := NewFromInterface(map[int]string{1: "a"}); err != DataTypeError {
+		t.Errorf("expected DataTypeError, got %v", err)
+	}
+	if j, _ := NewFromInterface(nil); false == j.IsNull() {
+		t.Errorf("expected null")
+	}
+
+	// cycles are rejected, while values shared by siblings are not
+	v.Nested = &v
+	if _, err := NewFromInterface(&v); err != CycleError {
+		t.Errorf("expected CycleError, got %v", err)
+	}
+	m := map[string]interface{}{}
+	m["self"] = m
+	if _, err := NewFromInterface(m); err != CycleError {
+		t.Errorf("expected CycleError, got %v", err)
+	}
+	shared := &base{ID: 1}
+	if j, err := NewFromInterface([]*base{shared, shared}); err != nil || j.Length() != 2 {
+		t.Errorf("unexpected result of shared pointers: %v", err)
+	}
+}
+
+func TestGetSetTime(t *testing.T) {
+	tm := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	v := NewObject()
+	if _, err := v.SetTime(tm, "", "a"); err != nil {
+		t.Fatalf("SetTime error: %v", err)
+	}
+	if _, err := v.SetTime(tm, TimeUnixMilli, "b"); err != nil {
+		t.Fatalf("SetTime error: %v", err)
+	}
+	if _, err := v.SetTime(tm, "2006-01-02", "c"); err != nil {
+		t.Fatalf("SetTime error: %v", err)
+	}
+	if s := marshalSorted(v); s != `{"a":"2021-03-04T05:06:07Z","b":1614834367000,"c":"2021-03-04"}` {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	if got, err := v.GetTime(nil, "a"); err != nil || false == got.Equal(tm) {
+		t.Errorf("GetTime a: %v, %v", got, err)
+	}
+	if got, err := v.GetTime([]string{TimeUnixMilli}, "b"); err != nil || false == got.Equal(tm) {
+		t.Errorf("GetTime b: %v, %v", got, err)
+	}
+	if got, err := v.GetTime(nil, "c"); err != nil || false == got.Equal(time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("GetTime c: %v, %v", got, err)
+	}
+
+	_, err := v.GetTime([]string{time.RFC3339}, "c")
+	pe, ok := err.(*PathError)
+	if false == ok || pe.Err != TimeFormatError {
+		t.Errorf("expected TimeFormatError, got %v", err)
+	}
+}
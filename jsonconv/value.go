@@ -4,9 +4,7 @@ import (
 	"github.com/buger/jsonparser"
 	// "github.com/Andrew-M-C/go-tools/log"
 	"strconv"
-	"strings"
 	"bytes"
-	"reflect"
 )
 
 // data definitions same as jsonparser
@@ -41,70 +39,6 @@ type JsonValue struct {
 	mustFloat		bool
 }
 
-// ====================
-// internal functions
-
-var escapeMap = map[rune]rune {
-	'"': '"',
-	'/': '/',
-	'b': '\b',
-	'f': '\f',
-	't': '\t',
-	'n': '\n',
-}
-
-func stringFromEscapedBytes(input []byte) (string, error) {
-	b := bytes.Buffer{}
-	s := string(input)
-	escaping := false
-	skip := 0
-
-	for i, chr := range s {
-		if skip > 0 {
-			skip --
-		} else if escaping {
-			escaping = false
-			switch chr {
-			case '"', '/', 'b', 'f', 't', 'n', 'r':
-				write_chr, exist := escapeMap[chr]
-				if exist {
-					b.WriteRune(write_chr)
-				}
-			case 'u':
-				// parse unicode
-				sub_str := s[i+1:i+5]
-				unicode, err := strconv.ParseInt(sub_str, 16, 32)
-				if err != nil {
-					// err
-					// log.Error("err: %s", err.Error())
-					return "", JsonFormatError
-				} else {
-					skip = 4
-					b.WriteRune(rune(unicode))
-				}
-			default:
-				// the previous \ is just a simple character
-				escaping = false
-				b.WriteRune('\\')
-				b.WriteRune(chr)
-			}
-		} else {
-			switch chr {
-			case '\\':
-				// get ready to escape
-				escaping = true
-			default:
-				b.WriteRune(chr)
-			}
-		}
-	}
-	if escaping {
-		escaping = false
-		b.WriteRune('\\')
-	}
-	return b.String(), nil
-}
-
 // ====================
 // New() functions
 
@@ -112,228 +46,15 @@ func NewByUnmarshal(s string) (*JsonValue, error) {
 	return NewFromString(s)
 }
 
-func NewFromString(s string) (*JsonValue, error) {
-	var obj *JsonValue
-	var err error
-
-	// get first character
-	for index, chr := range s {
-		switch chr {
-		case ' ', '\r', '\n', '\t':
-			// continue
-		case '{':
-			obj = NewObject()
-			err = obj.parseObject([]byte(s[index:]))
-			if err != nil {
-				return nil, err
-			} else {
-				return obj, nil
-			}
-		case '[':
-			obj = NewArray()
-			err = obj.parseArray([]byte(s[index:]))
-			if err != nil {
-				return nil, err
-			} else {
-				return obj, nil
-			}
-		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '-':
-			obj = new(JsonValue)
-			obj.valueType = Number
-			obj.mustSigned = (chr == '-')
-			obj.floatValue, err = strconv.ParseFloat(s[index:], 64)
-			if err != nil {
-				return nil, err
-			}
-			obj.intValue, err = strconv.ParseInt(s[index:], 10, 64)
-			if err != nil {
-				// return nil, err
-				// if parseFloat OK but parseInt failed, this may be a float
-				// log.Debug("error: %s", err.Error())
-				if strings.HasSuffix(err.Error(), "value out of range") {
-					// this must be a unsigned integer
-					obj.mustUnsigned = true
-				} else {
-					// log.Debug("MARK")
-					obj.mustFloat = true
-					obj.intValue = int64(obj.floatValue)
-					obj.uintValue = uint64(obj.intValue)
-				}
-			}
-			// log.Debug("mustUnsigned = %t, mustFloat = %t", obj.mustUnsigned, obj.mustFloat)
-
-			if obj.mustUnsigned && false == obj.mustFloat {
-				obj.uintValue, err = strconv.ParseUint(s[index:], 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				if 0 != obj.uintValue & 0x1000000000000000 {
-					obj.mustUnsigned = true
-				}
-			} else {
-				obj.uintValue = uint64(obj.intValue)
-			}
-			// log.Debug("orig str: %s", s[index:])
-			// log.Debug("parsed i: %d", obj.intValue)
-			// log.Debug("parsed u: %s", strconv.FormatUint(obj.uintValue, 10))
-			// log.Debug("parsed x: 0x%x", obj.uintValue)
-			return obj, nil
-		case '"':
-			obj = new(JsonValue)
-			obj.valueType = String
-			// search for next quote
-			next := strings.IndexRune(s[index + 1:], '"')
-			if next < 0 {
-				return nil, JsonFormatError
-			} else {
-				obj.stringValue = s[index + 1 : index + 1 + next]
-				return obj, nil
-			}
-		case 't':
-			if s[index:] == "true" {
-				obj = new(JsonValue)
-				obj.valueType = Boolean
-				obj.boolValue = true
-				return obj, nil
-			} else {
-				return nil, JsonFormatError
-			}
-		case 'f':
-			if s[index:] == "false" {
-				obj = new(JsonValue)
-				obj.valueType = Boolean
-				obj.boolValue = false
-				return obj, nil
-			} else {
-				return nil, JsonFormatError
-			}
-		case 'n':
-			if s[index:] == "null" {
-				obj = new(JsonValue)
-				obj.valueType = Null
-				return obj, nil
-			} else {
-				return nil, JsonFormatError
-			}
-		default:
-			// log.Debug("Skip: %c", chr)
-			// skip
-		}
-	}
-	return nil, JsonFormatError
-}
-
-// ====================
-// parse functions
-
-func (obj *JsonValue) parseObject(data []byte) error {
-	add_child := func (obj *JsonValue, key []byte, child *JsonValue) {
-		key_str, key_err := stringFromEscapedBytes(key)
-		if key_err == nil {
-			obj.objChildren[key_str] = child
-		}
+// NewFromString parses a JSON text strictly as defined by RFC 8259. Invalid
+// input gives a *SyntaxError. ParseOptions may be given to limit the input,
+// in which case violations give a *ParseError.
+func NewFromString(s string, opts ...ParseOptions) (*JsonValue, error) {
+	opt := ParseOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
 	}
-
-	jsonparser.ObjectEach(data, func(key []byte, value []byte, dataType jsonparser.ValueType, _ int) error {
-		// log.Debug("----------")
-		// log.Debug("key: %s", string(key))
-		// log.Debug("value: %s", string(value))
-		switch dataType {
-		case jsonparser.String:
-			// log.Debug("string")
-			str_value, err := stringFromEscapedBytes(value)
-			if err == nil {
-				child := NewString(str_value)
-				add_child(obj, key, child)
-			}
-		case jsonparser.Number:
-			// log.Debug("number")
-			child := new(JsonValue)
-			str_value := string(value)
-			child.valueType = Number
-			child.intValue, _ = strconv.ParseInt(str_value, 10, 64)
-			child.floatValue, _ = strconv.ParseFloat(str_value, 64)
-			add_child(obj, key, child)
-		case jsonparser.Object:
-			// log.Debug("object")
-			child := NewObject()
-			err := child.parseObject(value)
-			if err != nil {
-				// log.Error("Failed to parse object: %s", err.Error())
-			} else {
-				// log.Debug("%s ---- object size: %d", string(key), child.Length())
-				add_child(obj, key, child)
-			}
-		case jsonparser.Array:
-			// log.Debug("array")
-			child := NewArray()
-			err := child.parseArray(value)
-			if err != nil {
-				// log.Error("Failed to parse array: %s", err.Error())
-			} else {
-				// log.Debug("%s ---- array size: %d", string(key), child.Length())
-				add_child(obj, key, child)
-			}
-		case jsonparser.Boolean:
-			// log.Debug("bool")
-			b, err := strconv.ParseBool(string(value))
-			if err == nil {
-				child := NewBool(b)
-				add_child(obj, key, child)
-			}
-		case jsonparser.Null:
-			// log.Debug("null")
-			child := NewNull()
-			add_child(obj, key, child)
-		default:
-			// log.Debug("Invalid type: %d", int(dataType))
-		}
-		return nil
-	})
-	return nil
-}
-
-func (obj *JsonValue) parseArray(data []byte) error {
-	jsonparser.ArrayEach(data, func(value []byte, dataType jsonparser.ValueType, _ int, _ error) {
-		switch dataType {
-		case jsonparser.String:
-			str_value, err := stringFromEscapedBytes(value)
-			if err == nil {
-				child := NewString(str_value)
-				obj.arrChildren = append(obj.arrChildren, child)
-			}
-		case jsonparser.Number:
-			child := new(JsonValue)
-			str_value := string(value)
-			child.valueType = Number
-			child.intValue, _ = strconv.ParseInt(str_value, 10, 64)
-			child.floatValue, _ = strconv.ParseFloat(str_value, 64)
-			obj.arrChildren = append(obj.arrChildren, child)
-		case jsonparser.Object:
-			child := NewObject()
-			err := child.parseObject(value)
-			if err == nil {
-				obj.arrChildren = append(obj.arrChildren, child)
-			}
-		case jsonparser.Array:
-			child := NewArray()
-			err := child.parseArray(value)
-			if err == nil {
-				obj.arrChildren = append(obj.arrChildren, child)
-			}
-		case jsonparser.Boolean:
-			b, err := strconv.ParseBool(string(value))
-			if err == nil {
-				child := NewBool(b)
-				obj.arrChildren = append(obj.arrChildren, child)
-			}
-		case jsonparser.Null:
-			child := NewNull()
-			obj.arrChildren = append(obj.arrChildren, child)
-		}
-		return
-	})
-	return nil
+	return parseWithOptions(s, opt)
 }
 
 // ====================
@@ -484,86 +205,31 @@ func (obj *JsonValue) IsBool() bool {
 
 // children access
 func (obj *JsonValue) GetByKey(keys ...string) (*JsonValue, error) {
-	if obj.valueType != Object {
-		return nil, NotAnObjectError
+	path := make(Path, 0, len(keys))
+	for _, k := range keys {
+		path = append(path, k)
 	}
-	if 0 == len(keys) {
-		return obj, nil
-	}
-	if 1 == len(keys) {
-		child, exist := obj.objChildren[keys[0]]
-		if false == exist {
-			return nil, ObjectNotFoundError
-		} else {
-			return child, nil
-		}
-	}
-	// else
-	first_child, exist := obj.objChildren[keys[0]]
-	if false == exist {
-		return nil, ObjectNotFoundError
-	}
-	child, err := first_child.GetByKey(keys[1:]...)
-	if err != nil {
-		return nil, ObjectNotFoundError
-	} else {
-		return child, nil
+	if obj.valueType != Object {
+		return nil, &PathError{Path: path, Index: -1, Expected: Object, Actual: obj.valueType, Err: NotAnObjectError}
 	}
+	return obj.getByPath(path)
 }
 
 func (obj *JsonValue) GetAtIndex(index int) (*JsonValue, error) {
-	if obj.valueType == Array {
-		if index >= 0 && index < obj.Length() {
-			return obj.arrChildren[index], nil
-		} else {
-			// log.Error("request index %d, but length is %d", index, obj.Length())
-			return nil, IndexOutOfBoundsError
-		}
-	} else {
-		return nil, NotAnArrayError
-	}
+	return obj.getByPath(Path{index})
 }
 
 func (obj *JsonValue) Get(first interface{}, keys ...interface{}) (*JsonValue, error) {
-	switch first.(type) {
-	case string:
-		child, err := obj.GetByKey(first.(string))
-		if err != nil {
-			return nil, err
-		} else if len(keys) == 1 {
-			return child.Get(keys[0])
-		} else if len(keys) > 1 {
-			return child.Get(keys[0], keys[1:]...)
-		} else {
-			return child, nil
-		}
-	case int8, uint8, int16, uint16, int32, uint32, int64, uint64, int, uint:
-		value := reflect.ValueOf(first)
-		index := int(value.Int())
-		child, err := obj.GetAtIndex(index)
-		if err != nil {
-			return nil, err
-		} else if len(keys) == 1 {
-			return child.Get(keys[0])
-		} else if len(keys) > 1 {
-			return child.Get(keys[0], keys[1:]...)
-		} else {
-			return child, nil
-		}
-	default:
-		return nil, ParaError
-	}
+	return obj.getByPath(append(Path{first}, keys...))
 }
 
 func (obj *JsonValue) GetString(first interface{}, keys ...interface{}) (string, error) {
-	child, err := obj.Get(first, keys...)
-	if err != nil {
-		return "", err
-	}
-	if false == child.IsString() {
-		return "", NotAStringError
-	}
-	return child.String(), nil
+	var ret string
+	err := obj.getTyped(String, first, keys, func(v *JsonValue, c Coercion) (err error) {
+		ret, err = v.AsString(c)
+		return
+	})
+	return ret, err
 }
 
 // ====================
@@ -583,6 +249,16 @@ func (obj *JsonValue) Marshal(opts... Option) (string, error) {
 		opt = &dftOption
 	}
 
+	if r := newRedactor(opt.Redact); r != nil {
+		redacted := r.apply(nil, obj)
+		if nil == redacted {
+			return "null", nil
+		}
+		plain := *opt
+		plain.Redact = nil
+		return redacted.Marshal(plain)
+	}
+
 	switch obj.valueType {
 	case String:
 		return `"` + escapeJsonString(obj.String(), opt.EnsureAscii) + `"`, nil
@@ -686,7 +362,7 @@ func (obj *JsonValue) Delete(first interface{}, keys ...interface{}) error {
 	default:
 		last_index := len(keys) - 1
 		last_key = &keys[last_index]
-		parent, err = obj.Get(first, keys[1:]...)
+		parent, err = obj.Get(first, keys[:last_index]...)
 		if err != nil {
 			return ObjectNotFoundError
 		}
@@ -710,8 +386,10 @@ func (obj *JsonValue) Delete(first interface{}, keys ...interface{}) error {
 		return nil
 
 	case uint8, int8, uint16, int16, uint32, int32, uint64, int64, int, uint:
-		value := reflect.ValueOf(first)
-		index := int(value.Int())
+		if false == parent.IsArray() {
+			return NotAnArrayError
+		}
+		index, _ := intFromInterface(*last_key)
 		arr_len := len(parent.arrChildren)
 		if index >= 0 && index < arr_len {
 			tail := parent.arrChildren[index+1:]
@@ -759,8 +437,7 @@ func (this *JsonValue) Insert(newOne *JsonValue, index interface{}, keys ...inte
 		}
 		switch index.(type) {
 		case uint8, int8, uint16, int16, uint32, int32, uint64, int64, int, uint:
-			value := reflect.ValueOf(index)
-			index := int(value.Int())
+			index, _ := intFromInterface(index)
 			arr_len := len(this.arrChildren)
 			if index >= 0 && index < arr_len {
 				// ref: [SliceTricks](https://github.com/golang/go/wiki/SliceTricks)
@@ -778,9 +455,9 @@ func (this *JsonValue) Insert(newOne *JsonValue, index interface{}, keys ...inte
 		var err error
 		var child *JsonValue
 		if 1 == keys_count {
-			child, err = this.Get(index, keys[0])
+			child, err = this.Get(index)
 		} else {
-			child, err = this.Get(index, keys[:keys_count-2]...)
+			child, err = this.Get(index, keys[:keys_count-1]...)
 		}
 		if err != nil {
 			return nil, err
@@ -820,8 +497,10 @@ func (this *JsonValue) Set(newOne *JsonValue, first interface{}, keys ...interfa
 			}
 		case uint8, int8, uint16, int16, uint32, int32, uint64, int64, int, uint:
 			if this.IsArray() {
-				value := reflect.ValueOf(first)
-				index := int(value.Int())
+				index, _ := intFromInterface(first)
+				if index < 0 || index >= len(this.arrChildren) {
+					return nil, IndexOutOfBoundsError
+				}
 				this.arrChildren[index] = newOne
 				return newOne, nil
 			} else {
@@ -832,20 +511,13 @@ func (this *JsonValue) Set(newOne *JsonValue, first interface{}, keys ...interfa
 			// log.Error("leaf not a string")
 			return nil, DataTypeError
 		}
-	case 1:
-		child, err := this.Get(first)
-		if err != nil {
-			// log.Error("Failed to get: %s", err.Error())
-			return nil, err
-		}
-		return child.Set(newOne, keys[0])
 	default:
-		child, err := this.Get(first)
+		parent, err := this.getByPath(append(Path{first}, keys[:keys_count-1]...))
 		if err != nil {
 			// log.Error("Failed to get: %s", err.Error())
 			return nil, err
 		}
-		return child.Set(newOne, keys[0], keys[1:]...)
+		return parent.Set(newOne, keys[keys_count-1])
 	}
 }
 
@@ -0,0 +1,51 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func TestGetUint(t *testing.T) {
+	obj, _ := NewFromString(`{"n": 42, "arr": [7]}`)
+	if n, err := obj.GetUint("n"); err != nil || n != 42 {
+		t.Errorf("unexpected GetUint result: %d, %v", n, err)
+	}
+	if n, err := obj.GetUint64("arr", 0); err != nil || n != 7 {
+		t.Errorf("unexpected GetUint64 result: %d, %v", n, err)
+	}
+}
+
+func TestDeleteInsert(t *testing.T) {
+	obj, _ := NewFromString(`{"a": {"b": {"c": 1, "d": 2}, "arr": [1, 3, 4]}}`)
+	if err := obj.Delete("a", "b", "c"); err != nil {
+		t.Errorf("Delete nested key error: %v", err)
+	}
+	if err := obj.Delete("a", "arr", uint(2)); err != nil {
+		t.Errorf("Delete array index error: %v", err)
+	}
+	if err := obj.Delete("a", "b", 0); err != NotAnArrayError {
+		t.Errorf("expected NotAnArrayError, got %v", err)
+	}
+	if _, err := obj.Insert(NewInt(2), "a", "arr", 1); err != nil {
+		t.Errorf("Insert nested error: %v", err)
+	}
+	if arr, _ := obj.Get("a", "arr"); nil == arr {
+		t.Errorf("array not found")
+	} else if s, _ := arr.Marshal(); s != "[1,2,3]" {
+		t.Errorf("unexpected array: %s", s)
+	}
+	if b, _ := obj.Get("a", "b"); b.Length() != 1 {
+		t.Errorf("unexpected object length: %d", b.Length())
+	} else if d, _ := b.GetInt("d"); d != 2 {
+		t.Errorf("unexpected d value: %d", d)
+	}
+}
+
+func TestMergeFromUint(t *testing.T) {
+	to, _ := NewFromString(`{"n": 1}`)
+	from := NewObject()
+	from.SetUint64(18446744073709551615, "n")
+	to.MergeFrom(from)
+	if n, _ := to.GetUint64("n"); n != 18446744073709551615 {
+		t.Errorf("unexpected merged value: %d", n)
+	}
+}
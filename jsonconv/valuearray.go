@@ -0,0 +1,165 @@
+package jsonconv
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Array helpers below return new arrays. Elements are shared with the
+// original array rather than copied, use Clone if they are to be modified
+// separately.
+
+// Filter returns a new array with elements for which fn returns true
+func (obj *JsonValue) Filter(fn func(index int, v *JsonValue) bool) (*JsonValue, error) {
+	if false == obj.IsArray() {
+		return nil, NotAnArrayError
+	}
+	ret := NewArray()
+	for i, child := range obj.arrChildren {
+		if fn(i, child) {
+			ret.arrChildren = append(ret.arrChildren, child)
+		}
+	}
+	return ret, nil
+}
+
+// Map returns a new array with the results of fn on each element. It stops
+// at the first error. A nil result is stored as null.
+func (obj *JsonValue) Map(fn func(index int, v *JsonValue) (*JsonValue, error)) (*JsonValue, error) {
+	if false == obj.IsArray() {
+		return nil, NotAnArrayError
+	}
+	ret := NewArray()
+	ret.arrChildren = make([]*JsonValue, 0, len(obj.arrChildren))
+	for i, child := range obj.arrChildren {
+		v, err := fn(i, child)
+		if err != nil {
+			return nil, err
+		}
+		if nil == v {
+			v = NewNull()
+		}
+		ret.arrChildren = append(ret.arrChildren, v)
+	}
+	return ret, nil
+}
+
+// Unique returns a new array without duplicated elements, keeping the first
+// one of each. Elements are compared deeply, as Equal does.
+func (obj *JsonValue) Unique() (*JsonValue, error) {
+	if false == obj.IsArray() {
+		return nil, NotAnArrayError
+	}
+	ret := NewArray()
+	seen := make(map[string]bool, len(obj.arrChildren))
+	for _, child := range obj.arrChildren {
+		key := canonicalString(child)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ret.arrChildren = append(ret.arrChildren, child)
+	}
+	return ret, nil
+}
+
+// GroupBy returns an object whose keys are values found at path in each
+// element, and whose values are arrays of the matching elements in original
+// order. Keys are the values in an exact JSON form with strings quoted, so
+// that "1" and 1 give keys `"1"` and `1`, and elements without the path are
+// grouped under the empty key.
+func (obj *JsonValue) GroupBy(path ...interface{}) (*JsonValue, error) {
+	if false == obj.IsArray() {
+		return nil, NotAnArrayError
+	}
+	ret := NewObject()
+	for _, child := range obj.arrChildren {
+		key := ""
+		if v, err := child.getByPath(Path(path)); err == nil {
+			key = canonicalString(v)
+		}
+		group, exist := ret.objChildren[key]
+		if false == exist {
+			group = NewArray()
+			ret.objChildren[key] = group
+		}
+		group.arrChildren = append(group.arrChildren, child)
+	}
+	return ret, nil
+}
+
+// Reverse returns a new array with elements in reverse order
+func (obj *JsonValue) Reverse() (*JsonValue, error) {
+	if false == obj.IsArray() {
+		return nil, NotAnArrayError
+	}
+	l := len(obj.arrChildren)
+	ret := NewArray()
+	ret.arrChildren = make([]*JsonValue, l)
+	for i, child := range obj.arrChildren {
+		ret.arrChildren[l-1-i] = child
+	}
+	return ret, nil
+}
+
+// canonicalString returns a key for v so that values reported equal by Equal
+// give equal strings. Numbers are written exactly rather than in the fixed
+// precision Marshal uses.
+func canonicalString(v *JsonValue) string {
+	b := strings.Builder{}
+	writeCanonical(&b, v)
+	return b.String()
+}
+
+func writeCanonical(b *strings.Builder, v *JsonValue) {
+	switch v.valueType {
+	case String:
+		b.WriteString(strconv.Quote(v.stringValue))
+	case Number:
+		switch v.numberKind() {
+		case numberIsInt:
+			b.WriteString(strconv.FormatInt(v.intValue, 10))
+		case numberIsUint:
+			b.WriteString(strconv.FormatUint(v.uintValue, 10))
+		default:
+			f := v.floatValue
+			if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+				// the same key as an integer of equal value
+				b.WriteString(strconv.FormatInt(int64(f), 10))
+			} else {
+				b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+			}
+		}
+	case Boolean:
+		b.WriteString(strconv.FormatBool(v.boolValue))
+	case Object:
+		keys := make([]string, 0, len(v.objChildren))
+		for k := range v.objChildren {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Quote(k))
+			b.WriteByte(':')
+			writeCanonical(b, v.objChildren[k])
+		}
+		b.WriteByte('}')
+	case Array:
+		b.WriteByte('[')
+		for i, child := range v.arrChildren {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonical(b, child)
+		}
+		b.WriteByte(']')
+	default:
+		b.WriteString("null")
+	}
+}
@@ -0,0 +1,92 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func marshalSorted(v *JsonValue) string {
+	s, _ := v.Marshal(Option{ShowNull: true, SortMode: DictAsc})
+	return s
+}
+
+func TestSortArrayByKeys(t *testing.T) {
+	arr, _ := NewFromString(`[
+		{"n": "c", "age": 30},
+		{"n": "a", "age": 25},
+		{"n": "d"},
+		{"n": "b", "age": 30},
+		{"n": "e", "age": null},
+		{"n": "f", "age": 4}
+	]`)
+
+	arr.SortArrayBy("age")
+	if s := marshalSorted(names(arr)); s != `["d","e","f","a","c","b"]` {
+		t.Errorf("unexpected order: %s", s)
+	}
+
+	arr.SortArrayByKeys(SortKey{Path: Path{"age"}, Desc: true, NullsLast: true}, SortKey{Path: Path{"n"}})
+	if s := marshalSorted(names(arr)); s != `["b","c","a","f","d","e"]` {
+		t.Errorf("unexpected order: %s", s)
+	}
+
+	mixed, _ := NewFromString(`["b", 10, null, true, 9.5, "a", [1], {"x": 1}, false]`)
+	mixed.SortArrayBy()
+	if s := marshalSorted(mixed); s != `[null,false,true,9.5,10,"a","b",[1],{"x":1}]` {
+		t.Errorf("unexpected typed order: %s", s)
+	}
+
+	nums, _ := NewFromString(`[3, 1, 2]`)
+	nums.SortArray(func(a, b *JsonValue) bool { return a.Int() > b.Int() })
+	if s := marshalSorted(nums); s != `[3,2,1]` {
+		t.Errorf("unexpected custom order: %s", s)
+	}
+}
+
+func names(arr *JsonValue) *JsonValue {
+	ret, _ := arr.Map(func(_ int, v *JsonValue) (*JsonValue, error) {
+		return v.Get("n")
+	})
+	return ret
+}
+
+func TestArrayHelpers(t *testing.T) {
+	arr, _ := NewFromString(`[{"k": "x", "v": 1}, {"k": "y", "v": 2}, {"k": "x", "v": 1}, {"v": 3}]`)
+
+	filtered, _ := arr.Filter(func(_ int, v *JsonValue) bool {
+		return v.At("v").IntOr(0) > 1
+	})
+	if s := marshalSorted(filtered); s != `[{"k":"y","v":2},{"v":3}]` {
+		t.Errorf("unexpected Filter result: %s", s)
+	}
+
+	unique, _ := arr.Unique()
+	if unique.Length() != 3 {
+		t.Errorf("unexpected Unique result: %s", marshalSorted(unique))
+	}
+
+	groups, _ := arr.GroupBy("k")
+	if s := marshalSorted(groups); s != `{"":[{"v":3}],"\"x\"":[{"k":"x","v":1},{"k":"x","v":1}],"\"y\"":[{"k":"y","v":2}]}` {
+		t.Errorf("unexpected GroupBy result: %s", s)
+	}
+	mixed, _ := NewFromString(`[{"k": "1"}, {"k": 1}, {"k": "null"}, {"k": null}, {}]`)
+	if groups, _ := mixed.GroupBy("k"); groups.Length() != 5 {
+		t.Errorf("unexpected GroupBy result on mixed keys: %s", marshalSorted(groups))
+	}
+
+	small, _ := NewFromString(`[0, 1e-7, 2e-7, 0.0000003, 0.0000001, 1, 1.0]`)
+	if unique, _ := small.Unique(); unique.Length() != 5 {
+		t.Errorf("unexpected Unique result on small numbers: %s", marshalSorted(unique))
+	}
+	if groups, _ := small.GroupBy(); groups.Length() != 5 {
+		t.Errorf("unexpected GroupBy result on small numbers: %d groups", groups.Length())
+	}
+
+	reversed, _ := arr.Reverse()
+	if reversed.At(0, "v").IntOr(0) != 3 || arr.At(0, "v").IntOr(0) != 1 {
+		t.Errorf("unexpected Reverse result: %s", marshalSorted(reversed))
+	}
+
+	if _, err := NewObject().Filter(nil); err != NotAnArrayError {
+		t.Errorf("expected NotAnArrayError, got %v", err)
+	}
+}
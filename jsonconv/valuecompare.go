@@ -0,0 +1,199 @@
+package jsonconv
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	numberIsInt = iota
+	numberIsUint
+	numberIsFloat
+)
+
+// numberKind tells how a Number value should be compared and encoded
+func (obj *JsonValue) numberKind() int {
+	switch {
+	case obj.mustFloat:
+		return numberIsFloat
+	case obj.mustUnsigned && obj.uintValue > math.MaxInt64:
+		return numberIsUint
+	case float64(obj.intValue) == obj.floatValue:
+		return numberIsInt
+	default:
+		return numberIsFloat
+	}
+}
+
+// compareNumbers returns -1, 0 or 1
+func compareNumbers(a, b *JsonValue) int {
+	ka, kb := a.numberKind(), b.numberKind()
+	switch {
+	case numberIsInt == ka && numberIsInt == kb:
+		return compareInt64(a.intValue, b.intValue)
+	case numberIsUint == ka && numberIsUint == kb:
+		if a.uintValue < b.uintValue {
+			return -1
+		} else if a.uintValue > b.uintValue {
+			return 1
+		}
+		return 0
+	case numberIsInt == ka && numberIsUint == kb:
+		return -1
+	case numberIsUint == ka && numberIsInt == kb:
+		return 1
+	}
+
+	fa, fb := a.floatValue, b.floatValue
+	if numberIsUint == ka {
+		fa = float64(a.uintValue)
+	}
+	if numberIsUint == kb {
+		fb = float64(b.uintValue)
+	}
+	if fa < fb {
+		return -1
+	} else if fa > fb {
+		return 1
+	}
+	return 0
+}
+
+func compareInt64(a, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Equal tells whether two values are deeply equal. Numbers are compared by
+// value, so 1 and 1.0 are equal.
+func (obj *JsonValue) Equal(other *JsonValue) bool {
+	if obj == other {
+		return true
+	}
+	if nil == obj || nil == other || obj.valueType != other.valueType {
+		return false
+	}
+	switch obj.valueType {
+	case String:
+		return obj.stringValue == other.stringValue
+	case Number:
+		return 0 == compareNumbers(obj, other)
+	case Boolean:
+		return obj.boolValue == other.boolValue
+	case Null:
+		return true
+	case Object:
+		if len(obj.objChildren) != len(other.objChildren) {
+			return false
+		}
+		for k, child := range obj.objChildren {
+			if false == child.Equal(other.objChildren[k]) {
+				return false
+			}
+		}
+		return true
+	case Array:
+		if len(obj.arrChildren) != len(other.arrChildren) {
+			return false
+		}
+		for i, child := range obj.arrChildren {
+			if false == child.Equal(other.arrChildren[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// typeOrder ranks value types for Compare
+func typeOrder(v *JsonValue) int {
+	if nil == v {
+		return 0
+	}
+	switch v.valueType {
+	case Null:
+		return 0
+	case Boolean:
+		return 1
+	case Number:
+		return 2
+	case String:
+		return 3
+	case Array:
+		return 4
+	case Object:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// Compare returns -1, 0 or 1 comparing a and b. Values of different types
+// are ordered as null < boolean < number < string < array < object, with a
+// nil pointer regarded as null. Numbers compare numerically, strings
+// lexically, arrays element by element, and objects by their sorted keys
+// first and then by values.
+func Compare(a, b *JsonValue) int {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		return compareInt64(int64(ta), int64(tb))
+	}
+	if 0 == ta {
+		return 0
+	}
+
+	switch a.valueType {
+	case Boolean:
+		if a.boolValue == b.boolValue {
+			return 0
+		} else if b.boolValue {
+			return -1
+		}
+		return 1
+	case Number:
+		return compareNumbers(a, b)
+	case String:
+		return strings.Compare(a.stringValue, b.stringValue)
+	case Array:
+		for i := 0; i < len(a.arrChildren) && i < len(b.arrChildren); i++ {
+			if c := Compare(a.arrChildren[i], b.arrChildren[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInt64(int64(len(a.arrChildren)), int64(len(b.arrChildren)))
+	case Object:
+		ka, kb := sortedKeys(a), sortedKeys(b)
+		for i := 0; i < len(ka) && i < len(kb); i++ {
+			if c := strings.Compare(ka[i], kb[i]); c != 0 {
+				return c
+			}
+		}
+		if c := compareInt64(int64(len(ka)), int64(len(kb))); c != 0 {
+			return c
+		}
+		for _, k := range ka {
+			if c := Compare(a.objChildren[k], b.objChildren[k]); c != 0 {
+				return c
+			}
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+func sortedKeys(obj *JsonValue) []string {
+	keys := make([]string, 0, len(obj.objChildren))
+	for k := range obj.objChildren {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
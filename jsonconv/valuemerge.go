@@ -7,8 +7,44 @@ func (to *JsonValue) copyFrom(from *JsonValue) {
 	to.intValue = from.intValue
 	to.floatValue = from.floatValue
 	to.boolValue = from.boolValue
+	to.uintValue = from.uintValue
 	to.objChildren = from.objChildren
 	to.arrChildren = from.arrChildren
+	to.mustSigned = from.mustSigned
+	to.mustUnsigned = from.mustUnsigned
+	to.mustFloat = from.mustFloat
+}
+
+// Clone returns a deep copy of obj
+func (obj *JsonValue) Clone() *JsonValue {
+	if nil == obj {
+		return nil
+	}
+	ret := obj.shallowCopy()
+	for k, child := range ret.objChildren {
+		ret.objChildren[k] = child.Clone()
+	}
+	for i, child := range ret.arrChildren {
+		ret.arrChildren[i] = child.Clone()
+	}
+	return ret
+}
+
+// shallowCopy copies obj with new children containers, while the children
+// themselves are shared.
+func (obj *JsonValue) shallowCopy() *JsonValue {
+	ret := *obj
+	if obj.objChildren != nil {
+		ret.objChildren = make(map[string]*JsonValue, len(obj.objChildren))
+		for k, child := range obj.objChildren {
+			ret.objChildren[k] = child
+		}
+	}
+	if obj.arrChildren != nil {
+		ret.arrChildren = make([]*JsonValue, len(obj.arrChildren))
+		copy(ret.arrChildren, obj.arrChildren)
+	}
+	return &ret
 }
 
 func (to *JsonValue) MergeFrom(from *JsonValue, optList ...Option) error {
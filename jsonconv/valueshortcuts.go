@@ -149,26 +149,24 @@ func (this *JsonValue) InsertFloat(f float64, first interface{}, keys ...interfa
 
 
 // ==== GetXxx ====
+// GetXxx functions accept Coercion flags after the keys, e.g.
+// GetInt("port", CoerceNumericString), to convert values as AsXxx do.
 func (obj *JsonValue) GetInt64(first interface{}, keys ...interface{}) (int64, error) {
-	child, err := obj.Get(first, keys...)
-	if err != nil {
-		return 0, err
-	}
-	if false == child.IsNumber() {
-		return 0, NotANumberError
-	}
-	return child.Int64(), nil
+	var ret int64
+	err := obj.getTyped(Number, first, keys, func(v *JsonValue, c Coercion) (err error) {
+		ret, err = v.AsInt64(c)
+		return
+	})
+	return ret, err
 }
 
 func (obj *JsonValue) GetUint64(first interface{}, keys ...interface{}) (uint64, error) {
-	child, err := obj.Get(first, keys...)
-	if err != nil {
-		return 0, err
-	}
-	if false == child.IsNumber() {
-		return 0, NotANumberError
-	}
-	return child.Uint64(), nil
+	var ret uint64
+	err := obj.getTyped(Number, first, keys, func(v *JsonValue, c Coercion) (err error) {
+		ret, err = v.AsUint64(c)
+		return
+	})
+	return ret, err
 }
 
 func (obj *JsonValue) GetInt32(first interface{}, keys ...interface{}) (int32, error) {
@@ -187,41 +185,30 @@ func (obj *JsonValue) GetInt(first interface{}, keys ...interface{}) (int, error
 }
 
 func (obj *JsonValue) GetUint(first interface{}, keys ...interface{}) (uint, error) {
-	ret, err := obj.GetUint(first, keys...)
+	ret, err := obj.GetUint64(first, keys...)
 	return uint(ret), err
 }
 
 func (obj *JsonValue) GetFloat(first interface{}, keys ...interface{}) (float64, error) {
-	child, err := obj.Get(first, keys...)
-	if err != nil {
-		return 0.0, err
-	}
-	if false == child.IsNumber() {
-		return 0.0, NotANumberError
-	}
-	return child.Float(), nil
+	var ret float64
+	err := obj.getTyped(Number, first, keys, func(v *JsonValue, c Coercion) (err error) {
+		ret, err = v.AsFloat(c)
+		return
+	})
+	return ret, err
 }
 
 func (obj *JsonValue) GetBool(first interface{}, keys ...interface{}) (bool, error) {
-	child, err := obj.Get(first, keys...)
-	if err != nil {
-		return false, err
-	}
-	if false == child.IsBool() {
-		return false, NotABoolError
-	}
-	return child.Bool(), nil
+	var ret bool
+	err := obj.getTyped(Boolean, first, keys, func(v *JsonValue, c Coercion) (err error) {
+		ret, err = v.AsBool(c)
+		return
+	})
+	return ret, err
 }
 
 func (obj *JsonValue) GetBoolean(first interface{}, keys ...interface{}) (bool, error) {
-	child, err := obj.Get(first, keys...)
-	if err != nil {
-		return false, err
-	}
-	if false == child.IsBool() {
-		return false, NotABoolError
-	}
-	return child.Bool(), nil
+	return obj.GetBool(first, keys...)
 }
 
 
@@ -49,3 +49,95 @@ func sortObjects(obj *JsonValue, mode Sort) []*valuePair {
 	}
 	return ret
 }
+
+// SortKey is one ordering criterion of SortArrayByKeys
+type SortKey struct {
+	// Path locates the sorting value inside each element, empty for the
+	// element itself
+	Path Path
+	// Desc sorts in descending order
+	Desc bool
+	// NullsLast puts null and missing values at the end, whatever the order
+	NullsLast bool
+}
+
+// SortArray sorts an array in place with a custom less function. The sort is
+// stable.
+func (obj *JsonValue) SortArray(less func(a, b *JsonValue) bool) error {
+	if false == obj.IsArray() {
+		return NotAnArrayError
+	}
+	if nil == less {
+		return ParaError
+	}
+	arr := obj.arrChildren
+	sort.SliceStable(arr, func(i, j int) bool {
+		return less(arr[i], arr[j])
+	})
+	return nil
+}
+
+// SortArrayBy sorts an array in place in ascending order, by the values at
+// path inside each element. See Compare for the ordering between types.
+// Null and missing values come first.
+func (obj *JsonValue) SortArrayBy(path ...interface{}) error {
+	return obj.SortArrayByKeys(SortKey{Path: Path(path)})
+}
+
+// SortArrayByKeys sorts an array in place by several keys, later keys
+// breaking ties of earlier ones. The sort is stable.
+func (obj *JsonValue) SortArrayByKeys(keys ...SortKey) error {
+	if false == obj.IsArray() {
+		return NotAnArrayError
+	}
+	if 0 == len(keys) {
+		keys = []SortKey{{}}
+	}
+
+	// resolve sorting values only once
+	arr := obj.arrChildren
+	values := make([][]*JsonValue, len(arr))
+	for i, elem := range arr {
+		values[i] = make([]*JsonValue, len(keys))
+		for k, key := range keys {
+			v, err := elem.getByPath(key.Path)
+			if err == nil && false == v.IsNull() {
+				values[i][k] = v
+			}
+		}
+	}
+
+	indexes := make([]int, len(arr))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := values[indexes[i]], values[indexes[j]]
+		for k, key := range keys {
+			switch {
+			case nil == a[k] && nil == b[k]:
+				continue
+			case nil == a[k]:
+				return false == key.NullsLast
+			case nil == b[k]:
+				return key.NullsLast
+			}
+			c := Compare(a[k], b[k])
+			if 0 == c {
+				continue
+			}
+			if key.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+
+	sorted := make([]*JsonValue, len(arr))
+	for i, index := range indexes {
+		sorted[i] = arr[index]
+	}
+	copy(arr, sorted)
+	return nil
+}
@@ -0,0 +1,147 @@
+package jsonconv
+
+type walkKind int
+
+const (
+	walkContinue walkKind = iota
+	walkSkip
+	walkStop
+	walkReplace
+	walkDelete
+)
+
+// WalkAction is returned by the callback of Walk to tell what to do next
+type WalkAction struct {
+	kind  walkKind
+	value *JsonValue
+}
+
+var (
+	// WalkContinue goes on with the traversal
+	WalkContinue = WalkAction{kind: walkContinue}
+	// WalkSkip does not visit children of the current value. It is the same
+	// as WalkContinue in post-order.
+	WalkSkip = WalkAction{kind: walkSkip}
+	// WalkStop ends the traversal, keeping changes made so far
+	WalkStop = WalkAction{kind: walkStop}
+	// WalkDelete removes the current value from its parent. Deleting the
+	// root turns it into null.
+	WalkDelete = WalkAction{kind: walkDelete}
+)
+
+// WalkReplace replaces the current value with v. In pre-order, v is not
+// visited. A nil v is the same as WalkDelete.
+func WalkReplace(v *JsonValue) WalkAction {
+	if nil == v {
+		return WalkDelete
+	}
+	return WalkAction{kind: walkReplace, value: v}
+}
+
+// Walk visits obj and all values below it depth-first in pre-order, i.e. a
+// value before its children, calling fn with the path from obj. Object keys
+// are visited in ascending order. Array indexes in paths refer to positions
+// before any deletion made by the walk.
+//
+// To convert all timestamps into strings, for example:
+//
+//	v.Walk(func(path jsonconv.Path, v *jsonconv.JsonValue) jsonconv.WalkAction {
+//		if key, _ := path[len(path)-1].(string); key == "ts" && v.IsNumber() {
+//			return jsonconv.WalkReplace(jsonconv.NewString(...))
+//		}
+//		return jsonconv.WalkContinue
+//	})
+func (obj *JsonValue) Walk(fn func(path Path, v *JsonValue) WalkAction) {
+	obj.walkRoot(fn, false)
+}
+
+// WalkPostOrder works like Walk, but visits children before their parent, so
+// that fn sees a value after its children have been changed.
+func (obj *JsonValue) WalkPostOrder(fn func(path Path, v *JsonValue) WalkAction) {
+	obj.walkRoot(fn, true)
+}
+
+func (obj *JsonValue) walkRoot(fn func(path Path, v *JsonValue) WalkAction, postOrder bool) {
+	if nil == obj || nil == fn {
+		return
+	}
+	action, _ := walkValue(obj, Path{}, fn, postOrder)
+	switch action.kind {
+	case walkReplace:
+		obj.copyFrom(action.value)
+	case walkDelete:
+		obj.copyFrom(NewNull())
+	}
+}
+
+// walkValue returns the action to apply on v itself, and whether the walk is
+// stopped
+func walkValue(v *JsonValue, path Path, fn func(path Path, v *JsonValue) WalkAction, postOrder bool) (WalkAction, bool) {
+	if false == postOrder {
+		action := fn(path, v)
+		switch action.kind {
+		case walkStop:
+			return WalkContinue, true
+		case walkSkip:
+			return WalkContinue, false
+		case walkReplace, walkDelete:
+			return action, false
+		}
+	}
+
+	if walkChildren(v, path, fn, postOrder) {
+		return WalkContinue, true
+	}
+
+	if postOrder {
+		action := fn(path, v)
+		if walkStop == action.kind {
+			return WalkContinue, true
+		}
+		return action, false
+	}
+	return WalkContinue, false
+}
+
+// walkChildren visits and updates children of v, and returns whether the walk
+// is stopped
+func walkChildren(v *JsonValue, path Path, fn func(path Path, v *JsonValue) WalkAction, postOrder bool) bool {
+	switch v.valueType {
+	case Object:
+		for _, k := range sortedKeys(v) {
+			action, stop := walkValue(v.objChildren[k], childPath(path, k), fn, postOrder)
+			switch action.kind {
+			case walkReplace:
+				v.objChildren[k] = action.value
+			case walkDelete:
+				delete(v.objChildren, k)
+			}
+			if stop {
+				return true
+			}
+		}
+
+	case Array:
+		children := v.arrChildren[:0:0]
+		stopped := false
+		for i, child := range v.arrChildren {
+			if stopped {
+				children = append(children, child)
+				continue
+			}
+			action, stop := walkValue(child, childPath(path, i), fn, postOrder)
+			switch action.kind {
+			case walkReplace:
+				children = append(children, action.value)
+			case walkDelete:
+				// dropped
+			default:
+				children = append(children, child)
+			}
+			stopped = stop
+		}
+		v.arrChildren = children
+		return stopped
+	}
+	return false
+}
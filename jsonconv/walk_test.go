@@ -0,0 +1,64 @@
+package jsonconv
+
+import (
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	v, _ := NewFromString(`{"a": {"ts": 1, "b": [1, "x", 2]}, "c": {"ts": 2}, "secret": {"ts": 3}}`)
+
+	var visited []string
+	v.Walk(func(path Path, child *JsonValue) WalkAction {
+		visited = append(visited, path.String())
+		if len(path) == 0 {
+			return WalkContinue
+		}
+		switch last := path[len(path)-1]; {
+		case last == "secret":
+			return WalkSkip
+		case last == "ts":
+			return WalkReplace(NewString("t" + marshalSorted(child)))
+		case child.IsString():
+			return WalkDelete
+		}
+		return WalkContinue
+	})
+	expected := `{"a":{"b":[1,2],"ts":"t1"},"c":{"ts":"t2"},"secret":{"ts":3}}`
+	if s := marshalSorted(v); s != expected {
+		t.Errorf("unexpected result: %s", s)
+	}
+	if s := marshalSorted(stringArray(visited)); s != `["","a","a.b","a.b[0]","a.b[1]","a.b[2]","a.ts","c","c.ts","secret"]` {
+		t.Errorf("unexpected pre-order: %s", s)
+	}
+
+	visited = nil
+	v.WalkPostOrder(func(path Path, child *JsonValue) WalkAction {
+		visited = append(visited, path.String())
+		if child.IsArray() {
+			// children are already visited and changed
+			return WalkReplace(NewInt(child.Length()))
+		}
+		if child.IsNumber() && child.Int() == 2 {
+			return WalkDelete
+		}
+		if path.String() == "c" {
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	expected = `{"a":{"b":1,"ts":"t1"},"c":{"ts":"t2"},"secret":{"ts":3}}`
+	if s := marshalSorted(v); s != expected {
+		t.Errorf("unexpected result: %s", s)
+	}
+	if s := marshalSorted(stringArray(visited)); s != `["a.b[0]","a.b[1]","a.b","a.ts","a","c.ts","c"]` {
+		t.Errorf("unexpected post-order: %s", s)
+	}
+}
+
+func stringArray(list []string) *JsonValue {
+	arr := NewArray()
+	for _, s := range list {
+		arr.AppendString(s)
+	}
+	return arr
+}
@@ -0,0 +1,337 @@
+package jsonconv
+
+import (
+	"sort"
+	"strconv"
+)
+
+// EventType tells what happened to a value
+type EventType int
+
+const (
+	ValueAdded EventType = iota
+	ValueChanged
+	ValueRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case ValueAdded:
+		return "added"
+	case ValueChanged:
+		return "changed"
+	case ValueRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change at Path. Old is nil for ValueAdded and New is nil
+// for ValueRemoved. Both are read-only snapshots.
+//
+// A change inside an object or array is also reported as ValueChanged on each
+// of its ancestors, and when a subtree is added or removed, every value in it
+// gets its own event.
+type Event struct {
+	Type EventType
+	Path Path
+	Old  *JsonValue
+	New  *JsonValue
+}
+
+type watcher struct {
+	pattern  []string
+	callback func(events []Event)
+}
+
+// Watch registers callback for changes matching pattern. A pattern is a list
+// of keys or array indexes separated by ".", where "*" matches any single
+// segment and "**" matches any number of segments. A dot inside a key can be
+// escaped as "\.".
+//
+// Each committed update calls callback once with all matching events, after
+// the new value is published and outside of any lock, so callback may read or
+// even modify the SyncValue. Updates are delivered one at a time in the order
+// they were committed; an update made while events are being dispatched is
+// delivered after the current ones, possibly after the updating call has
+// returned. A panic in callback goes up to the updating call, and events not
+// delivered yet are dropped. The returned function cancels the watch.
+//
+//	s.Watch("db.*.timeout", func(events []jsonconv.Event) { ... })
+func (s *SyncValue) Watch(pattern string, callback func(events []Event)) (cancel func()) {
+	if nil == callback {
+		return func() {}
+	}
+	w := &watcher{callback: callback}
+	if "" != pattern {
+		w.pattern = splitEscapedFlattenKey(pattern, ".")
+	}
+
+	s.watchLock.Lock()
+	defer s.watchLock.Unlock()
+	if nil == s.watchers {
+		s.watchers = make(map[int]*watcher)
+	}
+	s.watcherSeq++
+	id := s.watcherSeq
+	s.watchers[id] = w
+
+	return func() {
+		s.watchLock.Lock()
+		defer s.watchLock.Unlock()
+		delete(s.watchers, id)
+	}
+}
+
+func (s *SyncValue) hasWatchers() bool {
+	s.watchLock.RLock()
+	defer s.watchLock.RUnlock()
+	return len(s.watchers) > 0
+}
+
+// notify diffs two published snapshots and dispatches events to watchers
+func (s *SyncValue) notify(old, new *JsonValue) {
+	if old == new || false == s.hasWatchers() {
+		return
+	}
+	events := make([]Event, 0, 8)
+	diffValues(Path{}, old, new, &events)
+	if 0 == len(events) {
+		return
+	}
+
+	s.watchLock.RLock()
+	ids := make([]int, 0, len(s.watchers))
+	for id := range s.watchers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	watchers := make([]*watcher, 0, len(ids))
+	for _, id := range ids {
+		watchers = append(watchers, s.watchers[id])
+	}
+	s.watchLock.RUnlock()
+
+	for _, w := range watchers {
+		var matched []Event
+		for _, e := range events {
+			if matchPathPattern(w.pattern, e.Path) {
+				matched = append(matched, e)
+			}
+		}
+		if len(matched) > 0 {
+			w.callback(matched)
+		}
+	}
+}
+
+// matchPathPattern matches a path against segments of a pattern, in which
+// "*" matches one segment and "**" matches zero or more segments.
+func matchPathPattern(pattern []string, path Path) bool {
+	if 0 == len(pattern) {
+		return 0 == len(path)
+	}
+	if "**" == pattern[0] {
+		for i := 0; i <= len(path); i++ {
+			if matchPathPattern(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if 0 == len(path) {
+		return false
+	}
+	if "*" != pattern[0] && pattern[0] != pathSegmentString(path[0]) {
+		return false
+	}
+	return matchPathPattern(pattern[1:], path[1:])
+}
+
+func pathSegmentString(seg interface{}) string {
+	if s, ok := seg.(string); ok {
+		return s
+	}
+	if i, ok := intFromInterface(seg); ok {
+		return strconv.Itoa(i)
+	}
+	return ""
+}
+
+func childPath(path Path, seg interface{}) Path {
+	return append(path[:len(path):len(path)], seg)
+}
+
+// diffValues appends events for every difference between old and new in
+// pre-order, and returns whether anything differs. Subtrees shared between
+// the two snapshots are skipped without looking into them.
+func diffValues(path Path, old, new *JsonValue, events *[]Event) bool {
+	if old == new {
+		return false
+	}
+	if nil == old {
+		subtreeEvents(ValueAdded, path, new, events)
+		return true
+	}
+	if nil == new {
+		subtreeEvents(ValueRemoved, path, old, events)
+		return true
+	}
+
+	sameContainer := old.valueType == new.valueType && (old.IsObject() || old.IsArray())
+	if false == sameContainer {
+		if old.Equal(new) {
+			return false
+		}
+		*events = append(*events, Event{Type: ValueChanged, Path: path, Old: old, New: new})
+		forEachChild(old, func(seg interface{}, child *JsonValue) {
+			subtreeEvents(ValueRemoved, childPath(path, seg), child, events)
+		})
+		forEachChild(new, func(seg interface{}, child *JsonValue) {
+			subtreeEvents(ValueAdded, childPath(path, seg), child, events)
+		})
+		return true
+	}
+
+	placeholder := len(*events)
+	*events = append(*events, Event{Type: ValueChanged, Path: path, Old: old, New: new})
+	changed := false
+	if old.IsObject() {
+		keys := make([]string, 0, len(old.objChildren)+len(new.objChildren))
+		for k := range old.objChildren {
+			keys = append(keys, k)
+		}
+		for k := range new.objChildren {
+			if _, exist := old.objChildren[k]; false == exist {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if diffValues(childPath(path, k), old.objChildren[k], new.objChildren[k], events) {
+				changed = true
+			}
+		}
+	} else {
+		l := len(old.arrChildren)
+		if len(new.arrChildren) > l {
+			l = len(new.arrChildren)
+		}
+		for i := 0; i < l; i++ {
+			var o, n *JsonValue
+			if i < len(old.arrChildren) {
+				o = old.arrChildren[i]
+			}
+			if i < len(new.arrChildren) {
+				n = new.arrChildren[i]
+			}
+			if diffValues(childPath(path, i), o, n, events) {
+				changed = true
+			}
+		}
+	}
+	if false == changed {
+		*events = (*events)[:placeholder]
+	}
+	return changed
+}
+
+func subtreeEvents(t EventType, path Path, v *JsonValue, events *[]Event) {
+	e := Event{Type: t, Path: path}
+	if ValueAdded == t {
+		e.New = v
+	} else {
+		e.Old = v
+	}
+	*events = append(*events, e)
+	forEachChild(v, func(seg interface{}, child *JsonValue) {
+		subtreeEvents(t, childPath(path, seg), child, events)
+	})
+}
+
+// forEachChild visits children of objects in key order, and of arrays in
+// index order.
+func forEachChild(v *JsonValue, callback func(seg interface{}, child *JsonValue)) {
+	switch v.valueType {
+	case Object:
+		for _, k := range sortedKeys(v) {
+			callback(k, v.objChildren[k])
+		}
+	case Array:
+		for i, child := range v.arrChildren {
+			callback(i, child)
+		}
+	}
+}
+
+// ====================
+// transaction
+
+// Transaction collects changes on a private copy of a SyncValue. Commit
+// publishes them at once, so that watchers get a single set of events.
+type Transaction struct {
+	s     *SyncValue
+	base  *JsonValue
+	value *JsonValue
+	done  bool
+}
+
+// Begin starts a transaction based on the current snapshot
+func (s *SyncValue) Begin() *Transaction {
+	base := s.Load()
+	return &Transaction{s: s, base: base, value: base.Clone()}
+}
+
+// Value returns the private copy in the transaction, which may be modified
+// freely before Commit.
+func (tx *Transaction) Value() *JsonValue {
+	return tx.value
+}
+
+func (tx *Transaction) Set(newOne *JsonValue, first interface{}, keys ...interface{}) error {
+	_, err := tx.value.Set(newOne, first, keys...)
+	return err
+}
+
+func (tx *Transaction) Delete(first interface{}, keys ...interface{}) error {
+	return tx.value.Delete(first, keys...)
+}
+
+func (tx *Transaction) Append(newOne *JsonValue, keys ...interface{}) error {
+	_, err := tx.value.Append(newOne, keys...)
+	return err
+}
+
+func (tx *Transaction) Insert(newOne *JsonValue, first interface{}, keys ...interface{}) error {
+	_, err := tx.value.Insert(newOne, first, keys...)
+	return err
+}
+
+func (tx *Transaction) MergeFrom(from *JsonValue, optList ...Option) error {
+	return tx.value.MergeFrom(from, optList...)
+}
+
+// Commit publishes the changes. It fails with TransactionConflictError if
+// the SyncValue was modified after Begin, in which case nothing is changed.
+func (tx *Transaction) Commit() error {
+	if tx.done {
+		return TransactionDoneError
+	}
+	tx.done = true
+
+	s := tx.s
+	s.lock.Lock()
+	old := s.Load()
+	if old != tx.base {
+		s.lock.Unlock()
+		return TransactionConflictError
+	}
+	s.publish(old, shareUnchanged(old, tx.value.Clone()))
+	return nil
+}
+
+// Rollback drops the changes
+func (tx *Transaction) Rollback() {
+	tx.done = true
+}
@@ -0,0 +1,149 @@
+package jsonconv
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSyncValueWatch(t *testing.T) {
+	init, _ := NewFromString(`{"db": {"master": {"timeout": 1}, "slave": {"timeout": 2}}, "log": "info"}`)
+	s := NewSyncValue(init)
+
+	var got []Event
+	cancel := s.Watch("db.*.timeout", func(events []Event) {
+		got = append(got, events...)
+	})
+	batches := 0
+	s.Watch("**", func(events []Event) {
+		batches++
+	})
+
+	s.Set(NewInt(5), "db", "master", "timeout")
+	if len(got) != 1 || got[0].Type != ValueChanged || got[0].Path.String() != "db.master.timeout" {
+		t.Errorf("unexpected events: %+v", got)
+		return
+	}
+	if got[0].Old.Int() != 1 || got[0].New.Int() != 5 {
+		t.Errorf("unexpected old/new values: %v, %v", got[0].Old.Int(), got[0].New.Int())
+	}
+
+	got = nil
+	s.Set(NewString("debug"), "log")
+	if len(got) != 0 {
+		t.Errorf("unexpected events: %+v", got)
+	}
+
+	got = nil
+	patch, _ := NewFromString(`{"db": {"backup": {"timeout": 3}}}`)
+	s.MergeFrom(patch)
+	if len(got) != 1 || got[0].Type != ValueAdded || got[0].Path.String() != "db.backup.timeout" {
+		t.Errorf("unexpected events: %+v", got)
+	}
+
+	got = nil
+	s.Delete("db", "slave")
+	if len(got) != 1 || got[0].Type != ValueRemoved || got[0].Old.Int() != 2 {
+		t.Errorf("unexpected events: %+v", got)
+	}
+
+	got = nil
+	batches = 0
+	tx := s.Begin()
+	tx.Set(NewInt(10), "db", "master", "timeout")
+	tx.Set(NewInt(30), "db", "backup", "timeout")
+	tx.Set(NewArray(), "list")
+	tx.Append(NewInt(1), "list")
+	if len(got) != 0 {
+		t.Errorf("events before commit: %+v", got)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Errorf("Commit error: %v", err)
+	}
+	if len(got) != 2 || batches != 1 {
+		t.Errorf("expected 2 events in 1 batch, got %+v in %d batches", got, batches)
+	}
+	if err := tx.Commit(); err != TransactionDoneError {
+		t.Errorf("expected TransactionDoneError, got %v", err)
+	}
+
+	tx = s.Begin()
+	tx.Set(NewInt(0), "db", "master", "timeout")
+	s.Append(NewInt(2), "list")
+	if err := tx.Commit(); err != TransactionConflictError {
+		t.Errorf("expected TransactionConflictError, got %v", err)
+	}
+
+	cancel()
+	got = nil
+	s.Set(NewInt(6), "db", "master", "timeout")
+	if len(got) != 0 {
+		t.Errorf("events after cancel: %+v", got)
+	}
+}
+
+func TestSyncValueWatchOrder(t *testing.T) {
+	init, _ := NewFromString(`{"n": 0, "big": {"list": [1, 2, 3]}}`)
+	s := NewSyncValue(init)
+	big, _ := s.Get("big")
+
+	var seen []int64
+	s.Watch("n", func(events []Event) {
+		seen = append(seen, events[0].New.Int64())
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Update(func(v *JsonValue) error {
+					n, _ := v.GetInt64("n")
+					_, err := v.SetInt64(n+1, "n")
+					return err
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 800 {
+		t.Fatalf("expected 800 events, got %d", len(seen))
+	}
+	for i, n := range seen {
+		if n != int64(i+1) {
+			t.Fatalf("event %d delivered out of order: %d", i, n)
+		}
+	}
+	if curr, _ := s.Get("big"); curr != big {
+		t.Errorf("unchanged subtree should stay shared between snapshots")
+	}
+}
+
+func TestSyncValueWatchPanic(t *testing.T) {
+	s := NewSyncValue(nil)
+	var got []Event
+	s.Watch("n", func(events []Event) {
+		if events[0].New.Int() == 1 {
+			panic("watcher failed")
+		}
+		got = append(got, events...)
+	})
+
+	func() {
+		defer func() {
+			if r := recover(); nil == r {
+				t.Errorf("expected the watcher panic to reach Set")
+			}
+		}()
+		s.Set(NewInt(1), "n")
+	}()
+
+	s.Set(NewInt(2), "n")
+	if len(got) != 1 || got[0].New.Int() != 2 {
+		t.Errorf("update after panic not delivered: %+v", got)
+	}
+	if s.At("n").IntOr(0) != 2 {
+		t.Errorf("unexpected value %d", s.At("n").IntOr(0))
+	}
+}
@@ -0,0 +1,671 @@
+package xmlconv
+
+import (
+	"encoding"
+	"encoding/xml"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PathError tells where Decode or Encode failed, e.g. "/xml/total_fee" or
+// "/xml/item[2]/@id"
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return "xmlconv: " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// TimeLayouts are tried in order by Decode to parse time.Time values.
+// Encode writes time.Time with the first one.
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"20060102150405",
+}
+
+var (
+	xmlNameType         = reflect.TypeOf(xml.Name{})
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// fieldInfo is a struct field with its parsed xml tag
+type fieldInfo struct {
+	index     []int
+	path      []string // element names, or the attribute name
+	attr      bool
+	chardata  bool
+	cdata     bool
+	innerxml  bool
+	comment   bool
+	omitempty bool
+	xmlName   bool
+}
+
+// tagName converts the "uri local" form of encoding/xml into "{uri}local"
+func tagName(n string) string {
+	if i := strings.LastIndexByte(n, ' '); i > 0 {
+		return "{" + n[:i] + "}" + n[i+1:]
+	}
+	return n
+}
+
+// structFields lists fields of t in the order of declaration, flattening
+// embedded structs without a tag
+func structFields(t reflect.Type, index []int) []fieldInfo {
+	var ret []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("xml")
+		if "-" == tag {
+			continue
+		}
+		idx := append(append([]int{}, index...), i)
+
+		if f.Anonymous && false == hasTag {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				ret = append(ret, structFields(ft, idx)...)
+				continue
+			}
+		}
+		if "" != f.PkgPath {
+			continue
+		}
+
+		info := fieldInfo{index: idx}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		for _, opt := range parts[1:] {
+			switch strings.TrimSpace(opt) {
+			case "attr":
+				info.attr = true
+			case "chardata":
+				info.chardata = true
+			case "cdata":
+				info.cdata = true
+			case "innerxml":
+				info.innerxml = true
+			case "comment":
+				info.comment = true
+			case "omitempty":
+				info.omitempty = true
+			}
+		}
+		switch {
+		case "XMLName" == f.Name:
+			if f.Type != xmlNameType {
+				continue
+			}
+			info.xmlName = true
+			info.path = []string{tagName(name)}
+		case info.attr:
+			if "" == name {
+				name = f.Name
+			}
+			info.path = []string{tagName(name)}
+		case info.chardata || info.cdata || info.innerxml || info.comment:
+			// no name
+		default:
+			if "" == name {
+				name = f.Name
+			}
+			for _, n := range strings.Split(name, ">") {
+				info.path = append(info.path, tagName(strings.TrimSpace(n)))
+			}
+		}
+		ret = append(ret, info)
+	}
+	return ret
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex allocating nil embedded pointers
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// ====================
+// decode
+
+// Decode stores item into dst, which should be a non-nil pointer, in the way
+// encoding/xml does with the same struct tags:
+//
+//   - `xml:"name"` or `xml:"a>b>c"` reads child elements by path, where names
+//     may be "prefix:local" or "uri local". A field without tag reads the
+//     element with the field name.
+//   - `xml:"name,attr"` reads an attribute.
+//   - `xml:",chardata"` or `xml:",cdata"` reads the text of the element.
+//   - `xml:",innerxml"` reads everything inside the element as marshalled.
+//   - An xml.Name field named XMLName receives the name of the element.
+//
+// Slices collect all matching elements, other fields read the first one.
+// Text is converted into strings, numbers, booleans, time.Time by
+// TimeLayouts, []byte or encoding.TextUnmarshaler. map[string]string reads
+// the text of each child element. Errors are *PathError.
+func Decode(item *Item, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if nil == item || v.Kind() != reflect.Ptr || v.IsNil() {
+		return ParaError
+	}
+	return decodeElement(item, v.Elem(), "/"+item.QName())
+}
+
+func decodeElement(item *Item, v reflect.Value, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeElement(item, v.Elem(), path)
+	}
+	if isTextType(v) {
+		return decodeText(item.String(), v, path)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return decodeStruct(item, v, path)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return &PathError{Path: path, Err: ParaError}
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for _, c := range item.ChildList() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeElement(c, e, path+"/"+c.QName()); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(c.name).Convert(v.Type().Key()), e)
+		}
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return &PathError{Path: path, Err: ParaError}
+		}
+		v.Set(reflect.ValueOf(item.String()))
+		return nil
+	default:
+		return decodeText(item.String(), v, path)
+	}
+}
+
+func decodeStruct(item *Item, v reflect.Value, path string) error {
+	for _, f := range structFields(v.Type(), nil) {
+		fv := fieldByIndex(v, f.index)
+		switch {
+		case f.xmlName:
+			fv.Set(reflect.ValueOf(xml.Name{Space: item.space, Local: item.name}))
+
+		case f.attr:
+			s, exist := item.GetAttr(f.path[0])
+			if false == exist {
+				continue
+			}
+			if err := decodeText(s, fv, path+"/@"+f.path[0]); err != nil {
+				return err
+			}
+
+		case f.chardata || f.cdata:
+			if err := decodeText(item.String(), fv, path); err != nil {
+				return err
+			}
+
+		case f.innerxml:
+			s := innerXML(item)
+			if fv.Kind() == reflect.String {
+				fv.SetString(s)
+			} else if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
+				fv.SetBytes([]byte(s))
+			}
+
+		case f.comment:
+			var b []byte
+			for _, c := range item.child {
+				if CommentNode == c.kind {
+					b = append(b, c.data...)
+				}
+			}
+			if err := decodeText(string(b), fv, path); err != nil {
+				return err
+			}
+
+		default:
+			if err := decodeChildren(item, fv, f.path, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodeChildren(item *Item, fv reflect.Value, names []string, path string) error {
+	children := item.GetChildren(names[0], names[1:]...)
+	if 0 == len(children) {
+		return nil
+	}
+	childPath := path + "/" + strings.Join(names, "/")
+
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		s := reflect.MakeSlice(fv.Type(), len(children), len(children))
+		for i, c := range children {
+			p := childPath + "[" + strconv.Itoa(i+1) + "]"
+			if err := decodeElement(c, s.Index(i), p); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+	return decodeElement(children[0], fv, childPath)
+}
+
+// isTextType tells whether v is read and written as text as a whole
+func isTextType(v reflect.Value) bool {
+	t := v.Type()
+	if t == timeType {
+		return true
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return true
+	}
+	return reflect.PtrTo(t).Implements(textUnmarshalerType) || t.Implements(textMarshalerType)
+}
+
+func decodeText(s string, v reflect.Value, path string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeText(s, v.Elem(), path)
+	}
+	if v.Type() == timeType {
+		if "" == s {
+			return nil
+		}
+		var err error
+		for _, layout := range TimeLayouts {
+			var t time.Time
+			if t, err = time.Parse(layout, s); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return &PathError{Path: path, Err: err}
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return &PathError{Path: path, Err: err}
+		}
+		return nil
+	}
+
+	var err error
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		if "" != s {
+			b, err = strconv.ParseBool(s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if "" != s {
+			i, err = strconv.ParseInt(s, 10, v.Type().Bits())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if "" != s {
+			u, err = strconv.ParseUint(s, 10, v.Type().Bits())
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if "" != s {
+			f, err = strconv.ParseFloat(s, v.Type().Bits())
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return &PathError{Path: path, Err: ParaError}
+		}
+		v.SetBytes([]byte(s))
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return &PathError{Path: path, Err: ParaError}
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return &PathError{Path: path, Err: ParaError}
+	}
+	if err != nil {
+		return &PathError{Path: path, Err: err}
+	}
+	return nil
+}
+
+// innerXML writes child nodes of item as they were parsed
+func innerXML(item *Item) string {
+	opt := Option{Preserve: true}
+	var buff strings.Builder
+	for _, c := range item.child {
+		b, _ := c.MarshalBytes(opt)
+		buff.Write(b)
+	}
+	return buff.String()
+}
+
+// ====================
+// encode
+
+// Encode converts a struct, or a pointer to it, into an Item, with the same
+// struct tags as Decode. Besides, `xml:",cdata"` writes text in CDATA,
+// `xml:",comment"` writes a comment and `omitempty` omits zero values. The
+// root element is named by the XMLName field, or the type name.
+//
+// A map[string]string is converted into an "xml" element with children in
+// ascending order of keys, as payment platforms such as WeChat Pay use.
+func Encode(src interface{}) (*Item, error) {
+	v := reflect.ValueOf(src)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, ParaError
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		name := v.Type().Name()
+		for _, f := range structFields(v.Type(), nil) {
+			if false == f.xmlName {
+				continue
+			}
+			if n := fieldByIndex(v, f.index).Interface().(xml.Name); "" != n.Local {
+				name = n.Local
+				if "" != n.Space {
+					name = "{" + n.Space + "}" + n.Local
+				}
+			} else if "" != f.path[0] {
+				name = f.path[0]
+			}
+		}
+		if "" == name {
+			return nil, ParaError
+		}
+		item := newNamedItem(name)
+		if err := encodeStruct(item, v, "/"+item.QName()); err != nil {
+			return nil, err
+		}
+		return item, nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, ParaError
+		}
+		item := NewItem("xml")
+		if err := encodeMap(item, v, "/xml"); err != nil {
+			return nil, err
+		}
+		return item, nil
+
+	default:
+		return nil, ParaError
+	}
+}
+
+// newNamedItem creates an element named "{uri}local" or "prefix:local"
+func newNamedItem(name string) *Item {
+	space, prefix, local := (*Item)(nil).splitName(name)
+	ret := NewItem(local)
+	ret.space, ret.prefix = space, prefix
+	return ret
+}
+
+func encodeMap(item *Item, v reflect.Value, path string) error {
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c := item.AppendChild(NewItem(k))
+		mv := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+		if err := encodeElement(c, mv, path+"/"+k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeStruct(item *Item, v reflect.Value, path string) error {
+	for _, f := range structFields(v.Type(), nil) {
+		fv, ok := safeFieldByIndex(v, f.index)
+		if false == ok || f.xmlName {
+			continue
+		}
+		if f.omitempty && isEmptyValue(fv) {
+			continue
+		}
+
+		switch {
+		case f.attr:
+			s, err := encodeText(fv, path+"/@"+f.path[0])
+			if err != nil {
+				return err
+			}
+			space, prefix, local := item.splitName(f.path[0])
+			qname := local
+			if "" != prefix {
+				qname = prefix + ":" + local
+			}
+			item.SetAttrNS(space, qname, s)
+
+		case f.chardata || f.cdata:
+			s, err := encodeText(fv, path)
+			if err != nil {
+				return err
+			}
+			if f.cdata {
+				item.AppendChild(NewCDATA(s))
+			} else {
+				item.AppendChild(NewText(s))
+			}
+
+		case f.comment:
+			s, err := encodeText(fv, path)
+			if err != nil {
+				return err
+			}
+			item.AppendChild(NewComment(s))
+
+		case f.innerxml:
+			s, err := encodeText(fv, path)
+			if err != nil {
+				return err
+			}
+			if "" == s {
+				continue
+			}
+			wrapper, err := NewFromString("<x>" + s + "</x>")
+			if err != nil {
+				return &PathError{Path: path, Err: err}
+			}
+			for _, c := range wrapper.Nodes() {
+				item.AppendChild(c)
+			}
+
+		default:
+			if err := encodeChildren(item, fv, f.path, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// safeFieldByIndex returns false for fields in nil embedded pointers
+func safeFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func encodeChildren(item *Item, fv reflect.Value, names []string, path string) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	parent := item
+	for _, n := range names[:len(names)-1] {
+		parent = parent.childOrNew(n)
+	}
+	last := names[len(names)-1]
+	childPath := path + "/" + strings.Join(names, "/")
+
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 || fv.Kind() == reflect.Array {
+		for i := 0; i < fv.Len(); i++ {
+			c := parent.AppendChild(NewItem(""))
+			parent.rename(c, last)
+			if err := encodeElement(c, fv.Index(i), childPath+"["+strconv.Itoa(i+1)+"]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	c := parent.AppendChild(NewItem(""))
+	parent.rename(c, last)
+	return encodeElement(c, fv, childPath)
+}
+
+func encodeElement(item *Item, v reflect.Value, path string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if isTextType(v) {
+		s, err := encodeText(v, path)
+		if err != nil {
+			return err
+		}
+		item.SetString(s)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return encodeStruct(item, v, path)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return &PathError{Path: path, Err: ParaError}
+		}
+		return encodeMap(item, v, path)
+	default:
+		s, err := encodeText(v, path)
+		if err != nil {
+			return err
+		}
+		item.SetString(s)
+		return nil
+	}
+}
+
+func encodeText(v reflect.Value, path string) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		return t.Format(TimeLayouts[0]), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", &PathError{Path: path, Err: err}
+		}
+		return string(b), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", &PathError{Path: path, Err: ParaError}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return false == v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
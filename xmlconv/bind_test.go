@@ -0,0 +1,145 @@
+package xmlconv
+
+import (
+	"encoding/xml"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type payNotify struct {
+	XMLName    xml.Name  `xml:"xml"`
+	AppID      string    `xml:"appid"`
+	TotalFee   int64     `xml:"total_fee"`
+	Subscribe  bool      `xml:"is_subscribe"`
+	TimeEnd    time.Time `xml:"time_end"`
+	Coupons    []coupon  `xml:"coupons>coupon"`
+	Rate       *float64  `xml:"rate,omitempty"`
+	Attach     string    `xml:"attach,omitempty"`
+	Version    int       `xml:"version,attr"`
+	Ignored    string    `xml:"-"`
+	unexported string
+}
+
+type coupon struct {
+	ID   string `xml:"id,attr"`
+	Fee  uint32 `xml:",chardata"`
+	Note []byte `xml:"-"`
+}
+
+const payNotifyXML = `<xml version="2"><appid><![CDATA[wx2421b1c4370ec43b]]></appid>` +
+	`<total_fee>100</total_fee><is_subscribe>true</is_subscribe><time_end>20140903131540</time_end>` +
+	`<coupons><coupon id="a">10</coupon><coupon id="b">20</coupon></coupons><rate>0.5</rate></xml>`
+
+func TestDecode(t *testing.T) {
+	x, err := NewFromString(payNotifyXML)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+	var n payNotify
+	if err := Decode(x, &n); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if n.XMLName.Local != "xml" || n.AppID != "wx2421b1c4370ec43b" || n.TotalFee != 100 || false == n.Subscribe || n.Version != 2 {
+		t.Errorf("unexpected result: %+v", n)
+	}
+	if false == n.TimeEnd.Equal(time.Date(2014, 9, 3, 13, 15, 40, 0, time.UTC)) {
+		t.Errorf("unexpected time: %v", n.TimeEnd)
+	}
+	if len(n.Coupons) != 2 || n.Coupons[0].ID != "a" || n.Coupons[1].Fee != 20 {
+		t.Errorf("unexpected coupons: %+v", n.Coupons)
+	}
+	if nil == n.Rate || *n.Rate != 0.5 {
+		t.Errorf("unexpected rate: %v", n.Rate)
+	}
+
+	m := map[string]string{}
+	if err := Decode(x, &m); err != nil {
+		t.Fatalf("Decode map error: %v", err)
+	}
+	if m["appid"] != "wx2421b1c4370ec43b" || m["total_fee"] != "100" {
+		t.Errorf("unexpected map: %v", m)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	x, _ := NewFromString(`<xml><coupons><coupon>1</coupon><coupon>x</coupon></coupons></xml>`)
+	var n payNotify
+	err := Decode(x, &n)
+	pe, ok := err.(*PathError)
+	if false == ok {
+		t.Fatalf("expected *PathError, got %v", err)
+	}
+	if pe.Path != "/xml/coupons/coupon[2]" {
+		t.Errorf("unexpected path: %s", pe.Path)
+	}
+	var numErr *strconv.NumError
+	if false == errors.As(err, &numErr) {
+		t.Errorf("strconv error not wrapped: %v", err)
+	}
+
+	if err := Decode(x, n); err != ParaError {
+		t.Errorf("expected ParaError, got %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	n := payNotify{
+		AppID:    "wx",
+		TotalFee: 100,
+		TimeEnd:  time.Date(2014, 9, 3, 13, 15, 40, 0, time.UTC),
+		Coupons:  []coupon{{ID: "a", Fee: 10}, {ID: "b", Fee: 20}},
+		Version:  2,
+		Ignored:  "x",
+	}
+	x, err := Encode(&n)
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	s, _ := x.Marshal()
+	expected := `<xml version="2"><appid>wx</appid><total_fee>100</total_fee><is_subscribe>false</is_subscribe>` +
+		`<time_end>2014-09-03T13:15:40Z</time_end><coupons><coupon id="a">10</coupon><coupon id="b">20</coupon></coupons></xml>`
+	if s != expected {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	// round trip
+	var back payNotify
+	if err := Decode(x, &back); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if back.AppID != n.AppID || len(back.Coupons) != 2 || false == back.TimeEnd.Equal(n.TimeEnd) {
+		t.Errorf("unexpected round trip result: %+v", back)
+	}
+
+	x, _ = Encode(map[string]string{"b": "2", "a": "1"})
+	if s, _ := x.Marshal(); s != `<xml><a>1</a><b>2</b></xml>` {
+		t.Errorf("unexpected map result: %s", s)
+	}
+}
+
+func TestEncodeSpecial(t *testing.T) {
+	type doc struct {
+		XMLName xml.Name `xml:"urn:doc root"`
+		Note    string   `xml:",comment"`
+		Body    string   `xml:",cdata"`
+		Inner   string   `xml:",innerxml"`
+	}
+	x, err := Encode(doc{Note: " hi ", Body: "a<b", Inner: "<x>1</x>"})
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	s, _ := x.Marshal(Option{Preserve: true})
+	if s != `<root xmlns="urn:doc"><!-- hi --><![CDATA[a<b]]><x>1</x></root>` {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	var d doc
+	if err := Decode(x, &d); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if d.XMLName.Space != "urn:doc" || d.Body != "a<b" || d.Note != " hi " || d.Inner != `<!-- hi --><![CDATA[a<b]]><x>1</x>` {
+		t.Errorf("unexpected decode result: %+v", d)
+	}
+}
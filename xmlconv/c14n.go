@@ -0,0 +1,323 @@
+package xmlconv
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Canonicalization methods, as used in the Algorithm attributes of XML-DSig
+const (
+	// C14N10 is Canonical XML 1.0, without comments
+	C14N10 = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	// C14N10WithComments is Canonical XML 1.0 with comments
+	C14N10WithComments = C14N10 + "#WithComments"
+	// ExcC14N is Exclusive XML Canonicalization 1.0, without comments
+	ExcC14N = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	// ExcC14NWithComments is Exclusive XML Canonicalization 1.0 with
+	// comments
+	ExcC14NWithComments = ExcC14N + "WithComments"
+)
+
+// Canonicalize writes the subtree of element x in canonical form by method,
+// one of C14N10, C14N10WithComments, ExcC14N and ExcC14NWithComments.
+// inclusivePrefixes is the InclusiveNamespaces PrefixList of exclusive
+// methods, with "#default" for the default namespace.
+//
+// Namespaces declared on ancestors are rendered on x, all of them by
+// Canonical XML and only those used by exclusive methods. A root element
+// parsed from a document is written with comments and processing
+// instructions of its prolog and epilog.
+func (x *Item) Canonicalize(method string, inclusivePrefixes ...string) ([]byte, error) {
+	c, err := newCanonicalizer(method, inclusivePrefixes)
+	if err != nil {
+		return nil, err
+	}
+	if nil == x || ElementNode != x.kind {
+		return nil, ParaError
+	}
+	c.document(x)
+	return c.buff.Bytes(), nil
+}
+
+type canonicalizer struct {
+	buff      bytes.Buffer
+	exclusive bool
+	comments  bool
+	inclusive map[string]bool
+	skip      *Item // the enveloped signature
+}
+
+func newCanonicalizer(method string, inclusivePrefixes []string) (*canonicalizer, error) {
+	c := &canonicalizer{}
+	switch method {
+	case C14N10:
+	case C14N10WithComments:
+		c.comments = true
+	case ExcC14N:
+		c.exclusive = true
+	case ExcC14NWithComments:
+		c.exclusive, c.comments = true, true
+	default:
+		return nil, ParaError
+	}
+	if c.exclusive {
+		c.inclusive = map[string]bool{}
+		for _, p := range inclusivePrefixes {
+			if "#default" == p {
+				p = ""
+			}
+			c.inclusive[p] = true
+		}
+	}
+	return c, nil
+}
+
+func (c *canonicalizer) document(x *Item) {
+	if nil != x.parent {
+		c.element(x, x.parent.c14nScope(), nil, true)
+		return
+	}
+	for _, n := range x.prolog {
+		if c.outside(n) {
+			c.node(n)
+			c.buff.WriteByte('\n')
+		}
+	}
+	c.element(x, nil, nil, true)
+	for _, n := range x.epilog {
+		if c.outside(n) {
+			c.buff.WriteByte('\n')
+			c.node(n)
+		}
+	}
+}
+
+// outside tells whether a node outside the root element is written, i.e.
+// processing instructions other than the XML declaration, and comments if
+// required
+func (c *canonicalizer) outside(n *Item) bool {
+	switch n.kind {
+	case ProcInstNode:
+		return "xml" != n.name
+	case CommentNode:
+		return c.comments
+	}
+	return false
+}
+
+// c14nDecls returns namespaces declared on x, plus those its name and
+// attributes need but are not declared, e.g. when created by NewItemNS
+func (x *Item) c14nDecls() []Namespace {
+	ret := x.ns
+	need := func(prefix, space string) {
+		for _, ns := range ret {
+			if ns.Prefix == prefix {
+				return
+			}
+		}
+		if bound, _ := x.LookupNamespace(prefix); bound != space {
+			ret = append(ret[:len(ret):len(ret)], Namespace{Prefix: prefix, URI: space})
+		}
+	}
+	need(x.prefix, x.space)
+	for _, a := range x.attrNames() {
+		if p, _ := splitQName(a.qname); "" != p && "" != a.space {
+			need(p, a.space)
+		}
+	}
+	return ret
+}
+
+// c14nScope returns namespaces in scope of x, prefix to URI
+func (x *Item) c14nScope() map[string]string {
+	var chain []*Item
+	for it := x; nil != it; it = it.parent {
+		chain = append(chain, it)
+	}
+	scope := map[string]string{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, ns := range chain[i].c14nDecls() {
+			scope[ns.Prefix] = ns.URI
+		}
+	}
+	return scope
+}
+
+// element writes x, with namespaces in scope of its parent and those rendered
+// by its nearest output ancestor
+func (c *canonicalizer) element(x *Item, scope, rendered map[string]string, apex bool) {
+	if decls := x.c14nDecls(); len(decls) > 0 {
+		s := make(map[string]string, len(scope)+len(decls))
+		for p, uri := range scope {
+			s[p] = uri
+		}
+		for _, ns := range decls {
+			s[ns.Prefix] = ns.URI
+		}
+		scope = s
+	}
+
+	var prefixes []string
+	if c.exclusive {
+		used := map[string]bool{x.prefix: true}
+		for _, a := range x.attrNames() {
+			if p, _ := splitQName(a.qname); "" != p {
+				used[p] = true
+			}
+		}
+		for p := range c.inclusive {
+			if _, exist := scope[p]; exist {
+				used[p] = true
+			}
+		}
+		for p := range used {
+			prefixes = append(prefixes, p)
+		}
+	} else {
+		for p := range scope {
+			prefixes = append(prefixes, p)
+		}
+	}
+	sort.Strings(prefixes)
+
+	var decls []Namespace
+	for _, p := range prefixes {
+		uri := scope[p]
+		if "xml" == p || ("" != p && "" == uri) {
+			continue
+		}
+		if r, exist := rendered[p]; exist && r == uri || (false == exist && "" == uri) {
+			continue
+		}
+		decls = append(decls, Namespace{Prefix: p, URI: uri})
+	}
+	if len(decls) > 0 {
+		r := make(map[string]string, len(rendered)+len(decls))
+		for p, uri := range rendered {
+			r[p] = uri
+		}
+		for _, ns := range decls {
+			r[ns.Prefix] = ns.URI
+		}
+		rendered = r
+	}
+
+	type attr struct {
+		space, local, qname, value string
+	}
+	attrs := make([]attr, 0, len(x.attrNames()))
+	for _, a := range x.attrNames() {
+		p, local := splitQName(a.qname)
+		space := a.space
+		if "xml" == p {
+			space = XMLNamespace
+		}
+		attrs = append(attrs, attr{space, local, a.qname, x.attrs[a.qname]})
+	}
+	if apex && false == c.exclusive {
+		// xml:* attributes are inherited from ancestors
+		for it := x.parent; nil != it; it = it.parent {
+		next:
+			for _, a := range it.attrNames() {
+				p, local := splitQName(a.qname)
+				if "xml" != p {
+					continue
+				}
+				for _, b := range attrs {
+					if b.qname == a.qname {
+						continue next
+					}
+				}
+				attrs = append(attrs, attr{XMLNamespace, local, a.qname, it.attrs[a.qname]})
+			}
+		}
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	b := &c.buff
+	b.WriteByte('<')
+	b.WriteString(x.QName())
+	for _, ns := range decls {
+		b.WriteString(" xmlns")
+		if "" != ns.Prefix {
+			b.WriteByte(':')
+			b.WriteString(ns.Prefix)
+		}
+		b.WriteString(`="`)
+		writeC14NAttr(b, ns.URI)
+		b.WriteByte('"')
+	}
+	for _, a := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(a.qname)
+		b.WriteString(`="`)
+		writeC14NAttr(b, a.value)
+		b.WriteByte('"')
+	}
+	b.WriteByte('>')
+
+	for _, n := range x.child {
+		switch {
+		case n == c.skip:
+		case ElementNode == n.kind:
+			c.element(n, scope, rendered, false)
+		default:
+			c.node(n)
+		}
+	}
+
+	b.WriteString("</")
+	b.WriteString(x.QName())
+	b.WriteByte('>')
+}
+
+// node writes a node other than elements
+func (c *canonicalizer) node(n *Item) {
+	b := &c.buff
+	switch n.kind {
+	case TextNode, CDataNode:
+		for _, r := range string(n.data) {
+			switch r {
+			case '&':
+				b.WriteString("&amp;")
+			case '<':
+				b.WriteString("&lt;")
+			case '>':
+				b.WriteString("&gt;")
+			case '\r':
+				b.WriteString("&#xD;")
+			default:
+				b.WriteRune(r)
+			}
+		}
+	case CommentNode:
+		if c.comments {
+			b.WriteString("<!--")
+			b.Write(n.data)
+			b.WriteString("-->")
+		}
+	case ProcInstNode:
+		b.WriteString("<?")
+		b.WriteString(n.name)
+		if len(n.data) > 0 {
+			b.WriteByte(' ')
+			b.Write(n.data)
+		}
+		b.WriteString("?>")
+	}
+}
+
+var c14nAttrReplacer = strings.NewReplacer(
+	"&", "&amp;", "<", "&lt;", `"`, "&quot;",
+	"\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;",
+)
+
+func writeC14NAttr(b *bytes.Buffer, v string) {
+	c14nAttrReplacer.WriteString(b, v)
+}
@@ -0,0 +1,83 @@
+package xmlconv
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalizeDocument(t *testing.T) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "c14n.xml"))
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	x, err := NewFromBytes(b)
+	if err != nil {
+		t.Fatalf("NewFromBytes error: %v", err)
+	}
+
+	// expected results are made by xmllint
+	cases := map[string]string{
+		C14N10WithComments:  "c14n_with_comments.xml",
+		ExcC14NWithComments: "exc_c14n_with_comments.xml",
+	}
+	for method, file := range cases {
+		expected, _ := ioutil.ReadFile(filepath.Join("testdata", file))
+		got, err := x.Canonicalize(method)
+		if err != nil {
+			t.Fatalf("Canonicalize error: %v", err)
+		}
+		if false == bytes.Equal(got, expected) {
+			t.Errorf("%s: unexpected result:\n%s", method, got)
+		}
+	}
+
+	got, _ := x.Canonicalize(C14N10)
+	if bytes.Contains(got, []byte("<!--")) || false == bytes.HasPrefix(got, []byte("<?xml-stylesheet")) {
+		t.Errorf("unexpected result without comments:\n%s", got)
+	}
+}
+
+func TestCanonicalizeSubtree(t *testing.T) {
+	x, _ := NewFromString(`<r xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b" xml:lang="en">` +
+		`<a:x b:attr="1" z="2"><y>t</y></a:x></r>`)
+	sub, _ := x.GetChild("x")
+
+	cases := []struct {
+		method   string
+		prefixes []string
+		expected string
+	}{
+		{C14N10, nil, `<a:x xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b" z="2" xml:lang="en" b:attr="1"><y>t</y></a:x>`},
+		{ExcC14N, nil, `<a:x xmlns:a="urn:a" xmlns:b="urn:b" z="2" b:attr="1"><y xmlns="urn:d">t</y></a:x>`},
+		{ExcC14N, []string{"#default"}, `<a:x xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b" z="2" b:attr="1"><y>t</y></a:x>`},
+	}
+	for _, c := range cases {
+		got, err := sub.Canonicalize(c.method, c.prefixes...)
+		if err != nil || string(got) != c.expected {
+			t.Errorf("%s %v: unexpected result %s, %v", c.method, c.prefixes, got, err)
+		}
+	}
+
+	// namespaces not declared but needed are rendered
+	built := NewItemNS("urn:p", "p:root")
+	built.SetAttrNS("urn:q", "q:attr", "v\tw")
+	built.AppendChild(NewItem("plain")).AppendChild(NewText("a < b"))
+	got, _ := built.Canonicalize(ExcC14N)
+	if string(got) != `<p:root xmlns:p="urn:p" xmlns:q="urn:q" q:attr="v&#x9;w"><plain>a &lt; b</plain></p:root>` {
+		t.Errorf("unexpected result: %s", got)
+	}
+
+	// literal white spaces in attributes are normalized by the parser,
+	// character references are kept
+	x, _ = NewFromString("<a v=\"1\t2\r\n3&#x9;4&#xA;5&amp;\"/>")
+	got, _ = x.Canonicalize(C14N10)
+	if string(got) != `<a v="1 2 3&#x9;4&#xA;5&amp;"></a>` {
+		t.Errorf("unexpected result: %s", got)
+	}
+
+	if _, err := sub.Canonicalize("urn:unknown"); err != ParaError {
+		t.Errorf("expected ParaError, got %v", err)
+	}
+}
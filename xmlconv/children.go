@@ -0,0 +1,178 @@
+package xmlconv
+
+// ChildList returns all child elements in document order. Use Nodes for text,
+// comments and other nodes as well.
+func (x *Item) ChildList() []*Item {
+	ret := make([]*Item, 0, len(x.child))
+	for _, c := range x.child {
+		if ElementNode == c.kind {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// ChildrenNamed returns direct children with given name in document order.
+// Like all other lookup functions, name may be "{uri}local", "prefix:local"
+// with prefix resolved in the scope of x, or a local name matching elements in
+// any namespace.
+func (x *Item) ChildrenNamed(name string) []*Item {
+	var ret []*Item
+	m := x.matcher(name)
+	for _, c := range x.child {
+		if m.matchItem(c) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// GetChildren returns all descendants matching the path of names in document
+// order. Unlike GetChild, every element matching an intermediate name is
+// searched, so that GetChildren("item", "tag") returns tags of all items.
+func (x *Item) GetChildren(n1 string, names ...string) []*Item {
+	curr := x.ChildrenNamed(n1)
+	for _, n := range names {
+		var next []*Item
+		for _, c := range curr {
+			next = append(next, c.ChildrenNamed(n)...)
+		}
+		curr = next
+		if 0 == len(curr) {
+			break
+		}
+	}
+	return curr
+}
+
+// GetChildAt returns the i-th (zero based) direct child with given name
+func (x *Item) GetChildAt(name string, i int) (*Item, bool) {
+	if i < 0 {
+		return nil, false
+	}
+	m := x.matcher(name)
+	for _, c := range x.child {
+		if false == m.matchItem(c) {
+			continue
+		}
+		if 0 == i {
+			return c, true
+		}
+		i--
+	}
+	return nil, false
+}
+
+// AppendChild adds child after all existing child nodes and returns it. child
+// may be an element or any other kind of node.
+func (x *Item) AppendChild(child *Item) *Item {
+	if nil == child {
+		return nil
+	}
+	x.adopt(child)
+	x.child = append(x.child, child)
+	return child
+}
+
+// InsertChild inserts child at position i among all direct child nodes. i is
+// clamped into [0, len(Nodes())].
+func (x *Item) InsertChild(i int, child *Item) *Item {
+	if nil == child {
+		return nil
+	}
+	x.adopt(child)
+	if i < 0 {
+		i = 0
+	} else if i > len(x.child) {
+		i = len(x.child)
+	}
+	x.child = append(x.child, nil)
+	copy(x.child[i+1:], x.child[i:])
+	x.child[i] = child
+	return child
+}
+
+// RemoveChild removes child from the direct children of x, and returns
+// whether it was found
+func (x *Item) RemoveChild(child *Item) bool {
+	for i, c := range x.child {
+		if c == child {
+			x.child = append(x.child[:i], x.child[i+1:]...)
+			child.parent = nil
+			return true
+		}
+	}
+	return false
+}
+
+func (x *Item) firstChild(name string) (*Item, bool) {
+	m := x.matcher(name)
+	for _, c := range x.child {
+		if m.matchItem(c) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// adopt detaches child from its current parent and makes x its parent,
+// resolving prefixes of child which have not been bound yet
+func (x *Item) adopt(child *Item) {
+	if nil != child.parent {
+		child.parent.RemoveChild(child)
+	}
+	child.parent = x
+	if "" != child.prefix && "" == child.space {
+		child.space, _ = child.LookupNamespace(child.prefix)
+	}
+	for i, a := range child.attrOrder {
+		if prefix, _ := splitQName(a.qname); "" != prefix && "" == a.space {
+			child.attrOrder[i].space, _ = child.LookupNamespace(prefix)
+		}
+	}
+}
+
+// rename sets the name of child, parsed in the scope of x
+func (x *Item) rename(child *Item, name string) {
+	child.space, child.prefix, child.name = x.splitName(name)
+}
+
+// childOrNew returns the first child with given name, appending a new one if
+// there is none
+func (x *Item) childOrNew(name string) *Item {
+	if c, exist := x.firstChild(name); exist {
+		return c
+	}
+	c := NewItem("")
+	x.rename(c, name)
+	return x.AppendChild(c)
+}
+
+// replaceChild names child and puts it in place of the first child with that
+// name, or appends it. A local name keeps the namespace of the replaced one.
+func (x *Item) replaceChild(child *Item, name string) {
+	x.rename(child, name)
+	m := x.matcher(name)
+	for _, c := range x.child {
+		if false == m.matchItem(c) {
+			continue
+		}
+		if c == child {
+			return
+		}
+		if m.anySpace {
+			child.space, child.prefix = c.space, c.prefix
+		}
+		c.parent = nil
+		x.adopt(child)
+		// adopt may have moved child within x, so look for c again
+		for i, cc := range x.child {
+			if cc == c {
+				x.child[i] = child
+				break
+			}
+		}
+		return
+	}
+	x.AppendChild(child)
+}
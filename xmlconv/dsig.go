@@ -0,0 +1,223 @@
+package xmlconv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+)
+
+// Algorithms of XML-DSig enveloped signatures
+const (
+	// DSigNamespace is the namespace of XML-DSig elements
+	DSigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+	// RSASHA256 is the RSA-SHA256 signature method
+	RSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	// SHA256 is the SHA-256 digest method
+	SHA256 = "http://www.w3.org/2001/04/xmlenc#sha256"
+	// EnvelopedSignature is the transform removing the signature itself
+	EnvelopedSignature = DSigNamespace + "enveloped-signature"
+)
+
+// SignEnveloped signs element x with key by an enveloped XML-DSig signature
+// appended as its last child, using Exclusive XML Canonicalization and
+// RSA-SHA256. The reference URI is "#" with the ID, Id or id attribute of x,
+// or "" for the whole document if none. cert is put into KeyInfo if not nil.
+//
+// Changes after signing, including trimmed text by Marshal without
+// Option.Preserve, break the signature.
+func SignEnveloped(x *Item, key *rsa.PrivateKey, cert *x509.Certificate) error {
+	if nil == x || ElementNode != x.kind || nil == key {
+		return ParaError
+	}
+	if nil != dsigChild(x, "Signature") {
+		return ParaError
+	}
+
+	uri := ""
+	if id, exist := elementID(x); exist {
+		uri = "#" + id
+	}
+	newDSig := func(parent *Item, name string) *Item {
+		return parent.AppendChild(NewItemNS(DSigNamespace, "ds:"+name))
+	}
+	sig := NewItemNS(DSigNamespace, "ds:Signature")
+	sig.DeclareNamespace("ds", DSigNamespace)
+	signedInfo := newDSig(sig, "SignedInfo")
+	newDSig(signedInfo, "CanonicalizationMethod").SetAttr("Algorithm", ExcC14N)
+	newDSig(signedInfo, "SignatureMethod").SetAttr("Algorithm", RSASHA256)
+	ref := newDSig(signedInfo, "Reference")
+	ref.SetAttr("URI", uri)
+	transforms := newDSig(ref, "Transforms")
+	newDSig(transforms, "Transform").SetAttr("Algorithm", EnvelopedSignature)
+	newDSig(transforms, "Transform").SetAttr("Algorithm", ExcC14N)
+	newDSig(ref, "DigestMethod").SetAttr("Algorithm", SHA256)
+	digest := newDSig(ref, "DigestValue")
+	value := newDSig(sig, "SignatureValue")
+	if nil != cert {
+		data := newDSig(newDSig(sig, "KeyInfo"), "X509Data")
+		newDSig(data, "X509Certificate").SetString(base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+	x.AppendChild(sig)
+
+	c, _ := newCanonicalizer(ExcC14N, nil)
+	c.skip = sig
+	c.document(x)
+	sum := sha256.Sum256(c.buff.Bytes())
+	digest.SetString(base64.StdEncoding.EncodeToString(sum[:]))
+
+	b, _ := signedInfo.Canonicalize(ExcC14N)
+	sum = sha256.Sum256(b)
+	s, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		x.RemoveChild(sig)
+		return err
+	}
+	value.SetString(base64.StdEncoding.EncodeToString(s))
+	return nil
+}
+
+// VerifyEnveloped verifies the enveloped XML-DSig signature of element x, a
+// Signature child referencing x by URI "" if x is the root element, or by its
+// ID, Id or id attribute. It must be signed by RSA-SHA256 with the key of
+// cert and digested by SHA-256, canonicalized by any method supported by
+// Canonicalize.
+//
+// It returns SignError if the digest or signature does not match,
+// FormatError if the signature is malformed, and AlgorithmError for other
+// algorithms.
+func VerifyEnveloped(x *Item, cert *x509.Certificate) error {
+	if nil == x || nil == cert {
+		return ParaError
+	}
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if false == ok {
+		return ParaError
+	}
+	sig := dsigChild(x, "Signature")
+	signedInfo := dsigChild(sig, "SignedInfo")
+	ref := dsigChild(signedInfo, "Reference")
+	if nil == ref {
+		return FormatError
+	}
+	if algorithm(dsigChild(signedInfo, "SignatureMethod")) != RSASHA256 ||
+		algorithm(dsigChild(ref, "DigestMethod")) != SHA256 {
+		return AlgorithmError
+	}
+
+	// the signed element must be x itself
+	uri, _ := ref.GetAttr("URI")
+	if id, exist := elementID(x); strings.HasPrefix(uri, "#") {
+		if false == exist || uri[1:] != id {
+			return FormatError
+		}
+	} else if "" != uri || nil != x.parent {
+		return FormatError
+	}
+
+	// canonical XML 1.0 applies if no canonicalization transform
+	c, _ := newCanonicalizer(C14N10, nil)
+	var transforms []*Item
+	if t := dsigChild(ref, "Transforms"); nil != t {
+		transforms = t.ChildrenNamed("{" + DSigNamespace + "}Transform")
+	}
+	for _, t := range transforms {
+		method := algorithm(t)
+		if EnvelopedSignature == method {
+			c.skip = sig
+			continue
+		}
+		next, err := newCanonicalizer(method, inclusivePrefixes(t))
+		if err != nil {
+			return AlgorithmError
+		}
+		next.skip = c.skip
+		c = next
+	}
+	c.document(x)
+	sum := sha256.Sum256(c.buff.Bytes())
+	expected, err := decodeBase64(dsigChild(ref, "DigestValue"))
+	if err != nil {
+		return err
+	}
+	if false == bytes.Equal(sum[:], expected) {
+		return SignError
+	}
+
+	method := dsigChild(signedInfo, "CanonicalizationMethod")
+	b, err := signedInfo.Canonicalize(algorithm(method), inclusivePrefixes(method)...)
+	if err != nil {
+		return AlgorithmError
+	}
+	value, err := decodeBase64(dsigChild(sig, "SignatureValue"))
+	if err != nil {
+		return err
+	}
+	sum = sha256.Sum256(b)
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], value); err != nil {
+		return SignError
+	}
+	return nil
+}
+
+// dsigChild returns the first child of x named local in the XML-DSig
+// namespace, or nil
+func dsigChild(x *Item, local string) *Item {
+	if nil == x {
+		return nil
+	}
+	for _, c := range x.child {
+		if ElementNode == c.kind && DSigNamespace == c.space && local == c.name {
+			return c
+		}
+	}
+	return nil
+}
+
+func elementID(x *Item) (string, bool) {
+	for _, n := range []string{"ID", "Id", "id"} {
+		if v, exist := x.attrs[n]; exist {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func algorithm(x *Item) string {
+	if nil == x {
+		return ""
+	}
+	v, _ := x.GetAttr("Algorithm")
+	return v
+}
+
+// inclusivePrefixes returns the PrefixList of an InclusiveNamespaces child of
+// a transform or canonicalization method
+func inclusivePrefixes(x *Item) []string {
+	if nil == x {
+		return nil
+	}
+	for _, c := range x.ChildList() {
+		if ExcC14N == c.space && "InclusiveNamespaces" == c.name {
+			v, _ := c.GetAttr("PrefixList")
+			return strings.Fields(v)
+		}
+	}
+	return nil
+}
+
+func decodeBase64(x *Item) ([]byte, error) {
+	if nil == x {
+		return nil, FormatError
+	}
+	s := strings.Join(strings.Fields(string(x.text())), "")
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, FormatError
+	}
+	return b, nil
+}
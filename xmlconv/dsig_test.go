@@ -0,0 +1,119 @@
+package xmlconv
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadDSigKey(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "dsig_key.pem"))
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	block, _ := pem.Decode(b)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParsePKCS8PrivateKey error: %v", err)
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join("testdata", "dsig_cert.pem"))
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	block, _ = pem.Decode(b)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate error: %v", err)
+	}
+	return key.(*rsa.PrivateKey), cert
+}
+
+func TestVerifyEnvelopedFixture(t *testing.T) {
+	_, cert := loadDSigKey(t)
+
+	// signed with xmllint and openssl
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "dsig_signed.xml"))
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	x, err := NewFromBytes(b)
+	if err != nil {
+		t.Fatalf("NewFromBytes error: %v", err)
+	}
+	if err := VerifyEnveloped(x, cert); err != nil {
+		t.Fatalf("VerifyEnveloped error: %v", err)
+	}
+
+	// tampered content
+	tampered, _ := NewFromString(strings.Replace(string(b), "alice@", "mallory@", 1))
+	if err := VerifyEnveloped(tampered, cert); err != SignError {
+		t.Errorf("expected SignError, got %v", err)
+	}
+
+	// tampered signed info
+	tampered, _ = NewFromString(strings.Replace(string(b), `URI="#_a1"`, `URI=""`, 1))
+	if err := VerifyEnveloped(tampered, cert); err != SignError {
+		t.Errorf("expected SignError, got %v", err)
+	}
+
+	// referencing another element
+	tampered, _ = NewFromString(strings.Replace(string(b), `ID="_a1"`, `ID="_a2"`, 1))
+	if err := VerifyEnveloped(tampered, cert); err != FormatError {
+		t.Errorf("expected FormatError, got %v", err)
+	}
+
+	unsigned, _ := NewFromString(`<a/>`)
+	if err := VerifyEnveloped(unsigned, cert); err != FormatError {
+		t.Errorf("expected FormatError, got %v", err)
+	}
+}
+
+func TestSignEnveloped(t *testing.T) {
+	key, cert := loadDSigKey(t)
+
+	x, _ := NewFromString(`<?xml version="1.0"?>
+<resp xmlns="urn:bank" Id="r1">
+  <amount currency="CNY">100.00</amount>
+  <memo><![CDATA[a & b]]></memo>
+</resp>`)
+	if err := SignEnveloped(x, key, cert); err != nil {
+		t.Fatalf("SignEnveloped error: %v", err)
+	}
+	if err := SignEnveloped(x, key, cert); err != ParaError {
+		t.Errorf("expected ParaError on signed item, got %v", err)
+	}
+
+	s, _ := x.MarshalString(Option{Preserve: true})
+	if false == strings.Contains(s, `<ds:Reference URI="#r1">`) {
+		t.Errorf("unexpected signature: %s", s)
+	}
+	signed, err := NewFromString(s)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+	if err := VerifyEnveloped(signed, cert); err != nil {
+		t.Errorf("VerifyEnveloped error: %v", err)
+	}
+
+	// without ID, the whole document is referenced
+	x = NewItem("order")
+	x.SetChildString("1", "id")
+	if err := SignEnveloped(x, key, nil); err != nil {
+		t.Fatalf("SignEnveloped error: %v", err)
+	}
+	s, _ = x.MarshalString()
+	signed, _ = NewFromString(s)
+	if err := VerifyEnveloped(signed, cert); err != nil {
+		t.Errorf("VerifyEnveloped error: %v", err)
+	}
+	id, _ := signed.GetChild("id")
+	id.SetString("2")
+	if err := VerifyEnveloped(signed, cert); err != SignError {
+		t.Errorf("expected SignError, got %v", err)
+	}
+}
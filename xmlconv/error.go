@@ -6,4 +6,6 @@ import (
 var (
 	ParaError				= errors.New("parameter invalid")
 	FormatError				= errors.New("format error")
+	SignError				= errors.New("signature mismatch")
+	AlgorithmError			= errors.New("unsupported algorithm")
 )
@@ -0,0 +1,103 @@
+package xmlconv
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// SignType is the algorithm to sign key-value messages with
+type SignType string
+
+const (
+	// SignMD5 is MD5 of the string to sign, the default of WeChat Pay
+	SignMD5 SignType = "MD5"
+	// SignHMACSHA256 is HMAC-SHA256 of the string to sign with the key
+	SignHMACSHA256 SignType = "HMAC-SHA256"
+)
+
+// ParseKeyValue parses a flat key-value message such as WeChat Pay ones:
+//
+//	<xml><appid><![CDATA[wx2421b1c4370ec43b]]></appid>...</xml>
+//
+// Values are the trimmed text of each child element of the root, whatever
+// its name is.
+func ParseKeyValue(b []byte) (map[string]string, error) {
+	item, err := NewFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	if err := Decode(item, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// NewFromKeyValue creates an "xml" element with a child for each key in
+// ascending order, each value in CDATA. Marshal it to build a message or
+// response.
+func NewFromKeyValue(m map[string]string) *Item {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := NewItem("xml")
+	for _, k := range keys {
+		c := ret.AppendChild(NewItem(k))
+		c.AppendChild(NewCDATA(m[k]))
+	}
+	return ret
+}
+
+// SignKeyValue signs a key-value message: keys except "sign" with non-empty
+// values are sorted, joined as "k1=v1&k2=v2&key=<key>", and digested into
+// upper case hexadecimal.
+func SignKeyValue(m map[string]string, key string, t SignType) (string, error) {
+	var h hash.Hash
+	switch t {
+	case SignMD5, "":
+		h = md5.New()
+	case SignHMACSHA256:
+		h = hmac.New(sha256.New, []byte(key))
+	default:
+		return "", ParaError
+	}
+
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		if "sign" != k && "" != v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Write([]byte(k + "=" + m[k] + "&"))
+	}
+	h.Write([]byte("key=" + key))
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil))), nil
+}
+
+// VerifyKeyValue checks the "sign" field of a key-value message, signed by
+// the algorithm in its "sign_type" field, MD5 by default. It returns
+// SignError if the signature does not match.
+func VerifyKeyValue(m map[string]string, key string) error {
+	sign, exist := m["sign"]
+	if false == exist {
+		return SignError
+	}
+	expected, err := SignKeyValue(m, key, SignType(m["sign_type"]))
+	if err != nil {
+		return err
+	}
+	if false == hmac.Equal([]byte(expected), []byte(strings.ToUpper(sign))) {
+		return SignError
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package xmlconv
+
+import (
+	"strings"
+	"testing"
+)
+
+// example from WeChat Pay documents
+const signKey = "192006250b4c09247ec02edce69f6a2d"
+
+var signParams = map[string]string{
+	"appid":       "wxd930ea5d5a258f4f",
+	"mch_id":      "10000100",
+	"device_info": "1000",
+	"body":        "test",
+	"nonce_str":   "ibuaiVcKdpRxkhJA",
+	"attach":      "",
+}
+
+func TestSignKeyValue(t *testing.T) {
+	cases := []struct {
+		t        SignType
+		expected string
+	}{
+		{SignMD5, "9A0A8659F005D6984697E2CA0A9CF3B7"},
+		{SignHMACSHA256, "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6"},
+	}
+	for _, c := range cases {
+		s, err := SignKeyValue(signParams, signKey, c.t)
+		if err != nil || s != c.expected {
+			t.Errorf("%s: unexpected sign %s, %v", c.t, s, err)
+		}
+	}
+	if _, err := SignKeyValue(signParams, signKey, "SHA1"); err != ParaError {
+		t.Errorf("expected ParaError, got %v", err)
+	}
+}
+
+func TestKeyValueMessage(t *testing.T) {
+	m := map[string]string{}
+	for k, v := range signParams {
+		m[k] = v
+	}
+	m["sign_type"] = string(SignHMACSHA256)
+	m["sign"], _ = SignKeyValue(m, signKey, SignHMACSHA256)
+
+	b, err := NewFromKeyValue(m).MarshalBytes()
+	if err != nil {
+		t.Fatalf("MarshalBytes error: %v", err)
+	}
+	if false == strings.HasPrefix(string(b), `<xml><appid><![CDATA[wxd930ea5d5a258f4f]]></appid><attach></attach>`) {
+		t.Errorf("unexpected message: %s", b)
+	}
+
+	got, err := ParseKeyValue(b)
+	if err != nil {
+		t.Fatalf("ParseKeyValue error: %v", err)
+	}
+	if len(got) != len(m) || got["nonce_str"] != m["nonce_str"] {
+		t.Errorf("unexpected map: %v", got)
+	}
+	if err := VerifyKeyValue(got, signKey); err != nil {
+		t.Errorf("VerifyKeyValue error: %v", err)
+	}
+
+	got["body"] = "tampered"
+	if err := VerifyKeyValue(got, signKey); err != SignError {
+		t.Errorf("expected SignError, got %v", err)
+	}
+	delete(got, "sign")
+	if err := VerifyKeyValue(got, signKey); err != SignError {
+		t.Errorf("expected SignError, got %v", err)
+	}
+}
+
+func TestNewFromKeyValue(t *testing.T) {
+	s, _ := NewFromKeyValue(map[string]string{"return_msg": "OK", "return_code": "SUCCESS"}).MarshalString()
+	if s != `<xml><return_code><![CDATA[SUCCESS]]></return_code><return_msg><![CDATA[OK]]></return_msg></xml>` {
+		t.Errorf("unexpected response: %s", s)
+	}
+}
@@ -3,16 +3,17 @@ import (
 	"github.com/Andrew-M-C/go-tools/str"
 )
 
+// GetChild returns the first descendant matching the path of names. When
+// elements are repeated, the first one in document order is taken at each
+// step, while earlier versions took the last one.
 func (x *Item) GetChild(n1 string, names ...string) (c *Item, exist bool) {
-	c, exist = x.child[n1]
-
-	l := len(names)
-	if 0 == l {
-		return c, exist
+	c, exist = x.firstChild(n1)
+	if false == exist {
+		return nil, false
 	}
 
 	for _, n := range names {
-		c, exist = c.child[n]
+		c, exist = c.firstChild(n)
 		if false == exist {
 			return nil, exist
 		}
@@ -21,6 +22,8 @@ func (x *Item) GetChild(n1 string, names ...string) (c *Item, exist bool) {
 }
 
 
+// SetChild puts child at the path of names, replacing the first existing
+// element there, and creating missing parents on the way
 func (x *Item) SetChild(child *Item, n1 string, names ...string) *Item {
 	if nil == child || str.Empty(n1) {
 		return nil
@@ -28,27 +31,16 @@ func (x *Item) SetChild(child *Item, n1 string, names ...string) *Item {
 
 	l := len(names)
 	if 0 == l {
-		child.name = n1
-		x.child[n1] = child
+		x.replaceChild(child, n1)
 		return child
 	}
 
-	c, exist := x.child[n1]
-	if false == exist {
-		c = NewItem(n1)
-		x.child[n1] = c
-	}
+	c := x.childOrNew(n1)
 	for i, n := range names {
 		if i == l - 1 {
-			c.child[n] = child
-			child.name = n
+			c.replaceChild(child, n)
 		} else {
-			new_c, exist := c.child[n]
-			if false == exist {
-				new_c = NewItem(n)
-				c.child[n] = new_c
-			}
-			c = new_c
+			c = c.childOrNew(n)
 		}
 	}
 	return c
@@ -81,26 +73,17 @@ func (x *Item) SetChildBytes(b []byte, n1 string, names ...string) *Item {
 	}
 
 	l := len(names)
-	c, exist := x.child[n1]
-	if false == exist {
-		c = NewItem(n1)
-		x.child[n1] = c
-	}
+	c := x.childOrNew(n1)
 
 	if 0 == l {
-		c.data = b
+		c.SetData(b)
 		return c
 	}
 
 	for i, n := range names {
-		new_c, exist := c.child[n]
-		if false == exist {
-			new_c = NewItem(n)
-			c.child[n] = new_c
-		}
-		c = new_c
+		c = c.childOrNew(n)
 		if i == l - 1 {
-			c.data = b
+			c.SetData(b)
 		}
 	}
 	return c
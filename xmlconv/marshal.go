@@ -2,102 +2,455 @@ package xmlconv
 import (
 	"github.com/Andrew-M-C/go-tools/str"
 	"strings"
+	"strconv"
 	"bytes"
+	"bufio"
+	"io"
+	"sort"
+)
+
+// CDataPolicy tells how Marshal writes text
+type CDataPolicy int
+
+const (
+	// CDataAuto writes text in CDATA if it was CDATA or contains any of
+	// <>&"'. With Option.Preserve, it is the same as CDataNever.
+	CDataAuto CDataPolicy = iota
+	// CDataAlways writes all text in CDATA
+	CDataAlways
+	// CDataNever escapes text, and only writes CDATA sections as CDATA
+	CDataNever
+	// CDataEscape escapes all text, including CDATA sections
+	CDataEscape
 )
 
 type Option struct {
 	Indent	string
+	// Preserve writes a parsed document as it was: the prolog and epilog
+	// such as XML declaration and DOCTYPE, white spaces, and text escaped
+	// instead of in CDATA unless it was CDATA. Indent is ignored.
+	//
+	// Otherwise, text is trimmed and written in CDATA if it was CDATA or
+	// contains any of <>&"', and white space only text is omitted.
+	Preserve	bool
+	// Declaration writes an XML declaration first, replacing the one of
+	// the document with Preserve. Encoding is declared in it, "UTF-8" by
+	// default. Text is not converted into Encoding.
+	Declaration	bool
+	Encoding	string
+	// SelfClose writes elements without content as <a/> instead of <a></a>
+	SelfClose	bool
+	// SortAttrs writes attributes and namespace declarations in ascending
+	// order of names instead of the original order
+	SortAttrs	bool
+	CDATA		CDataPolicy
+	// LineEnding is written for line breaks of Indent and after
+	// Declaration, "\n" by default
+	LineEnding	string
+	// SingleQuote quotes attribute values with ' instead of "
+	SingleQuote	bool
 }
 
 var defaultOpt = Option{
 	Indent: "",
 }
 
+func (opt *Option) lineEnding() string {
+	if "" == opt.LineEnding {
+		return "\n"
+	}
+	return opt.LineEnding
+}
+
+func (opt *Option) quote() byte {
+	if opt.SingleQuote {
+		return '\''
+	}
+	return '"'
+}
+
+
+// WriteTo writes the item as XML into w with default options, implementing
+// io.WriterTo
+func (self *Item) WriteTo(w io.Writer) (int64, error) {
+	return self.WriteToWithOption(w, defaultOpt)
+}
+
+
+// WriteToWithOption writes the item as XML into w with buffering, returning
+// the number of bytes written
+func (self *Item) WriteToWithOption(w io.Writer, opt Option) (int64, error) {
+	cw := &countWriter{w: w}
+	buff := bufio.NewWriter(cw)
+	self.documentToBuffer(buff, &opt)
+	err := buff.Flush()
+	return cw.n, err
+}
+
 
 func (self *Item) MarshalBytes(opt ...Option) ([]byte, error) {
-	indent := ""
+	o := defaultOpt
 	if len(opt) > 0 {
-		indent = opt[0].Indent
-	} else {
-		indent = defaultOpt.Indent
+		o = opt[0]
 	}
 	buff := bytes.Buffer{}
-	self.toBuffer(&buff, indent, 0)
+	if _, err := self.WriteToWithOption(&buff, o); err != nil {
+		return nil, err
+	}
 	return buff.Bytes(), nil
 }
 
 
 func (self *Item) MarshalString(opt ...Option) (string, error) {
-	indent := ""
-	if len(opt) > 0 {
-		indent = opt[0].Indent
+	b, err := self.MarshalBytes(opt...)
+	return string(b), err
+}
+
+
+func (self *Item) Marshal(opt ...Option) (string, error) {
+	return self.MarshalString(opt...)
+}
+
+
+type countWriter struct {
+	w	io.Writer
+	n	int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+
+// nsScope is the namespace bindings in effect while marshalling
+type nsScope struct {
+	prefix	string
+	uri		string
+	next	*nsScope
+}
+
+func (s *nsScope) lookup(prefix string) (string, bool) {
+	if "xml" == prefix {
+		return XMLNamespace, true
+	}
+	for ; s != nil; s = s.next {
+		if s.prefix == prefix {
+			return s.uri, true
+		}
+	}
+	return "", false
+}
+
+// prefixFor returns a prefix bound to uri which is not shadowed. Attributes
+// need a non-empty prefix as the default namespace does not apply to them.
+func (s *nsScope) prefixFor(uri string, allowDefault bool) (string, bool) {
+	if XMLNamespace == uri {
+		return "xml", true
+	}
+	for it := s; it != nil; it = it.next {
+		if it.uri != uri || ("" == it.prefix && false == allowDefault) {
+			continue
+		}
+		if bound, _ := s.lookup(it.prefix); bound == uri {
+			return it.prefix, true
+		}
+	}
+	return "", false
+}
+
+// qualify returns the name to write for namespace space with preferred prefix,
+// adding a declaration to decls if the namespace is not bound yet
+func qualify(space, prefix, local string, isAttr bool, scope **nsScope, decls *[]Namespace) string {
+	if "" == space {
+		if "" == prefix {
+			return local
+		}
+		return prefix + ":" + local
+	}
+	if bound, _ := (*scope).lookup(prefix); bound == space && (false == isAttr || "" != prefix) {
+		// already bound as preferred
+	} else if p, exist := (*scope).prefixFor(space, false == isAttr); exist {
+		prefix = p
 	} else {
-		indent = defaultOpt.Indent
+		taken := func(p string) bool {
+			for _, d := range *decls {
+				if d.Prefix == p {
+					return true
+				}
+			}
+			return false
+		}
+		// attributes never shadow a binding which may be used by the
+		// element itself or previous attributes
+		_, bound := (*scope).lookup(prefix)
+		if isAttr && ("" == prefix || bound) || taken(prefix) {
+			for i := 1; ; i++ {
+				prefix = "ns" + strconv.Itoa(i)
+				if _, exist := (*scope).lookup(prefix); false == exist && false == taken(prefix) {
+					break
+				}
+			}
+		}
+		*decls = append(*decls, Namespace{Prefix: prefix, URI: space})
+		*scope = &nsScope{prefix: prefix, uri: space, next: *scope}
 	}
-	buff := bytes.Buffer{}
-	self.toBuffer(&buff, indent, 0)
-	return buff.String(), nil
+	if "" == prefix {
+		return local
+	}
+	return prefix + ":" + local
 }
 
 
-func (self *Item) Marshal(opt ...Option) (string, error) {
-	indent := ""
-	if len(opt) > 0 {
-		indent = opt[0].Indent
+func (self *Item) documentToBuffer(buff *bufio.Writer, opt *Option) {
+	wroteDecl := false
+	writeDecl := func() {
+		enc := opt.Encoding
+		if "" == enc {
+			enc = "UTF-8"
+		}
+		buff.WriteString("<?xml version=\"1.0\" encoding=\"")
+		writeAttrToBuff(enc, buff)
+		buff.WriteString("\"?>")
+		wroteDecl = true
+	}
+	if opt.Preserve {
+		for _, n := range self.prolog {
+			if opt.Declaration && ProcInstNode == n.kind && "xml" == n.name {
+				writeDecl()
+			} else {
+				n.nodeToBuffer(buff, opt)
+			}
+		}
+	}
+	if opt.Declaration && false == wroteDecl {
+		writeDecl()
+		buff.WriteString(opt.lineEnding())
+	}
+	if ElementNode == self.kind {
+		self.toBuffer(buff, opt, 0, nil)
 	} else {
-		indent = defaultOpt.Indent
+		self.nodeToBuffer(buff, opt)
+	}
+	if opt.Preserve {
+		for _, n := range self.epilog {
+			n.nodeToBuffer(buff, opt)
+		}
 	}
-	buff := bytes.Buffer{}
-	self.toBuffer(&buff, indent, 0)
-	return buff.String(), nil
 }
 
 
-func (self *Item) toBuffer(buff *bytes.Buffer, indent string, depth int) {
-	prefix := ""
-	if str.Valid(indent) {
-		prefix = "\n" + strings.Repeat(indent, depth)
+func indentPrefix(opt *Option, depth int) string {
+	if opt.Preserve || str.Empty(opt.Indent) {
+		return ""
 	}
+	return opt.lineEnding() + strings.Repeat(opt.Indent, depth)
+}
+
+
+func (self *Item) toBuffer(buff *bufio.Writer, opt *Option, depth int, scope *nsScope) {
+	prefix := indentPrefix(opt, depth)
 	if depth > 0 {
 		buff.WriteString(prefix)
 	}
-	buff.WriteRune('<')
-	buff.WriteString(self.name)
 
-	for k, v := range self.attrs {
-		buff.WriteRune(' ')
-		buff.WriteString(k)
-		buff.WriteString("=\"")
-		writeAttrToBuff(v, buff)
-		buff.WriteRune('"')
+	// declarations written in the document come first
+	decls := make([]Namespace, 0, len(self.ns))
+	for _, ns := range self.ns {
+		decls = append(decls, ns)
+		scope = &nsScope{prefix: ns.Prefix, uri: ns.URI, next: scope}
+	}
+	name := qualify(self.space, self.prefix, self.name, false, &scope, &decls)
+	attrs := make([]attrValue, 0, len(self.attrNames()))
+	for _, a := range self.attrNames() {
+		p, local := splitQName(a.qname)
+		n := qualify(a.space, p, local, true, &scope, &decls)
+		attrs = append(attrs, attrValue{name: n, value: self.attrs[a.qname]})
+	}
+	if opt.SortAttrs {
+		sort.SliceStable(decls, func(i, j int) bool {
+			return decls[i].Prefix < decls[j].Prefix
+		})
+		sort.SliceStable(attrs, func(i, j int) bool {
+			return attrs[i].name < attrs[j].name
+		})
 	}
-	buff.WriteRune('>')
 
-	s := self.String()
-	if str.Valid(s) {
-		if strings.ContainsAny(s, "<>&\"'") {
-			buff.WriteString("<![CDATA[")
-			buff.WriteString(s)
-			buff.WriteString("]]>")
+	buff.WriteRune('<')
+	buff.WriteString(name)
+
+	q := opt.quote()
+	for _, ns := range decls {
+		if "" == ns.Prefix {
+			buff.WriteString(" xmlns=")
 		} else {
-			buff.WriteString(s)
+			buff.WriteString(" xmlns:")
+			buff.WriteString(ns.Prefix)
+			buff.WriteRune('=')
 		}
+		buff.WriteByte(q)
+		writeAttrToBuff(ns.URI, buff)
+		buff.WriteByte(q)
+	}
+	for _, a := range attrs {
+		buff.WriteRune(' ')
+		buff.WriteString(a.name)
+		buff.WriteRune('=')
+		buff.WriteByte(q)
+		writeAttrToBuff(a.value, buff)
+		buff.WriteByte(q)
 	}
 
-	if len(self.child) > 0 {
-		for _, c := range self.child {
-			c.toBuffer(buff, indent, depth + 1)
+	if opt.SelfClose && self.emptyContent(opt) {
+		buff.WriteString("/>")
+		return
+	}
+	buff.WriteRune('>')
+
+	wroteNode := false
+	for _, c := range self.child {
+		switch c.kind {
+		case ElementNode:
+			c.toBuffer(buff, opt, depth + 1, scope)
+			wroteNode = true
+		case TextNode, CDataNode:
+			c.nodeToBuffer(buff, opt)
+		default:
+			buff.WriteString(indentPrefix(opt, depth + 1))
+			c.nodeToBuffer(buff, opt)
+			wroteNode = true
 		}
+	}
+	if wroteNode {
 		buff.WriteString(prefix)
 	}
 
 	buff.WriteString("</")
-	buff.WriteString(self.name)
+	buff.WriteString(name)
 	buff.WriteRune('>')
 }
 
 
-func writeAttrToBuff(v string, buff *bytes.Buffer) {
+type attrValue struct {
+	name	string
+	value	string
+}
+
+
+// emptyContent tells whether nothing would be written between the tags
+func (self *Item) emptyContent(opt *Option) bool {
+	for _, c := range self.child {
+		if false == c.isText() {
+			return false
+		}
+		if _, ok := c.textToWrite(opt); ok {
+			return false
+		}
+	}
+	return true
+}
+
+
+// textToWrite returns the content of a text node to write, or false if it is
+// omitted
+func (self *Item) textToWrite(opt *Option) ([]byte, bool) {
+	if opt.Preserve {
+		return self.data, len(self.data) > 0 || CDataNode == self.kind
+	}
+	b := bytes.Trim(self.data, "\r\n\t ")
+	return b, len(b) > 0
+}
+
+
+// nodeToBuffer writes nodes other than elements
+func (self *Item) nodeToBuffer(buff *bufio.Writer, opt *Option) {
+	switch self.kind {
+	case TextNode, CDataNode:
+		b, ok := self.textToWrite(opt)
+		if false == ok {
+			return
+		}
+		cdata := CDataNode == self.kind
+		switch opt.CDATA {
+		case CDataAlways:
+			cdata = true
+		case CDataEscape:
+			cdata = false
+		case CDataAuto:
+			if false == opt.Preserve && bytes.ContainsAny(b, "<>&\"'") {
+				cdata = true
+			}
+		}
+		if cdata {
+			writeCDataToBuff(b, buff)
+		} else if CDataAuto == opt.CDATA && false == opt.Preserve {
+			buff.Write(b)
+		} else {
+			writeTextToBuff(b, buff)
+		}
+
+	case CommentNode:
+		buff.WriteString("<!--")
+		buff.Write(self.data)
+		buff.WriteString("-->")
+
+	case ProcInstNode:
+		buff.WriteString("<?")
+		buff.WriteString(self.name)
+		if len(self.data) > 0 {
+			buff.WriteRune(' ')
+			buff.Write(self.data)
+		}
+		buff.WriteString("?>")
+
+	case DirectiveNode:
+		buff.WriteString("<!")
+		buff.Write(self.data)
+		buff.WriteRune('>')
+
+	case ElementNode:
+		self.toBuffer(buff, opt, 0, nil)
+	}
+}
+
+
+// writeCDataToBuff writes b in CDATA, splitting any "]]>" in it
+func writeCDataToBuff(b []byte, buff *bufio.Writer) {
+	buff.WriteString("<![CDATA[")
+	for {
+		i := bytes.Index(b, []byte("]]>"))
+		if i < 0 {
+			break
+		}
+		buff.Write(b[:i+2])
+		buff.WriteString("]]><![CDATA[")
+		b = b[i+2:]
+	}
+	buff.Write(b)
+	buff.WriteString("]]>")
+}
+
+
+func writeTextToBuff(b []byte, buff *bufio.Writer) {
+	for _, c := range b {
+		switch c {
+		case '&':
+			buff.WriteString("&amp;")
+		case '<':
+			buff.WriteString("&lt;")
+		case '>':
+			buff.WriteString("&gt;")
+		case '\r':
+			buff.WriteString("&#xD;")
+		default:
+			buff.WriteByte(c)
+		}
+	}
+}
+
+
+func writeAttrToBuff(v string, buff *bufio.Writer) {
 	for _, c := range v {
 		switch c {
 		case '&':
@@ -0,0 +1,102 @@
+package xmlconv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestWriteToOptions(t *testing.T) {
+	x, err := NewFromString(`<a z="1" b='x"y'><b/><c> <![CDATA[1<2]]> </c><d>plain</d><e>x&amp;y</e></a>`)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+
+	cases := []struct {
+		opt      Option
+		expected string
+	}{
+		{Option{}, `<a z="1" b="x&quot;y"><b></b><c><![CDATA[1<2]]></c><d>plain</d><e><![CDATA[x&y]]></e></a>`},
+		{Option{SelfClose: true, SortAttrs: true, SingleQuote: true}, `<a b='x&quot;y' z='1'><b/><c><![CDATA[1<2]]></c><d>plain</d><e><![CDATA[x&y]]></e></a>`},
+		{Option{CDATA: CDataAlways}, `<a z="1" b="x&quot;y"><b></b><c><![CDATA[1<2]]></c><d><![CDATA[plain]]></d><e><![CDATA[x&y]]></e></a>`},
+		{Option{CDATA: CDataNever}, `<a z="1" b="x&quot;y"><b></b><c><![CDATA[1<2]]></c><d>plain</d><e>x&amp;y</e></a>`},
+		{Option{CDATA: CDataEscape}, `<a z="1" b="x&quot;y"><b></b><c>1&lt;2</c><d>plain</d><e>x&amp;y</e></a>`},
+		{Option{Preserve: true, SelfClose: true}, `<a z="1" b="x&quot;y"><b/><c> <![CDATA[1<2]]> </c><d>plain</d><e>x&amp;y</e></a>`},
+	}
+	for i, c := range cases {
+		buff := bytes.Buffer{}
+		n, err := x.WriteToWithOption(&buff, c.opt)
+		if err != nil {
+			t.Fatalf("%d: WriteTo error: %v", i, err)
+		}
+		if buff.String() != c.expected || n != int64(buff.Len()) {
+			t.Errorf("%d: unexpected result: %s (%d)", i, buff.String(), n)
+		}
+	}
+}
+
+func TestWriteToDeclaration(t *testing.T) {
+	x := NewItem("a")
+	x.SetChildString("1", "b")
+	s, _ := x.MarshalString(Option{Declaration: true, Encoding: "GBK", Indent: "  ", LineEnding: "\r\n"})
+	if s != "<?xml version=\"1.0\" encoding=\"GBK\"?>\r\n<a>\r\n  <b>1</b>\r\n</a>" {
+		t.Errorf("unexpected result: %q", s)
+	}
+
+	// the original declaration is replaced in place
+	x, _ = NewFromString("<?xml version=\"1.0\" standalone=\"yes\"?>\n<!-- c -->\n<a/>")
+	s, _ = x.MarshalString(Option{Preserve: true, Declaration: true})
+	if s != "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!-- c -->\n<a></a>" {
+		t.Errorf("unexpected result: %q", s)
+	}
+}
+
+func TestWriteToAttrsMap(t *testing.T) {
+	x, _ := NewFromString(`<a y="1" x="0"/>`)
+	x.Attrs()["z"] = "2"
+	x.Attrs()["b"] = "3"
+	delete(x.Attrs(), "x")
+	s, _ := x.MarshalString()
+	if s != `<a y="1" b="3" z="2"></a>` {
+		t.Errorf("unexpected result: %s", s)
+	}
+	if v, exist := x.GetAttr("z"); false == exist || v != "2" {
+		t.Errorf("unexpected attribute z: %s, %v", v, exist)
+	}
+}
+
+type failWriter struct {
+	n int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, errors.New("full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriteToError(t *testing.T) {
+	x := NewItem("a")
+	x.SetString(string(bytes.Repeat([]byte("x"), 10000)))
+	n, err := x.WriteTo(&failWriter{n: 100})
+	if err == nil || n != 100 {
+		t.Errorf("unexpected result: %d, %v", n, err)
+	}
+}
+
+func TestWriterTo(t *testing.T) {
+	x, _ := NewFromString(`<a><b>1</b></a>`)
+	var wt interface{} = x
+	w, ok := wt.(io.WriterTo)
+	if false == ok {
+		t.Fatalf("*Item should implement io.WriterTo")
+	}
+	buff := bytes.Buffer{}
+	n, err := w.WriteTo(&buff)
+	if err != nil || buff.String() != "<a><b>1</b></a>" || n != int64(buff.Len()) {
+		t.Errorf("unexpected result: %s (%d), %v", buff.String(), n, err)
+	}
+}
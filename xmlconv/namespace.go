@@ -0,0 +1,208 @@
+package xmlconv
+
+import (
+	"sort"
+	"strings"
+)
+
+// XMLNamespace is the namespace bound to the reserved "xml" prefix
+const XMLNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Namespace is a namespace declaration, i.e. an xmlns attribute. An empty
+// Prefix is the default namespace.
+type Namespace struct {
+	Prefix string
+	URI    string
+}
+
+// attrName is the name of an attribute in document order. qname is the key in
+// Item.attrs, with prefix if any, and space is the resolved namespace URI.
+type attrName struct {
+	qname string
+	space string
+}
+
+// attrNames returns the attributes in document order. Entries written
+// directly into the map returned by Attrs() have no recorded position; they
+// follow the ordered ones, sorted by name.
+func (x *Item) attrNames() []attrName {
+	ret := make([]attrName, 0, len(x.attrs))
+	known := make(map[string]bool, len(x.attrOrder))
+	for _, a := range x.attrOrder {
+		if _, exist := x.attrs[a.qname]; exist {
+			ret = append(ret, a)
+			known[a.qname] = true
+		}
+	}
+	if len(known) == len(x.attrOrder) && len(known) == len(x.attrs) {
+		return x.attrOrder
+	}
+	extra := make([]string, 0, len(x.attrs)-len(known))
+	for n := range x.attrs {
+		if false == known[n] {
+			extra = append(extra, n)
+		}
+	}
+	sort.Strings(extra)
+	for _, n := range extra {
+		space := ""
+		if prefix, _ := splitQName(n); "" != prefix {
+			space, _ = x.LookupNamespace(prefix)
+		}
+		ret = append(ret, attrName{qname: n, space: space})
+	}
+	return ret
+}
+
+// Space returns the namespace URI of the element, or "" for none
+func (x *Item) Space() string {
+	return x.space
+}
+
+// Prefix returns the namespace prefix of the element, or "" for none
+func (x *Item) Prefix() string {
+	return x.prefix
+}
+
+// QName returns the name with prefix, e.g. "soap:Body"
+func (x *Item) QName() string {
+	if "" == x.prefix {
+		return x.name
+	}
+	return x.prefix + ":" + x.name
+}
+
+// Parent returns the parent element, or nil for a root or detached item
+func (x *Item) Parent() *Item {
+	return x.parent
+}
+
+// Namespaces returns namespaces declared on this element
+func (x *Item) Namespaces() []Namespace {
+	return x.ns
+}
+
+// DeclareNamespace adds or replaces an xmlns declaration on this element. An
+// empty prefix declares the default namespace.
+func (x *Item) DeclareNamespace(prefix, uri string) {
+	for i, ns := range x.ns {
+		if ns.Prefix == prefix {
+			x.ns[i].URI = uri
+			return
+		}
+	}
+	x.ns = append(x.ns, Namespace{Prefix: prefix, URI: uri})
+	if "" != x.prefix && prefix == x.prefix && "" == x.space {
+		x.space = uri
+	}
+}
+
+// LookupNamespace returns the URI bound to prefix in the scope of this element
+func (x *Item) LookupNamespace(prefix string) (string, bool) {
+	if "xml" == prefix {
+		return XMLNamespace, true
+	}
+	for it := x; nil != it; it = it.parent {
+		for _, ns := range it.ns {
+			if ns.Prefix == prefix {
+				return ns.URI, true
+			}
+		}
+	}
+	return "", false
+}
+
+// LookupPrefix returns a prefix bound to uri in the scope of this element,
+// preferring the nearest declaration. The prefix may be "" for the default
+// namespace.
+func (x *Item) LookupPrefix(uri string) (string, bool) {
+	if XMLNamespace == uri {
+		return "xml", true
+	}
+	for it := x; nil != it; it = it.parent {
+		for _, ns := range it.ns {
+			if ns.URI != uri {
+				continue
+			}
+			// the prefix may be rebound closer to x
+			if bound, _ := x.LookupNamespace(ns.Prefix); bound == uri {
+				return ns.Prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// SetAttrNS sets an attribute in namespace space. qname may have a prefix,
+// e.g. "xsi:type", which is used when marshalling if possible.
+func (x *Item) SetAttrNS(space, qname, v string) {
+	x.SetAttr(qname, v)
+	for i := range x.attrOrder {
+		if x.attrOrder[i].qname == qname {
+			x.attrOrder[i].space = space
+		}
+	}
+}
+
+// splitQName splits "prefix:local" into its parts
+func splitQName(n string) (prefix, local string) {
+	if i := strings.IndexByte(n, ':'); i > 0 {
+		return n[:i], n[i+1:]
+	}
+	return "", n
+}
+
+// splitName parses a name in the form of "{uri}local", "prefix:local" or
+// "local", resolving the prefix in the scope of x
+func (x *Item) splitName(n string) (space, prefix, local string) {
+	if strings.HasPrefix(n, "{") {
+		if i := strings.IndexByte(n, '}'); i > 0 {
+			return n[1:i], "", n[i+1:]
+		}
+	}
+	prefix, local = splitQName(n)
+	if "" != prefix && nil != x {
+		space, _ = x.LookupNamespace(prefix)
+	}
+	return space, prefix, local
+}
+
+// nameMatcher matches elements or attributes by a name given to lookup
+// functions. A plain local name matches in any namespace.
+type nameMatcher struct {
+	space    string
+	prefix   string
+	local    string
+	anySpace bool
+}
+
+func (x *Item) matcher(n string) nameMatcher {
+	space, prefix, local := x.splitName(n)
+	m := nameMatcher{space: space, prefix: prefix, local: local}
+	if "" == space && "" == prefix && false == strings.HasPrefix(n, "{") {
+		m.anySpace = true
+	}
+	return m
+}
+
+func (m *nameMatcher) match(space, prefix, local string) bool {
+	if m.local != local {
+		return false
+	}
+	switch {
+	case m.anySpace:
+		return true
+	case "" != m.space:
+		return m.space == space
+	case "" != m.prefix:
+		// unresolved prefix, compare literally
+		return m.prefix == prefix
+	default:
+		// "{}local" means no namespace
+		return "" == space
+	}
+}
+
+func (m *nameMatcher) matchItem(c *Item) bool {
+	return ElementNode == c.kind && m.match(c.space, c.prefix, c.name)
+}
@@ -0,0 +1,106 @@
+package xmlconv
+
+import (
+	"testing"
+)
+
+const soapEnvelope = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:example">` +
+	`<soap:Header><m:Trans soap:mustUnderstand="1">234</m:Trans></soap:Header>` +
+	`<soap:Body><m:GetPrice><m:Item>Apples</m:Item></m:GetPrice><Item>plain</Item></soap:Body>` +
+	`</soap:Envelope>`
+
+func TestNamespaceParse(t *testing.T) {
+	x, err := NewFromString(soapEnvelope)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+	if x.Name() != "Envelope" || x.Prefix() != "soap" || x.Space() != "http://schemas.xmlsoap.org/soap/envelope/" {
+		t.Errorf("unexpected root name: %s %s %s", x.Name(), x.Prefix(), x.Space())
+	}
+	if len(x.Namespaces()) != 2 {
+		t.Errorf("unexpected namespaces: %v", x.Namespaces())
+	}
+
+	body, exist := x.GetChild("{http://schemas.xmlsoap.org/soap/envelope/}Body")
+	if false == exist {
+		t.Fatalf("Body not found by URI")
+	}
+	if c, exist := x.GetChild("soap:Body", "m:GetPrice", "m:Item"); false == exist || c.String() != "Apples" {
+		t.Errorf("m:Item not found by prefix")
+	}
+	if items := body.GetChildren("Item"); len(items) != 1 || items[0].String() != "plain" {
+		t.Errorf("unexpected local name lookup: %v", childNames(items))
+	}
+	if items := body.ChildrenNamed("{}Item"); len(items) != 1 || items[0].String() != "plain" {
+		t.Errorf("unexpected no-namespace lookup: %v", childNames(items))
+	}
+	if items := body.GetChildren("GetPrice", "{urn:example}Item"); len(items) != 1 || items[0].String() != "Apples" {
+		t.Errorf("unexpected URI lookup: %v", childNames(items))
+	}
+
+	trans, _ := x.GetChild("Header", "Trans")
+	for _, n := range []string{"soap:mustUnderstand", "{http://schemas.xmlsoap.org/soap/envelope/}mustUnderstand", "mustUnderstand"} {
+		if v, exist := trans.GetAttr(n); false == exist || v != "1" {
+			t.Errorf("attribute %s not found", n)
+		}
+	}
+
+	s, _ := x.Marshal()
+	if s != soapEnvelope {
+		t.Errorf("unexpected marshal result: %s", s)
+	}
+
+	// a subtree is marshalled with the declarations it needs
+	s, _ = trans.Marshal()
+	if s != `<m:Trans xmlns:m="urn:example" xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" soap:mustUnderstand="1">234</m:Trans>` {
+		t.Errorf("unexpected subtree result: %s", s)
+	}
+}
+
+func TestNamespaceDefault(t *testing.T) {
+	const atom = `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">` +
+		`<title>t</title><media:title>m</media:title><entry xmlns=""><title>x</title></entry></feed>`
+	x, err := NewFromString(atom)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+	if c, _ := x.GetChild("{http://www.w3.org/2005/Atom}title"); nil == c || c.String() != "t" {
+		t.Errorf("atom title not found")
+	}
+	if c, _ := x.GetChild("media:title"); nil == c || c.String() != "m" {
+		t.Errorf("media title not found")
+	}
+	if c, _ := x.GetChild("entry", "title"); nil == c || c.Space() != "" {
+		t.Errorf("undeclared default namespace not applied")
+	}
+	if s, _ := x.Marshal(); s != atom {
+		t.Errorf("unexpected marshal result: %s", s)
+	}
+}
+
+func TestNamespaceBuild(t *testing.T) {
+	root := NewItemNS("urn:a", "a:root")
+	child := root.AppendChild(NewItemNS("urn:b", "item"))
+	child.SetAttrNS("urn:a", "a:id", "1")
+	child.SetAttrNS("urn:c", "type", "x")
+	root.AppendChild(NewItemNS("urn:a", "other"))
+
+	s, _ := root.Marshal()
+	expected := `<a:root xmlns:a="urn:a"><item xmlns="urn:b" xmlns:ns1="urn:c" a:id="1" ns1:type="x"></item><a:other></a:other></a:root>`
+	if s != expected {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	// prefixes given to NewItem are resolved once added to a parent
+	root.DeclareNamespace("b", "urn:b")
+	c := root.AppendChild(NewItem("b:more"))
+	if c.Space() != "urn:b" {
+		t.Errorf("prefix not resolved: %s", c.Space())
+	}
+}
+
+func TestNamespaceMismatch(t *testing.T) {
+	if _, err := NewFromString(`<a:x xmlns:a="urn:a"></b:x>`); err == nil {
+		t.Errorf("mismatched end element should fail")
+	}
+}
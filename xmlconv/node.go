@@ -0,0 +1,161 @@
+package xmlconv
+
+import (
+	"bytes"
+)
+
+// NodeKind tells what an Item stands for. Items are elements by default, and
+// other kinds of nodes only appear in Nodes, Prolog and Epilog.
+type NodeKind int
+
+const (
+	// ElementNode is an element, with name, attributes and child nodes
+	ElementNode NodeKind = iota
+	// TextNode is character data written as escaped text
+	TextNode
+	// CDataNode is character data written in a CDATA section
+	CDataNode
+	// CommentNode is a comment, with its text as data
+	CommentNode
+	// ProcInstNode is a processing instruction, with its target as name and
+	// the instruction as data. The XML declaration is a ProcInstNode with
+	// target "xml".
+	ProcInstNode
+	// DirectiveNode is a directive such as DOCTYPE, with the text between
+	// "<!" and ">" as data
+	DirectiveNode
+	// AttrNode is an attribute returned by XPath queries, with the owner
+	// element as parent. Changing it does not change the owner.
+	AttrNode
+)
+
+// documentNode is the root node of XPath, above the root element
+const documentNode NodeKind = -1
+
+// NewText creates a text node
+func NewText(s string) *Item {
+	return &Item{kind: TextNode, data: []byte(s)}
+}
+
+// NewCDATA creates a text node written as CDATA
+func NewCDATA(s string) *Item {
+	return &Item{kind: CDataNode, data: []byte(s)}
+}
+
+// NewComment creates a comment node
+func NewComment(s string) *Item {
+	return &Item{kind: CommentNode, data: []byte(s)}
+}
+
+// NewProcInst creates a processing instruction, e.g.
+// NewProcInst("xml-stylesheet", `href="style.xsl" type="text/xsl"`)
+func NewProcInst(target, inst string) *Item {
+	return &Item{kind: ProcInstNode, name: target, data: []byte(inst)}
+}
+
+// NewDirective creates a directive, e.g. NewDirective("DOCTYPE html")
+func NewDirective(s string) *Item {
+	return &Item{kind: DirectiveNode, data: []byte(s)}
+}
+
+// Kind returns the node kind of x
+func (x *Item) Kind() NodeKind {
+	return x.kind
+}
+
+// Nodes returns all direct child nodes in document order, including text,
+// comments and processing instructions. The returned slice should not be
+// modified.
+func (x *Item) Nodes() []*Item {
+	return x.child
+}
+
+// Prolog returns nodes before the root element of a parsed document, such as
+// the XML declaration, DOCTYPE and comments
+func (x *Item) Prolog() []*Item {
+	return x.prolog
+}
+
+// SetProlog replaces nodes before the root element. They are written only
+// with Option.Preserve.
+func (x *Item) SetProlog(nodes ...*Item) {
+	x.prolog = nodes
+}
+
+// Epilog returns nodes after the root element of a parsed document
+func (x *Item) Epilog() []*Item {
+	return x.epilog
+}
+
+// SetEpilog replaces nodes after the root element. They are written only with
+// Option.Preserve.
+func (x *Item) SetEpilog(nodes ...*Item) {
+	x.epilog = nodes
+}
+
+// Declaration returns the XML declaration of a parsed document, e.g.
+// `version="1.0" encoding="UTF-8"`
+func (x *Item) Declaration() (string, bool) {
+	for _, n := range x.prolog {
+		if ProcInstNode == n.kind && "xml" == n.name {
+			return string(n.data), true
+		}
+	}
+	return "", false
+}
+
+func (x *Item) isText() bool {
+	return TextNode == x.kind || CDataNode == x.kind
+}
+
+// text returns character data of an element, i.e. its direct text nodes
+// concatenated and trimmed
+func (x *Item) text() []byte {
+	var ret []byte
+	n := 0
+	for _, c := range x.child {
+		if false == c.isText() {
+			continue
+		}
+		if 0 == n {
+			ret = c.data
+		} else {
+			if 1 == n {
+				ret = append([]byte{}, ret...)
+			}
+			ret = append(ret, c.data...)
+		}
+		n++
+	}
+	return bytes.Trim(ret, "\r\n\t ")
+}
+
+// setText replaces text nodes of an element with b, placed where the first
+// text node was and kept as CDATA if it was
+func (x *Item) setText(b []byte) {
+	pos := -1
+	kind := TextNode
+	children := x.child[:0]
+	for _, c := range x.child {
+		if c.isText() {
+			if pos < 0 {
+				pos = len(children)
+				kind = c.kind
+			}
+			c.parent = nil
+			continue
+		}
+		children = append(children, c)
+	}
+	for i := len(children); i < len(x.child); i++ {
+		x.child[i] = nil
+	}
+	x.child = children
+	if 0 == len(b) {
+		return
+	}
+	if pos < 0 {
+		pos = 0
+	}
+	x.InsertChild(pos, &Item{kind: kind, data: b})
+}
@@ -0,0 +1,83 @@
+package xmlconv
+
+import (
+	"testing"
+)
+
+const mixedDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE note SYSTEM "note.dtd">
+<!-- partner document -->
+<note id="1">
+  <?render mode="full"?>
+  <p>Hello <b>world</b>, a &amp; b &lt; c</p>
+  <code><![CDATA[if a < b {}]]></code>
+  <!-- end -->
+</note>
+<!-- trailer -->`
+
+func TestPreserveRoundTrip(t *testing.T) {
+	x, err := NewFromString(mixedDoc)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+	if decl, exist := x.Declaration(); false == exist || decl != `version="1.0" encoding="UTF-8"` {
+		t.Errorf("unexpected declaration: %q", decl)
+	}
+	if len(x.Prolog()) == 0 || len(x.Epilog()) == 0 {
+		t.Errorf("prolog or epilog missing")
+	}
+
+	s, _ := x.Marshal(Option{Preserve: true})
+	if s != mixedDoc {
+		t.Errorf("unexpected preserved result:\n%s", s)
+	}
+
+	s, _ = x.Marshal()
+	expected := `<note id="1"><?render mode="full"?><p>Hello<b>world</b><![CDATA[, a & b < c]]></p>` +
+		`<code><![CDATA[if a < b {}]]></code><!-- end --></note>`
+	if s != expected {
+		t.Errorf("unexpected default result:\n%s", s)
+	}
+}
+
+func TestMixedText(t *testing.T) {
+	x, _ := NewFromString(`<p>a <![CDATA[<b>]]> c<i>x</i> d </p>`)
+	if s := x.String(); s != "a <b> c d" {
+		t.Errorf("unexpected text: %q", s)
+	}
+	if n := len(x.Nodes()); n != 5 {
+		t.Errorf("unexpected node count: %d", n)
+	}
+	if n := len(x.ChildList()); n != 1 {
+		t.Errorf("unexpected element count: %d", n)
+	}
+
+	// CDATA is kept when text is replaced
+	c, _ := NewFromString(`<c><![CDATA[old]]><x/></c>`)
+	c.SetString("new")
+	if s, _ := c.Marshal(Option{Preserve: true}); s != `<c><![CDATA[new]]><x></x></c>` {
+		t.Errorf("unexpected result: %s", s)
+	}
+}
+
+func TestBuildNodes(t *testing.T) {
+	x := NewItem("r")
+	x.AppendChild(NewComment(" generated "))
+	x.AppendChild(NewText("1 < 2"))
+	x.AppendChild(NewCDATA("a]]>b"))
+	x.SetProlog(NewProcInst("xml", `version="1.0"`), NewText("\n"), NewDirective("DOCTYPE r"), NewText("\n"))
+
+	s, _ := x.Marshal(Option{Preserve: true})
+	expected := "<?xml version=\"1.0\"?>\n<!DOCTYPE r>\n<r><!-- generated -->1 &lt; 2<![CDATA[a]]]]><![CDATA[>b]]></r>"
+	if s != expected {
+		t.Errorf("unexpected result:\n%s", s)
+	}
+
+	y, err := NewFromString(s)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+	if y.String() != "1 < 2a]]>b" {
+		t.Errorf("unexpected text: %q", y.String())
+	}
+}
@@ -0,0 +1,1050 @@
+package xmlconv
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XSDNamespace is the namespace of XML Schema elements and built-in types
+const XSDNamespace = "http://www.w3.org/2001/XMLSchema"
+
+// xsiNamespace is the namespace of attributes such as xsi:schemaLocation,
+// which are always allowed
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// Schema is a compiled subset of XML Schema 1.0, safe for concurrent use.
+//
+// Supported are global and local elements, element references, named and
+// anonymous complex and simple types, sequence, choice and all with
+// minOccurs and maxOccurs, mixed content, simpleContent extensions,
+// attributes with use="required", and simple type restrictions with
+// enumeration, pattern, length, minLength, maxLength, minInclusive,
+// maxInclusive, minExclusive, maxExclusive, totalDigits and fractionDigits
+// facets of built-in types.
+type Schema struct {
+	target    string
+	qualified bool
+	elements  map[string]*xsElement
+}
+
+// ValidationError is a violation found by Schema.Validate, with the path of
+// the element or attribute, e.g. "/order/item[2]/@sku"
+type ValidationError struct {
+	Path string
+	Msg  string
+}
+
+func (e *ValidationError) Error() string {
+	return "xmlconv: " + e.Path + ": " + e.Msg
+}
+
+// ValidationErrors are all violations found by Schema.Validate, those of each
+// element before those of its children
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	s := make([]string, 0, len(e))
+	for _, v := range e {
+		s = append(s, v.Error())
+	}
+	return strings.Join(s, "; ")
+}
+
+type xsElement struct {
+	name    string
+	space   string
+	simple  *xsSimpleType
+	complex *xsComplexType
+	// both nil for xs:anyType
+}
+
+type xsComplexType struct {
+	content *xsParticle // nil for empty content
+	mixed   bool
+	text    *xsSimpleType // simpleContent
+	attrs   []*xsAttribute
+}
+
+type xsAttribute struct {
+	name     string
+	simple   *xsSimpleType
+	required bool
+}
+
+type particleKind int
+
+const (
+	elementParticle particleKind = iota
+	sequenceParticle
+	choiceParticle
+	allParticle
+)
+
+type xsParticle struct {
+	kind  particleKind
+	elem  *xsElement
+	items []*xsParticle
+	min   int
+	max   int // -1 for unbounded
+}
+
+type xsSimpleType struct {
+	base     string // local name of the built-in type
+	enum     []string
+	patterns []*regexp.Regexp
+	min, max *big.Rat
+	minExcl  bool
+	maxExcl  bool
+	length   int // -1 for none
+	minLen   int
+	maxLen   int
+	// digit limits of decimal values, -1 for none
+	totalDigits    int
+	fractionDigits int
+}
+
+// ====================
+// loading
+
+// ParseSchema compiles an XML Schema document
+func ParseSchema(b []byte) (*Schema, error) {
+	x, err := NewFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(x)
+}
+
+// NewSchema compiles a parsed xs:schema element. Errors are *PathError with
+// FormatError for malformed schemas, or ParaError for unsupported features.
+func NewSchema(x *Item) (*Schema, error) {
+	if nil == x || XSDNamespace != x.space || "schema" != x.name {
+		return nil, ParaError
+	}
+	l := &schemaLoader{
+		s: &Schema{
+			elements: map[string]*xsElement{},
+		},
+		defs:    map[string]*Item{},
+		complex: map[string]*xsComplexType{},
+		simple:  map[string]*xsSimpleType{},
+	}
+	l.s.target, _ = x.GetAttr("targetNamespace")
+	form, _ := x.GetAttr("elementFormDefault")
+	l.s.qualified = "qualified" == form
+
+	for _, c := range x.ChildList() {
+		if XSDNamespace != c.space {
+			continue
+		}
+		switch c.name {
+		case "element", "complexType", "simpleType":
+			name, _ := c.GetAttr("name")
+			if "" == name {
+				return nil, schemaError(c, FormatError)
+			}
+			l.defs[c.name+" "+name] = c
+		case "annotation", "import":
+		default:
+			return nil, schemaError(c, ParaError)
+		}
+	}
+	for key, c := range l.defs {
+		if strings.HasPrefix(key, "element ") {
+			if _, err := l.globalElement(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l.s, nil
+}
+
+type schemaLoader struct {
+	s       *Schema
+	defs    map[string]*Item // global definitions by kind and name
+	complex map[string]*xsComplexType
+	simple  map[string]*xsSimpleType
+}
+
+func schemaError(x *Item, err error) error {
+	return &PathError{Path: itemPath(x), Err: err}
+}
+
+// typeName resolves a QName such as "xs:int" in the scope of x, returning
+// whether it is built-in
+func typeName(x *Item, qname string) (local string, builtin bool) {
+	prefix, local := splitQName(qname)
+	space, _ := x.LookupNamespace(prefix)
+	return local, XSDNamespace == space
+}
+
+func (l *schemaLoader) globalElement(x *Item) (*xsElement, error) {
+	name, _ := x.GetAttr("name")
+	key := "{" + l.s.target + "}" + name
+	if e, exist := l.s.elements[key]; exist {
+		return e, nil
+	}
+	e := &xsElement{name: name, space: l.s.target}
+	l.s.elements[key] = e
+	return e, l.elementType(x, e)
+}
+
+func (l *schemaLoader) localElement(x *Item) (*xsElement, error) {
+	if ref, exist := x.GetAttr("ref"); exist {
+		name, _ := typeName(x, ref)
+		def := l.defs["element "+name]
+		if nil == def {
+			return nil, schemaError(x, FormatError)
+		}
+		return l.globalElement(def)
+	}
+	name, _ := x.GetAttr("name")
+	if "" == name {
+		return nil, schemaError(x, FormatError)
+	}
+	e := &xsElement{name: name}
+	if form, _ := x.GetAttr("form"); "qualified" == form || ("" == form && l.s.qualified) {
+		e.space = l.s.target
+	}
+	return e, l.elementType(x, e)
+}
+
+func (l *schemaLoader) elementType(x *Item, e *xsElement) error {
+	var err error
+	if t, exist := x.GetAttr("type"); exist {
+		e.simple, e.complex, err = l.namedType(x, t)
+		return err
+	}
+	for _, c := range x.ChildList() {
+		switch c.name {
+		case "complexType":
+			e.complex = &xsComplexType{}
+			return l.complexType(c, e.complex)
+		case "simpleType":
+			e.simple, err = l.simpleType(c)
+			return err
+		}
+	}
+	return nil
+}
+
+// namedType resolves the type of an element by name. Both are nil for
+// xs:anyType.
+func (l *schemaLoader) namedType(x *Item, qname string) (*xsSimpleType, *xsComplexType, error) {
+	name, builtin := typeName(x, qname)
+	if builtin {
+		if "anyType" == name {
+			return nil, nil, nil
+		}
+		t, err := builtinType(name)
+		if err != nil {
+			return nil, nil, schemaError(x, err)
+		}
+		return t, nil, nil
+	}
+	if t, exist := l.complex[name]; exist {
+		return nil, t, nil
+	}
+	if def := l.defs["complexType "+name]; nil != def {
+		// registered before loading for recursive types
+		t := &xsComplexType{}
+		l.complex[name] = t
+		return nil, t, l.complexType(def, t)
+	}
+	t, err := l.namedSimpleType(x, qname)
+	return t, nil, err
+}
+
+func (l *schemaLoader) namedSimpleType(x *Item, qname string) (*xsSimpleType, error) {
+	name, builtin := typeName(x, qname)
+	if builtin {
+		t, err := builtinType(name)
+		if err != nil {
+			return nil, schemaError(x, err)
+		}
+		return t, nil
+	}
+	if t, exist := l.simple[name]; exist {
+		return t, nil
+	}
+	def := l.defs["simpleType "+name]
+	if nil == def {
+		return nil, schemaError(x, FormatError)
+	}
+	t, err := l.simpleType(def)
+	if err != nil {
+		return nil, err
+	}
+	l.simple[name] = t
+	return t, nil
+}
+
+func builtinType(name string) (*xsSimpleType, error) {
+	if _, exist := builtinChecks[name]; false == exist {
+		return nil, ParaError
+	}
+	return &xsSimpleType{
+		base: name, length: -1, minLen: -1, maxLen: -1,
+		totalDigits: -1, fractionDigits: -1,
+	}, nil
+}
+
+func (l *schemaLoader) complexType(x *Item, t *xsComplexType) error {
+	mixed, _ := x.GetAttr("mixed")
+	t.mixed = "true" == mixed || "1" == mixed
+	for _, c := range x.ChildList() {
+		var err error
+		switch c.name {
+		case "sequence", "choice", "all":
+			t.content, err = l.particle(c)
+		case "attribute":
+			err = l.attribute(c, t)
+		case "simpleContent":
+			err = l.simpleContent(c, t)
+		case "annotation":
+		default:
+			err = schemaError(c, ParaError)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *schemaLoader) simpleContent(x *Item, t *xsComplexType) error {
+	ext, exist := x.GetChild("extension")
+	if false == exist {
+		return schemaError(x, ParaError)
+	}
+	base, _ := ext.GetAttr("base")
+	var err error
+	if t.text, err = l.namedSimpleType(ext, base); err != nil {
+		return err
+	}
+	for _, c := range ext.ChildrenNamed("attribute") {
+		if err := l.attribute(c, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *schemaLoader) attribute(x *Item, t *xsComplexType) error {
+	name, _ := x.GetAttr("name")
+	if "" == name {
+		return schemaError(x, ParaError)
+	}
+	a := &xsAttribute{name: name}
+	use, _ := x.GetAttr("use")
+	a.required = "required" == use
+	var err error
+	if typ, exist := x.GetAttr("type"); exist {
+		a.simple, err = l.namedSimpleType(x, typ)
+	} else if c, exist := x.GetChild("simpleType"); exist {
+		a.simple, err = l.simpleType(c)
+	} else {
+		a.simple, _ = builtinType("anySimpleType")
+	}
+	if err != nil {
+		return err
+	}
+	t.attrs = append(t.attrs, a)
+	return nil
+}
+
+func occurs(x *Item) (min, max int, err error) {
+	min, max = 1, 1
+	if s, exist := x.GetAttr("minOccurs"); exist {
+		if min, err = strconv.Atoi(s); err != nil || min < 0 {
+			return 0, 0, schemaError(x, FormatError)
+		}
+	}
+	if s, exist := x.GetAttr("maxOccurs"); "unbounded" == s {
+		max = -1
+	} else if exist {
+		if max, err = strconv.Atoi(s); err != nil || max < min {
+			return 0, 0, schemaError(x, FormatError)
+		}
+	}
+	return min, max, nil
+}
+
+func (l *schemaLoader) particle(x *Item) (*xsParticle, error) {
+	min, max, err := occurs(x)
+	if err != nil {
+		return nil, err
+	}
+	p := &xsParticle{min: min, max: max}
+	switch x.name {
+	case "element":
+		p.kind = elementParticle
+		p.elem, err = l.localElement(x)
+		return p, err
+	case "sequence":
+		p.kind = sequenceParticle
+	case "choice":
+		p.kind = choiceParticle
+	case "all":
+		p.kind = allParticle
+	default:
+		return nil, schemaError(x, ParaError)
+	}
+	for _, c := range x.ChildList() {
+		if "annotation" == c.name {
+			continue
+		}
+		if allParticle == p.kind && "element" != c.name {
+			return nil, schemaError(c, FormatError)
+		}
+		item, err := l.particle(c)
+		if err != nil {
+			return nil, err
+		}
+		p.items = append(p.items, item)
+	}
+	return p, nil
+}
+
+func (l *schemaLoader) simpleType(x *Item) (*xsSimpleType, error) {
+	r, exist := x.GetChild("restriction")
+	if false == exist {
+		// xs:list and xs:union
+		return nil, schemaError(x, ParaError)
+	}
+	base, _ := r.GetAttr("base")
+	parent, err := l.namedSimpleType(r, base)
+	if err != nil {
+		return nil, err
+	}
+	t := *parent
+	t.patterns = parent.patterns[:len(parent.patterns):len(parent.patterns)]
+
+	var enum, patterns []string
+	for _, f := range r.ChildList() {
+		v, _ := f.GetAttr("value")
+		var err error
+		switch f.name {
+		case "enumeration":
+			enum = append(enum, v)
+		case "pattern":
+			patterns = append(patterns, "(?:"+v+")")
+		case "minInclusive", "minExclusive":
+			t.min, err = parseRat(f, v)
+			t.minExcl = "minExclusive" == f.name
+		case "maxInclusive", "maxExclusive":
+			t.max, err = parseRat(f, v)
+			t.maxExcl = "maxExclusive" == f.name
+		case "length":
+			t.length, err = parseLength(f, v)
+		case "minLength":
+			t.minLen, err = parseLength(f, v)
+		case "maxLength":
+			t.maxLen, err = parseLength(f, v)
+		case "totalDigits":
+			t.totalDigits, err = parseLength(f, v)
+			if nil == err && 0 == t.totalDigits {
+				err = schemaError(f, FormatError)
+			}
+		case "fractionDigits":
+			t.fractionDigits, err = parseLength(f, v)
+		case "annotation", "whiteSpace":
+		default:
+			err = schemaError(f, ParaError)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(enum) > 0 {
+		t.enum = enum
+	}
+	// patterns of a restriction are alternatives, while those of each
+	// derivation step must all match
+	if len(patterns) > 0 {
+		re, err := regexp.Compile("^(?:" + strings.Join(patterns, "|") + ")$")
+		if err != nil {
+			return nil, schemaError(r, FormatError)
+		}
+		t.patterns = append(t.patterns, re)
+	}
+	return &t, nil
+}
+
+func parseRat(x *Item, v string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(v))
+	if false == ok {
+		return nil, schemaError(x, FormatError)
+	}
+	return r, nil
+}
+
+func parseLength(x *Item, v string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 0 {
+		return 0, schemaError(x, FormatError)
+	}
+	return n, nil
+}
+
+// ====================
+// validating
+
+// Validate checks x against global element declarations of the schema,
+// returning nil or ValidationErrors with all violations found. Values are
+// trimmed before checking.
+func (s *Schema) Validate(x *Item) error {
+	if nil == x || ElementNode != x.kind {
+		return ParaError
+	}
+	v := &validator{}
+	path := "/" + x.QName()
+	if e, exist := s.elements["{"+x.space+"}"+x.name]; exist {
+		v.element(x, e, path)
+	} else {
+		v.report(path, "no declaration for element "+strconv.Quote(x.QName()))
+	}
+	if 0 == len(v.errs) {
+		return nil
+	}
+	return v.errs
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) report(path, msg string) {
+	v.errs = append(v.errs, &ValidationError{Path: path, Msg: msg})
+}
+
+func (v *validator) element(x *Item, e *xsElement, path string) {
+	switch {
+	case nil != e.complex:
+		v.complex(x, e.complex, path)
+	case nil != e.simple:
+		v.attributes(x, nil, path)
+		if children := x.ChildList(); len(children) > 0 {
+			v.report(childPath(path, children[0]), "unexpected element "+strconv.Quote(children[0].QName()))
+			return
+		}
+		v.simple(string(x.text()), e.simple, path)
+	}
+}
+
+func (v *validator) complex(x *Item, t *xsComplexType, path string) {
+	v.attributes(x, t.attrs, path)
+	children := x.ChildList()
+	if nil != t.text {
+		if len(children) > 0 {
+			v.report(childPath(path, children[0]), "unexpected element "+strconv.Quote(children[0].QName()))
+			return
+		}
+		v.simple(string(x.text()), t.text, path)
+		return
+	}
+	if false == t.mixed && len(x.text()) > 0 {
+		v.report(path, "unexpected text in element-only content")
+	}
+
+	if nil == t.content {
+		if len(children) > 0 {
+			v.report(childPath(path, children[0]), "unexpected element "+strconv.Quote(children[0].QName()))
+		}
+		return
+	}
+	m := &contentMatcher{children: children, model: t.content}
+	m.report = func(pos int, unexpected bool, expected []*xsElement) {
+		var names []string
+		for _, e := range expected {
+			names = append(names, strconv.Quote(e.String()))
+		}
+		switch {
+		case false == unexpected:
+			v.report(path, "missing element "+strings.Join(names, " or "))
+		case 0 == len(names):
+			v.report(childPath(path, children[pos]), "unexpected element "+strconv.Quote(children[pos].QName()))
+		default:
+			v.report(childPath(path, children[pos]), "unexpected element "+strconv.Quote(children[pos].QName())+", expecting "+strings.Join(names, " or "))
+		}
+	}
+	if end, _ := m.recover(t.content, 0, false); end < len(children) {
+		var expected []*xsElement
+		if m.furthest == end {
+			expected = m.expected
+		}
+		m.report(end, true, expected)
+	}
+
+	for _, c := range children {
+		if e := t.content.find(c); nil != e {
+			v.element(c, e, childPath(path, c))
+		}
+	}
+}
+
+func (v *validator) attributes(x *Item, attrs []*xsAttribute, path string) {
+	for _, a := range attrs {
+		val, exist := x.attrs[a.name]
+		if false == exist {
+			if a.required {
+				v.report(path, "missing required attribute "+strconv.Quote(a.name))
+			}
+			continue
+		}
+		v.simple(val, a.simple, path+"/@"+a.name)
+	}
+next:
+	for _, n := range x.attrNames() {
+		if xsiNamespace == n.space || strings.HasPrefix(n.qname, "xml:") {
+			continue
+		}
+		for _, a := range attrs {
+			if a.name == n.qname {
+				continue next
+			}
+		}
+		v.report(path+"/@"+n.qname, "unexpected attribute")
+	}
+}
+
+func (v *validator) simple(s string, t *xsSimpleType, path string) {
+	s = strings.TrimSpace(s)
+	quoted := strconv.Quote(s)
+	if false == builtinChecks[t.base](s) {
+		v.report(path, "value "+quoted+" is not a valid "+t.base)
+		return
+	}
+	if len(t.enum) > 0 {
+		found := false
+		for _, e := range t.enum {
+			found = found || e == s
+		}
+		if false == found {
+			v.report(path, "value "+quoted+" is not one of "+strings.Join(t.enum, ", "))
+		}
+	}
+	for _, re := range t.patterns {
+		if false == re.MatchString(s) {
+			v.report(path, "value "+quoted+" does not match pattern")
+			break
+		}
+	}
+	if nil != t.min || nil != t.max {
+		if r, ok := new(big.Rat).SetString(s); ok {
+			if nil != t.min {
+				if c := r.Cmp(t.min); c < 0 || (0 == c && t.minExcl) {
+					v.report(path, "value "+quoted+" is less than minimum "+t.min.RatString())
+				}
+			}
+			if nil != t.max {
+				if c := r.Cmp(t.max); c > 0 || (0 == c && t.maxExcl) {
+					v.report(path, "value "+quoted+" is greater than maximum "+t.max.RatString())
+				}
+			}
+		}
+	}
+	if t.totalDigits >= 0 || t.fractionDigits >= 0 {
+		if total, fraction, ok := decimalDigits(s); ok {
+			if t.totalDigits >= 0 && total > t.totalDigits {
+				v.report(path, "value "+quoted+" has more than "+strconv.Itoa(t.totalDigits)+" digits")
+			}
+			if t.fractionDigits >= 0 && fraction > t.fractionDigits {
+				v.report(path, "value "+quoted+" has more than "+strconv.Itoa(t.fractionDigits)+" fraction digits")
+			}
+		}
+	}
+	n := len([]rune(s))
+	switch {
+	case t.length >= 0 && n != t.length:
+		v.report(path, "length of "+quoted+" is not "+strconv.Itoa(t.length))
+	case t.minLen >= 0 && n < t.minLen:
+		v.report(path, "length of "+quoted+" is less than "+strconv.Itoa(t.minLen))
+	case t.maxLen >= 0 && n > t.maxLen:
+		v.report(path, "length of "+quoted+" is greater than "+strconv.Itoa(t.maxLen))
+	}
+}
+
+// decimalDigits counts significant digits of a decimal literal like
+// "-012.340", which has 4 digits in total and 2 of them in the fraction. ok is
+// false for other forms, e.g. float values with exponent.
+func decimalDigits(s string) (total, fraction int, ok bool) {
+	s = strings.TrimLeft(s, "+-")
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return 0, 0, false
+		}
+	}
+	intPart = strings.TrimLeft(intPart, "0")
+	fracPart = strings.TrimRight(fracPart, "0")
+	return len(intPart) + len(fracPart), len(fracPart), true
+}
+
+// contentMatcher matches child elements against a content model greedily,
+// which is enough for schemas following the Unique Particle Attribution rule
+type contentMatcher struct {
+	children []*Item
+	model    *xsParticle
+	// furthest is the furthest position where elements were expected
+	furthest int
+	expected []*xsElement
+	// report receives violations found by recover, either a missing particle
+	// at position pos, or an unexpected child there
+	report func(pos int, unexpected bool, expected []*xsElement)
+}
+
+func (m *contentMatcher) expect(pos int, e *xsElement) {
+	if pos > m.furthest {
+		m.furthest, m.expected = pos, nil
+	} else if pos < m.furthest {
+		return
+	}
+	for _, it := range m.expected {
+		if it == e {
+			return
+		}
+	}
+	m.expected = append(m.expected, e)
+}
+
+// String returns the name of e, as "{uri}local" if in a namespace
+func (e *xsElement) String() string {
+	if "" == e.space {
+		return e.name
+	}
+	return "{" + e.space + "}" + e.name
+}
+
+func (e *xsElement) matches(x *Item) bool {
+	return e.name == x.name && e.space == x.space
+}
+
+// match consumes children from position i by p, returning the end position
+func (m *contentMatcher) match(p *xsParticle, i int) (int, bool) {
+	n, j := 0, i
+	for p.max < 0 || n < p.max {
+		k, ok := m.matchOnce(p, j)
+		if false == ok {
+			break
+		}
+		n++
+		if k == j {
+			// matching nothing for once also does for any times
+			if n < p.min {
+				n = p.min
+			}
+			break
+		}
+		j = k
+	}
+	if n < p.min {
+		return i, false
+	}
+	return j, true
+}
+
+func (m *contentMatcher) matchOnce(p *xsParticle, i int) (int, bool) {
+	switch p.kind {
+	case elementParticle:
+		if i < len(m.children) && p.elem.matches(m.children[i]) {
+			return i + 1, true
+		}
+		m.expect(i, p.elem)
+		return i, false
+
+	case sequenceParticle:
+		j := i
+		for _, it := range p.items {
+			var ok bool
+			if j, ok = m.match(it, j); false == ok {
+				return i, false
+			}
+		}
+		return j, true
+
+	case choiceParticle:
+		empty := false
+		for _, it := range p.items {
+			j, ok := m.match(it, i)
+			if ok && j > i {
+				return j, true
+			}
+			empty = empty || ok
+		}
+		return i, empty
+
+	default:
+		used := make([]bool, len(p.items))
+		j := i
+	loop:
+		for j < len(m.children) {
+			for k, it := range p.items {
+				if false == used[k] && it.elem.matches(m.children[j]) {
+					used[k] = true
+					j++
+					continue loop
+				}
+			}
+			break
+		}
+		ok := true
+		for k, it := range p.items {
+			if false == used[k] {
+				m.expect(j, it.elem)
+				ok = ok && 0 == it.min
+			}
+		}
+		if false == ok {
+			return i, false
+		}
+		return j, true
+	}
+}
+
+// recover works like match, but when p can not be matched it reports what is
+// wrong and goes on, so that every missing particle is reported rather than
+// only the first one. Children not declared anywhere in the content model are
+// reported as unexpected and skipped. pending tells that such a report,
+// listing what was expected, also stands for the next missing particle.
+func (m *contentMatcher) recover(p *xsParticle, i int, pending bool) (int, bool) {
+	if j, ok := m.match(p, i); ok {
+		return j, pending && j == i
+	}
+	j := i
+	for j < len(m.children) && nil == m.model.find(m.children[j]) {
+		m.report(j, true, p.firsts(nil))
+		pending = true
+		j++
+		if k, ok := m.match(p, j); ok {
+			return k, pending && k == j
+		}
+	}
+	missing := func(expected ...*xsElement) {
+		if pending {
+			pending = false
+		} else {
+			m.report(j, false, expected)
+		}
+	}
+
+	switch p.kind {
+	case elementParticle:
+		n := 0
+		for j < len(m.children) && p.elem.matches(m.children[j]) {
+			j++
+			n++
+		}
+		if n > 0 {
+			pending = false
+		}
+		if n < p.min {
+			missing(p.elem)
+		}
+
+	case sequenceParticle:
+		for _, it := range p.items {
+			j, pending = m.recover(it, j, pending)
+		}
+		if p.max < 0 || p.max > 1 {
+			rest := *p
+			rest.min = 0
+			if rest.max > 0 {
+				rest.max--
+			}
+			if k, ok := m.match(&rest, j); ok && k > j {
+				j, pending = k, false
+			}
+		}
+
+	case choiceParticle:
+		missing(p.firsts(nil)...)
+
+	default:
+		used := make([]bool, len(p.items))
+	loop:
+		for j < len(m.children) {
+			for k, it := range p.items {
+				if false == used[k] && it.elem.matches(m.children[j]) {
+					used[k] = true
+					j++
+					pending = false
+					continue loop
+				}
+			}
+			break
+		}
+		for k, it := range p.items {
+			if false == used[k] && it.min > 0 {
+				missing(it.elem)
+			}
+		}
+	}
+	return j, pending
+}
+
+// firsts appends elements which may start p to ret
+func (p *xsParticle) firsts(ret []*xsElement) []*xsElement {
+	switch p.kind {
+	case elementParticle:
+		return append(ret, p.elem)
+	case sequenceParticle:
+		for _, it := range p.items {
+			ret = it.firsts(ret)
+			if it.min > 0 {
+				break
+			}
+		}
+	default:
+		for _, it := range p.items {
+			ret = it.firsts(ret)
+		}
+	}
+	return ret
+}
+
+// find returns the declaration of child element x in content model p
+func (p *xsParticle) find(x *Item) *xsElement {
+	if elementParticle == p.kind {
+		if p.elem.matches(x) {
+			return p.elem
+		}
+		return nil
+	}
+	for _, it := range p.items {
+		if e := it.find(x); nil != e {
+			return e
+		}
+	}
+	return nil
+}
+
+// childPath returns the path of element x under parent path, with a position
+// if it has siblings of the same name, e.g. "/order/item[2]"
+func childPath(parent string, x *Item) string {
+	path := parent + "/" + x.QName()
+	if nil == x.parent {
+		return path
+	}
+	n, pos := 0, 0
+	for _, c := range x.parent.child {
+		if ElementNode == c.kind && c.name == x.name && c.prefix == x.prefix {
+			n++
+			if c == x {
+				pos = n
+			}
+		}
+	}
+	if n > 1 {
+		path += "[" + strconv.Itoa(pos) + "]"
+	}
+	return path
+}
+
+func itemPath(x *Item) string {
+	if nil == x.parent {
+		return "/" + x.QName()
+	}
+	return childPath(itemPath(x.parent), x)
+}
+
+var (
+	decimalRegexp = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+	integerRegexp = regexp.MustCompile(`^[+-]?\d+$`)
+)
+
+func validString(string) bool {
+	return true
+}
+
+func validFloat(s string) bool {
+	switch s {
+	case "INF", "-INF", "NaN":
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func validTime(layouts ...string) func(string) bool {
+	return func(s string) bool {
+		for _, l := range layouts {
+			if _, err := time.Parse(l, s); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// validInteger checks an integer within [min, max], either of which may be
+// "" for no limit
+func validInteger(min, max string) func(string) bool {
+	return func(s string) bool {
+		if false == integerRegexp.MatchString(s) {
+			return false
+		}
+		n, _ := new(big.Int).SetString(strings.TrimPrefix(s, "+"), 10)
+		if b, ok := new(big.Int).SetString(min, 10); ok && n.Cmp(b) < 0 {
+			return false
+		}
+		if b, ok := new(big.Int).SetString(max, 10); ok && n.Cmp(b) > 0 {
+			return false
+		}
+		return true
+	}
+}
+
+// builtinChecks are the supported built-in simple types of XML Schema
+var builtinChecks = map[string]func(string) bool{
+	"anySimpleType":    validString,
+	"string":           validString,
+	"normalizedString": validString,
+	"token":            validString,
+	"language":         validString,
+	"Name":             validString,
+	"NCName":           validString,
+	"NMTOKEN":          validString,
+	"ID":               validString,
+	"IDREF":            validString,
+	"QName":            validString,
+	"anyURI":           validString,
+	"boolean": func(s string) bool {
+		return "true" == s || "false" == s || "1" == s || "0" == s
+	},
+	"decimal":            decimalRegexp.MatchString,
+	"float":              validFloat,
+	"double":             validFloat,
+	"integer":            validInteger("", ""),
+	"nonNegativeInteger": validInteger("0", ""),
+	"positiveInteger":    validInteger("1", ""),
+	"nonPositiveInteger": validInteger("", "0"),
+	"negativeInteger":    validInteger("", "-1"),
+	"long":               validInteger("-9223372036854775808", "9223372036854775807"),
+	"int":                validInteger("-2147483648", "2147483647"),
+	"short":              validInteger("-32768", "32767"),
+	"byte":               validInteger("-128", "127"),
+	"unsignedLong":       validInteger("0", "18446744073709551615"),
+	"unsignedInt":        validInteger("0", "4294967295"),
+	"unsignedShort":      validInteger("0", "65535"),
+	"unsignedByte":       validInteger("0", "255"),
+	"date":               validTime("2006-01-02", "2006-01-02Z07:00"),
+	"time":               validTime("15:04:05", "15:04:05Z07:00"),
+	"dateTime":           validTime("2006-01-02T15:04:05", "2006-01-02T15:04:05Z07:00"),
+	"base64Binary": func(s string) bool {
+		_, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+		return err == nil
+	},
+	"hexBinary": func(s string) bool {
+		_, err := hex.DecodeString(s)
+		return err == nil
+	},
+}
@@ -0,0 +1,253 @@
+package xmlconv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const orderXSD = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:simpleType name="skuType">
+    <xs:restriction base="xs:string">
+      <xs:pattern value="[A-Z]{3}-\d{4}"/>
+    </xs:restriction>
+  </xs:simpleType>
+  <xs:simpleType name="statusType">
+    <xs:restriction base="xs:token">
+      <xs:enumeration value="NEW"/>
+      <xs:enumeration value="PAID"/>
+    </xs:restriction>
+  </xs:simpleType>
+  <xs:complexType name="itemType">
+    <xs:sequence>
+      <xs:element name="qty">
+        <xs:simpleType>
+          <xs:restriction base="xs:positiveInteger">
+            <xs:maxInclusive value="100"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:element>
+      <xs:element name="price">
+        <xs:simpleType>
+          <xs:restriction base="xs:decimal">
+            <xs:minExclusive value="0"/>
+            <xs:totalDigits value="5"/>
+            <xs:fractionDigits value="2"/>
+          </xs:restriction>
+        </xs:simpleType>
+      </xs:element>
+    </xs:sequence>
+    <xs:attribute name="sku" type="skuType" use="required"/>
+  </xs:complexType>
+  <xs:element name="order">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="id" type="xs:int"/>
+        <xs:element name="customer">
+          <xs:complexType>
+            <xs:all>
+              <xs:element name="name" type="xs:string"/>
+              <xs:element name="address" type="xs:string" minOccurs="0"/>
+            </xs:all>
+          </xs:complexType>
+        </xs:element>
+        <xs:choice>
+          <xs:element name="email" type="xs:string"/>
+          <xs:element name="phone" type="xs:string"/>
+        </xs:choice>
+        <xs:element name="item" type="itemType" maxOccurs="3"/>
+        <xs:element name="status" type="statusType"/>
+        <xs:element name="note" minOccurs="0">
+          <xs:complexType>
+            <xs:simpleContent>
+              <xs:extension base="xs:string">
+                <xs:attribute name="lang" type="xs:language"/>
+              </xs:extension>
+            </xs:simpleContent>
+          </xs:complexType>
+        </xs:element>
+      </xs:sequence>
+      <xs:attribute name="version" type="xs:decimal" use="required"/>
+      <xs:attribute name="created" type="xs:dateTime"/>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+func TestSchemaValid(t *testing.T) {
+	s, err := ParseSchema([]byte(orderXSD))
+	if err != nil {
+		t.Fatalf("ParseSchema error: %v", err)
+	}
+	x, _ := NewFromString(`<order version="1.0" created="2026-10-19T08:00:00+08:00">
+  <id>42</id>
+  <customer><address>Shenzhen</address><name>Alice</name></customer>
+  <phone>10086</phone>
+  <item sku="ABC-0001"><qty>2</qty><price>9.90</price></item>
+  <item sku="XYZ-0002"><qty>100</qty><price>0.01</price></item>
+  <status> PAID </status>
+  <note lang="en">deliver at noon</note>
+</order>`)
+	if err := s.Validate(x); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSchemaViolations(t *testing.T) {
+	s, _ := ParseSchema([]byte(orderXSD))
+	x, _ := NewFromString(`<order created="yesterday" extra="1">
+  <id>4.2</id>
+  <customer><address>Shenzhen</address></customer>
+  <item sku="abc"><qty>0</qty><price>-12345.6</price></item>
+  <item><qty>101</qty><price>1.005</price><gift/></item>
+  <status>CLOSED</status>
+</order>`)
+	err := s.Validate(x)
+	errs, ok := err.(ValidationErrors)
+	if false == ok {
+		t.Fatalf("unexpected error type %T: %v", err, err)
+	}
+	expected := []string{
+		`/order: missing required attribute "version"`,
+		`/order/@created: value "yesterday" is not a valid dateTime`,
+		`/order/@extra: unexpected attribute`,
+		`/order: missing element "email" or "phone"`,
+		`/order/id: value "4.2" is not a valid int`,
+		`/order/customer: missing element "name"`,
+		`/order/item[1]/@sku: value "abc" does not match pattern`,
+		`/order/item[1]/qty: value "0" is not a valid positiveInteger`,
+		`/order/item[1]/price: value "-12345.6" is less than minimum 0`,
+		`/order/item[1]/price: value "-12345.6" has more than 5 digits`,
+		`/order/item[2]: missing required attribute "sku"`,
+		`/order/item[2]/gift: unexpected element "gift"`,
+		`/order/item[2]/qty: value "101" is greater than maximum 100`,
+		`/order/item[2]/price: value "1.005" has more than 2 fraction digits`,
+		`/order/status: value "CLOSED" is not one of NEW, PAID`,
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Path+": "+e.Msg)
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected violations:\n%s", strings.Join(got, "\n"))
+	}
+
+	x, _ = NewFromString(`<invoice/>`)
+	if err := s.Validate(x); nil == err || false == strings.Contains(err.Error(), "no declaration") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSchemaNamespace(t *testing.T) {
+	s, err := ParseSchema([]byte(`<schema xmlns="http://www.w3.org/2001/XMLSchema" xmlns:t="urn:t"
+    targetNamespace="urn:t" elementFormDefault="qualified">
+  <element name="list">
+    <complexType>
+      <sequence maxOccurs="unbounded">
+        <element name="key" type="string"/>
+        <element ref="t:value"/>
+      </sequence>
+    </complexType>
+  </element>
+  <element name="value" type="t:code"/>
+  <simpleType name="code">
+    <restriction base="string">
+      <length value="2"/>
+    </restriction>
+  </simpleType>
+</schema>`))
+	if err != nil {
+		t.Fatalf("ParseSchema error: %v", err)
+	}
+
+	x, _ := NewFromString(`<l:list xmlns:l="urn:t"><l:key>a</l:key><l:value>01</l:value><l:key>b</l:key><l:value>2</l:value></l:list>`)
+	err = s.Validate(x)
+	if nil == err || err.Error() != `xmlconv: /l:list/l:value[2]: length of "2" is not 2` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// not in the target namespace
+	x, _ = NewFromString(`<t:list xmlns:t="urn:t"><key>a</key><t:value>01</t:value></t:list>`)
+	err = s.Validate(x)
+	if nil == err || err.Error() != `xmlconv: /t:list/key: unexpected element "key", expecting "{urn:t}key"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSchemaMissingElements(t *testing.T) {
+	s, err := ParseSchema([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="row">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="id" type="xs:int"/>
+        <xs:element name="name" type="xs:string"/>
+        <xs:element name="qty" type="xs:int"/>
+        <xs:element name="tags">
+          <xs:complexType>
+            <xs:all>
+              <xs:element name="a" type="xs:string"/>
+              <xs:element name="b" type="xs:string"/>
+              <xs:element name="c" type="xs:string" minOccurs="0"/>
+            </xs:all>
+          </xs:complexType>
+        </xs:element>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`))
+	if err != nil {
+		t.Fatalf("ParseSchema error: %v", err)
+	}
+
+	cases := []struct {
+		xml      string
+		expected []string
+	}{
+		{`<row><qty>1</qty><tags><a/><b/></tags></row>`, []string{
+			`/row: missing element "id"`,
+			`/row: missing element "name"`,
+		}},
+		{`<row><id>1</id><extra/><qty>1</qty><tags><c/></tags></row>`, []string{
+			`/row/extra: unexpected element "extra", expecting "name"`,
+			`/row/tags: missing element "a"`,
+			`/row/tags: missing element "b"`,
+		}},
+		{`<row><id>1</id></row>`, []string{
+			`/row: missing element "name"`,
+			`/row: missing element "qty"`,
+			`/row: missing element "tags"`,
+		}},
+	}
+	for _, c := range cases {
+		x, _ := NewFromString(c.xml)
+		errs, _ := s.Validate(x).(ValidationErrors)
+		var got []string
+		for _, e := range errs {
+			got = append(got, e.Path+": "+e.Msg)
+		}
+		if strings.Join(got, "\n") != strings.Join(c.expected, "\n") {
+			t.Errorf("%s: unexpected violations:\n%s", c.xml, strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestSchemaError(t *testing.T) {
+	cases := []struct {
+		xsd string
+		err error
+	}{
+		{`<xs:element name="a"><xs:simpleType><xs:list itemType="xs:int"/></xs:simpleType></xs:element>`, ParaError},
+		{`<xs:element name="a" type="xs:duration"/>`, ParaError},
+		{`<xs:element name="a" type="undefined"/>`, FormatError},
+		{`<xs:element name="a"><xs:complexType><xs:sequence maxOccurs="x"/></xs:complexType></xs:element>`, FormatError},
+		{`<xs:element name="a"><xs:simpleType><xs:restriction base="xs:decimal"><xs:totalDigits value="0"/></xs:restriction></xs:simpleType></xs:element>`, FormatError},
+		{`<xs:element name="a"><xs:simpleType><xs:restriction base="xs:string"><xs:pattern value="(["/></xs:restriction></xs:simpleType></xs:element>`, FormatError},
+	}
+	for _, c := range cases {
+		_, err := ParseSchema([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">` + c.xsd + `</xs:schema>`))
+		var pe *PathError
+		if false == errors.As(err, &pe) || pe.Err != c.err {
+			t.Errorf("%s: unexpected error %v", c.xsd, err)
+		}
+	}
+}
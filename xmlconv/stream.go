@@ -0,0 +1,405 @@
+package xmlconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// EventKind tells what an Event is about
+type EventKind int
+
+const (
+	// StartEvent is a start tag. Event.Item has name, namespaces and
+	// attributes, but no child nodes yet.
+	StartEvent EventKind = iota
+	// EndEvent is an end tag, with the same Item as its StartEvent
+	EndEvent
+	// TextEvent is character data, with Item as a TextNode or CDataNode
+	TextEvent
+	// CommentEvent is a comment, with Item as a CommentNode
+	CommentEvent
+	// ProcInstEvent is a processing instruction, including the XML
+	// declaration, with Item as a ProcInstNode
+	ProcInstEvent
+	// DirectiveEvent is a directive such as DOCTYPE, with Item as a
+	// DirectiveNode
+	DirectiveEvent
+)
+
+// Event is a piece of an XML document read by Decoder.Next
+type Event struct {
+	Kind EventKind
+	Item *Item
+	// Depth is the number of open elements, including the element of a
+	// StartEvent or EndEvent. It is 0 for nodes outside the root element.
+	Depth int
+}
+
+// Decoder reads an XML document from an io.Reader piece by piece, with
+// memory bounded by the depth of the document instead of its size.
+//
+// Call Next for events, or register handlers with Handle and call Run to
+// receive a complete Item for each element on a given path.
+//
+// Elements have Parent set to their open ancestors, which keep their names,
+// namespaces and attributes, so that prefixes can be looked up. Ancestors do
+// not keep child nodes unless inside an element being handled.
+type Decoder struct {
+	dec      *xml.Decoder
+	rec      *recorder
+	stk      *stack
+	kept     []bool // whether each open element keeps its children
+	keepAll  bool
+	handlers []pathHandler
+	root     *Item
+	prolog   []*Item
+	rootDone bool
+}
+
+type pathHandler struct {
+	path []string
+	fn   func(*Item) error
+}
+
+// NewDecoder creates a Decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	rec := &recorder{r: bufio.NewReader(r)}
+	return &Decoder{
+		dec: xml.NewDecoder(rec),
+		rec: rec,
+		stk: newStack(),
+	}
+}
+
+// Handle registers fn to be called by Run with each element on path, e.g.
+// "/catalog/product", once it is closed. The element is detached afterwards,
+// so only one of them is held in memory at a time. Path segments may be names
+// in any form accepted by GetChild, or "*" for any element. Handlers of
+// nested paths are not called within an element being handled.
+func (d *Decoder) Handle(path string, fn func(*Item) error) {
+	var segments []string
+	for _, s := range strings.Split(strings.Trim(path, "/"), "/") {
+		if "" != s {
+			segments = append(segments, s)
+		}
+	}
+	d.handlers = append(d.handlers, pathHandler{path: segments, fn: fn})
+}
+
+// Run reads the whole document, calling handlers registered by Handle. An
+// error returned by a handler stops reading and is returned as is.
+func (d *Decoder) Run() error {
+	for {
+		_, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Path returns the path of the current element, e.g. "/catalog/product", or
+// "" outside the root element
+func (d *Decoder) Path() string {
+	if 0 == len(d.stk.L) {
+		return ""
+	}
+	var b strings.Builder
+	for _, it := range d.stk.L {
+		b.WriteByte('/')
+		b.WriteString(it.QName())
+	}
+	return b.String()
+}
+
+// Next returns the next event, or io.EOF at the end of the document
+func (d *Decoder) Next() (*Event, error) {
+	start := d.dec.InputOffset()
+	t, err := d.dec.RawToken()
+	if err != nil {
+		if err == io.EOF && (len(d.stk.L) > 0 || nil == d.root) {
+			return nil, FormatError
+		}
+		return nil, err
+	}
+	t = xml.CopyToken(t)
+	end := d.dec.InputOffset()
+	isCDATA := d.rec.hasPrefixAt(start, "<![CDATA[")
+	if se, ok := t.(xml.StartElement); ok {
+		normalizeAttrs(&se, d.rec.slice(start, end))
+		t = se
+	}
+	d.rec.discard(end)
+
+	depth := len(d.stk.L)
+	var curr *Item
+	if depth > 0 {
+		curr = d.stk.L[depth-1]
+	}
+
+	switch t := t.(type) {
+	case xml.StartElement:
+		if d.rootDone {
+			// multiple root elements
+			return nil, FormatError
+		}
+		item, err := newElement(t, curr)
+		if err != nil {
+			return nil, err
+		}
+		keep := d.keepAll || (depth > 0 && d.kept[depth-1])
+		if keep && nil != curr {
+			curr.child = append(curr.child, item)
+		}
+		if nil == curr {
+			d.root = item
+			item.prolog = d.prolog
+			d.prolog = nil
+		}
+		d.stk.Push(item)
+		if false == keep && nil != d.handlerFor() {
+			keep = true
+		}
+		d.kept = append(d.kept, keep)
+		return &Event{Kind: StartEvent, Item: item, Depth: depth + 1}, nil
+
+	case xml.EndElement:
+		if nil == curr || curr.name != t.Name.Local || curr.prefix != t.Name.Space {
+			return nil, FormatError
+		}
+		var h *pathHandler
+		if false == d.keepAll && d.kept[depth-1] && (1 == depth || false == d.kept[depth-2]) {
+			h = d.handlerFor()
+		}
+		d.stk.Pop()
+		d.kept = d.kept[:depth-1]
+		if 1 == depth {
+			d.rootDone = true
+		}
+		if nil != h {
+			if err := h.fn(curr); err != nil {
+				return nil, err
+			}
+			curr.parent = nil
+		}
+		return &Event{Kind: EndEvent, Item: curr, Depth: depth}, nil
+	}
+
+	var ev *Event
+	switch t := t.(type) {
+	case xml.CharData:
+		node := &Item{kind: TextNode, data: []byte(t)}
+		if isCDATA {
+			node.kind = CDataNode
+		}
+		ev = &Event{Kind: TextEvent, Item: node, Depth: depth}
+	case xml.Comment:
+		ev = &Event{Kind: CommentEvent, Item: NewComment(string(t)), Depth: depth}
+	case xml.ProcInst:
+		ev = &Event{Kind: ProcInstEvent, Item: NewProcInst(t.Target, string(t.Inst)), Depth: depth}
+	case xml.Directive:
+		ev = &Event{Kind: DirectiveEvent, Item: NewDirective(string(t)), Depth: depth}
+	default:
+		return d.Next()
+	}
+
+	switch {
+	case nil != curr:
+		ev.Item.parent = curr
+		if d.kept[depth-1] {
+			curr.child = append(curr.child, ev.Item)
+		}
+	case false == d.keepAll:
+		// nodes outside the root element are not kept
+	case nil == d.root:
+		d.prolog = append(d.prolog, ev.Item)
+	default:
+		d.root.epilog = append(d.root.epilog, ev.Item)
+	}
+	return ev, nil
+}
+
+// handlerFor returns the handler for the current path
+func (d *Decoder) handlerFor() *pathHandler {
+	for i := range d.handlers {
+		h := &d.handlers[i]
+		if len(h.path) != len(d.stk.L) {
+			continue
+		}
+		matched := true
+		for j, it := range d.stk.L {
+			if "*" == h.path[j] {
+				continue
+			}
+			m := it.matcher(h.path[j])
+			if false == m.matchItem(it) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return h
+		}
+	}
+	return nil
+}
+
+// newElement creates an element from a start tag, with prefixes resolved in
+// the scope of parent
+func newElement(t xml.StartElement, parent *Item) (*Item, error) {
+	item := NewItem("")
+	item.name = t.Name.Local
+	item.prefix = t.Name.Space
+	item.parent = parent
+
+	for _, a := range t.Attr {
+		switch {
+		case "xmlns" == a.Name.Space:
+			item.ns = append(item.ns, Namespace{Prefix: a.Name.Local, URI: a.Value})
+		case "" == a.Name.Space && "xmlns" == a.Name.Local:
+			item.ns = append(item.ns, Namespace{URI: a.Value})
+		}
+	}
+
+	item.space, _ = item.LookupNamespace(item.prefix)
+	for _, a := range t.Attr {
+		if "xmlns" == a.Name.Space || ("" == a.Name.Space && "xmlns" == a.Name.Local) {
+			continue
+		}
+		n := attrName{qname: a.Name.Local}
+		if "" != a.Name.Space {
+			n.qname = a.Name.Space + ":" + a.Name.Local
+			n.space, _ = item.LookupNamespace(a.Name.Space)
+		}
+		if _, exist := item.attrs[n.qname]; exist {
+			return nil, FormatError
+		}
+		item.attrs[n.qname] = a.Value
+		item.attrOrder = append(item.attrOrder, n)
+	}
+	return item, nil
+}
+
+// normalizeAttrs turns literal tabs and line breaks in attribute values of t
+// into spaces as required by XML 1.0 section 3.3.3, with raw being the source
+// of the start tag. xml.Decoder keeps them as is, which can not be told from
+// character references such as "&#x9;" afterwards.
+func normalizeAttrs(t *xml.StartElement, raw []byte) {
+	if false == bytes.ContainsAny(raw, "\t\n\r") {
+		return
+	}
+	isSpace := func(c byte) bool {
+		return ' ' == c || '\t' == c || '\n' == c || '\r' == c
+	}
+	i := bytes.IndexByte(raw, '<')
+	if i < 0 {
+		return
+	}
+	for i < len(raw) && false == isSpace(raw[i]) && '>' != raw[i] && '/' != raw[i] {
+		i++
+	}
+	for n := range t.Attr {
+		for i < len(raw) && '=' != raw[i] {
+			i++
+		}
+		for i++; i < len(raw) && isSpace(raw[i]); i++ {
+		}
+		if i >= len(raw) || ('"' != raw[i] && '\'' != raw[i]) {
+			return
+		}
+		q := raw[i]
+		l := bytes.IndexByte(raw[i+1:], q)
+		if l < 0 {
+			return
+		}
+		v := raw[i+1 : i+1+l]
+		i += l + 2
+		if false == bytes.ContainsAny(v, "\t\n\r") {
+			continue
+		}
+
+		// let xml.Decoder resolve references in the normalized value
+		v = bytes.Replace(v, []byte("\r\n"), []byte(" "), -1)
+		v = bytes.Map(func(r rune) rune {
+			if '\t' == r || '\n' == r || '\r' == r {
+				return ' '
+			}
+			return r
+		}, v)
+		src := make([]byte, 0, len(v)+8)
+		src = append(append(append(append(src, "<a v="...), q), v...), q)
+		src = append(src, "/>"...)
+		tok, err := xml.NewDecoder(bytes.NewReader(src)).RawToken()
+		if se, ok := tok.(xml.StartElement); ok && err == nil && 1 == len(se.Attr) {
+			t.Attr[n].Value = se.Attr[0].Value
+		}
+	}
+}
+
+// NewFromReader parses a whole document from r
+func NewFromReader(r io.Reader) (*Item, error) {
+	d := NewDecoder(r)
+	d.keepAll = true
+	if err := d.Run(); err != nil {
+		return nil, err
+	}
+	return d.root, nil
+}
+
+// recorder keeps bytes read by xml.Decoder since the last token, so that
+// CDATA sections can be told from other character data
+type recorder struct {
+	r    *bufio.Reader
+	buf  []byte
+	base int64 // offset of buf[0]
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+func (r *recorder) ReadByte() (byte, error) {
+	c, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, c)
+	}
+	return c, err
+}
+
+func (r *recorder) hasPrefixAt(off int64, prefix string) bool {
+	i := off - r.base
+	if i < 0 || i > int64(len(r.buf)) {
+		return false
+	}
+	return bytes.HasPrefix(r.buf[i:], []byte(prefix))
+}
+
+// slice returns recorded bytes between offsets from and to
+func (r *recorder) slice(from, to int64) []byte {
+	i, j := from-r.base, to-r.base
+	if i < 0 || j > int64(len(r.buf)) || i > j {
+		return nil
+	}
+	return r.buf[i:j]
+}
+
+// discard drops bytes before offset off
+func (r *recorder) discard(off int64) {
+	i := off - r.base
+	if i <= 0 {
+		return
+	}
+	if i > int64(len(r.buf)) {
+		i = int64(len(r.buf))
+	}
+	n := copy(r.buf, r.buf[i:])
+	r.buf = r.buf[:n]
+	r.base += i
+}
@@ -0,0 +1,127 @@
+package xmlconv
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDecoderEvents(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<?xml version="1.0"?><a x="1"><!--c--><b><![CDATA[<t>]]></b>text</a>`))
+	var got []string
+	for {
+		ev, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next error: %v", err)
+		}
+		var s string
+		switch ev.Kind {
+		case StartEvent:
+			s = "start:" + d.Path()
+		case EndEvent:
+			s = "end:" + ev.Item.Name()
+		case TextEvent:
+			s = "text:" + string(ev.Item.data)
+			if CDataNode == ev.Item.Kind() {
+				s = "cdata:" + string(ev.Item.data)
+			}
+		case CommentEvent:
+			s = "comment:" + string(ev.Item.data)
+		case ProcInstEvent:
+			s = "pi:" + ev.Item.Name()
+		}
+		got = append(got, s+"@"+strconv.Itoa(ev.Depth))
+	}
+	expected := "pi:xml@0,start:/a@1,comment:c@1,start:/a/b@2,cdata:<t>@2,end:b@2,text:text@1,end:a@1"
+	if strings.Join(got, ",") != expected {
+		t.Errorf("unexpected events: %s", strings.Join(got, ","))
+	}
+}
+
+// catalogReader generates a catalog with n products without holding it in
+// memory
+type catalogReader struct {
+	n, i int
+	buf  []byte
+}
+
+func (r *catalogReader) Read(p []byte) (int, error) {
+	for 0 == len(r.buf) {
+		switch {
+		case r.i < 0:
+			return 0, io.EOF
+		case 0 == r.i:
+			r.buf = []byte(`<catalog xmlns:p="urn:p"><meta>feed</meta>`)
+			r.i = 1
+		case r.i <= r.n:
+			id := strconv.Itoa(r.i)
+			r.buf = []byte(`<p:product id="` + id + `"><name><![CDATA[item ` + id + `]]></name><price>` + id + `</price></p:product>`)
+			r.i++
+		default:
+			r.buf = []byte(`</catalog>`)
+			r.i = -1
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestDecoderHandle(t *testing.T) {
+	const n = 10000
+	d := NewDecoder(&catalogReader{n: n})
+	count, sum := 0, 0
+	d.Handle("/catalog/p:product", func(x *Item) error {
+		count++
+		if x.Space() != "urn:p" || x.Parent().Name() != "catalog" {
+			t.Fatalf("unexpected product %s", x.QName())
+		}
+		if len(x.Parent().Nodes()) != 0 {
+			t.Fatalf("ancestor should not keep children")
+		}
+		name, _ := x.GetChild("name")
+		price, _ := x.GetChild("price")
+		id, _ := x.GetAttr("id")
+		if name.String() != "item "+id {
+			t.Fatalf("unexpected name %s for %s", name.String(), id)
+		}
+		v, _ := strconv.Atoi(price.String())
+		sum += v
+		return nil
+	})
+	var meta string
+	d.Handle("/*/meta", func(x *Item) error {
+		meta = x.String()
+		return nil
+	})
+	if err := d.Run(); err != nil {
+		t.Fatalf("Run error: %v", err)
+	}
+	if count != n || sum != n*(n+1)/2 || meta != "feed" {
+		t.Errorf("unexpected result: count %d, sum %d, meta %q", count, sum, meta)
+	}
+}
+
+func TestDecoderError(t *testing.T) {
+	stop := errors.New("stop")
+	d := NewDecoder(strings.NewReader(`<a><b>1</b><b>2</b></a>`))
+	count := 0
+	d.Handle("a/b", func(x *Item) error {
+		count++
+		return stop
+	})
+	if err := d.Run(); err != stop || count != 1 {
+		t.Errorf("unexpected result: %v, %d", err, count)
+	}
+
+	for _, s := range []string{`<a><b></a>`, `<a></a><b></b>`, `<a>`, ``} {
+		if err := NewDecoder(strings.NewReader(s)).Run(); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
@@ -7,53 +7,78 @@ package xmlconv
 import (
 	// "github.com/Andrew-M-C/go-tools/str"
 	// "github.com/Andrew-M-C/go-tools/log"
-	"encoding/xml"
 	"bytes"
-	"io"
 )
 
 type Item struct {
+	kind		NodeKind
 	name		string
+	space		string
+	prefix		string
 	data		[]byte
-	dataString	*string
 	attrs		map[string]string
-	child		map[string]*Item
+	attrOrder	[]attrName
+	ns			[]Namespace
+	child		[]*Item
+	parent		*Item
+	prolog		[]*Item
+	epilog		[]*Item
 }
 
 func (x *Item) Name() string {
 	return x.name
 }
 
+// Bytes returns the text of an element, i.e. all its direct text and CDATA
+// nodes concatenated, with leading and trailing white spaces trimmed. For
+// other kinds of nodes, it returns their content as is.
 func (x *Item) Bytes() []byte {
+	if ElementNode == x.kind {
+		return x.text()
+	}
 	return x.data
 }
 
+// SetData replaces the text of an element, or the content of other nodes
 func (x *Item) SetData(b []byte) {
-	x.data = b
-	x.dataString = nil
+	if ElementNode == x.kind {
+		x.setText(b)
+	} else {
+		x.data = b
+	}
 	return
 }
 
 func (x *Item) SetString(s string) {
-	x.data = []byte(s)
-	x.dataString = nil
+	x.SetData([]byte(s))
 	return
 }
 
 func (x *Item) String() string {
-	if nil == x.dataString {
-		s := string(x.data)
-		x.dataString = &s
-	}
-	return *(x.dataString)
+	return string(x.Bytes())
 }
 
+// Attrs returns the attribute map of the item itself. Entries added to it
+// directly are written after the existing attributes, sorted by name.
 func (x *Item) Attrs() map[string]string {
 	return x.attrs
 }
 
+// GetAttr reads an attribute by its name as written, e.g. "xsi:type", or by
+// "{uri}local". A name without prefix also matches prefixed attributes with
+// the same local name.
 func (x *Item) GetAttr(a string, defaultValue ...string) (string, bool) {
 	ret, exist := x.attrs[a]
+	if false == exist {
+		m := x.matcher(a)
+		for _, n := range x.attrNames() {
+			prefix, local := splitQName(n.qname)
+			if m.match(n.space, prefix, local) {
+				ret, exist = x.attrs[n.qname]
+				break
+			}
+		}
+	}
 	if false == exist && len(defaultValue) > 0 {
 		ret = defaultValue[0]
 	}
@@ -61,27 +86,79 @@ func (x *Item) GetAttr(a string, defaultValue ...string) (string, bool) {
 }
 
 func (x *Item) SetAttr(n, v string) {
+	if _, exist := x.attrs[n]; false == exist {
+		space := ""
+		if prefix, _ := splitQName(n); "" != prefix {
+			space, _ = x.LookupNamespace(prefix)
+		}
+		x.attrOrder = append(x.attrOrder, attrName{qname: n, space: space})
+	}
 	x.attrs[n] = v
 	return
 }
 
+func (x *Item) RemoveAttr(n string) {
+	if _, exist := x.attrs[n]; false == exist {
+		return
+	}
+	delete(x.attrs, n)
+	for i, a := range x.attrOrder {
+		if a.qname == n {
+			x.attrOrder = append(x.attrOrder[:i], x.attrOrder[i+1:]...)
+			break
+		}
+	}
+	return
+}
+
+// Children returns direct children by name. Only the first one is kept for
+// repeated names, use ChildList or ChildrenNamed for all of them.
+//
+// The map is built on each call, so adding or removing entries no longer
+// changes the item; use AppendChild, SetChild or RemoveChild instead. Earlier
+// versions kept the last of repeated elements.
+//
+// Deprecated: use ChildList, which keeps document order and every repeated
+// element.
 func (x *Item) Children() map[string]*Item {
-	return x.child
+	ret := make(map[string]*Item, len(x.child))
+	for _, c := range x.child {
+		if ElementNode != c.kind {
+			continue
+		}
+		if _, exist := ret[c.name]; false == exist {
+			ret[c.name] = c
+		}
+	}
+	return ret
 }
 
 
+// NewItem creates an element. name may be "prefix:local", with the prefix
+// resolved when the item is added to a parent. Use NewItemNS for a namespace
+// URI.
 func NewItem(name string) *Item {
 	// log.Debug("NewItem %s", name)
+	prefix, local := splitQName(name)
 	ret := Item{
-		name: name,
+		name: local,
+		prefix: prefix,
 		attrs: make(map[string]string),
-		child: make(map[string]*Item),
-		data: []byte(""),
+		child: make([]*Item, 0),
 	}
 	return &ret
 }
 
 
+// NewItemNS creates an element in namespace space. name may have a prefix to
+// be used when marshalling.
+func NewItemNS(space, name string) *Item {
+	ret := NewItem(name)
+	ret.space = space
+	return ret
+}
+
+
 func NewFromString(s string) (*Item, error) {
 	return NewFromBytes([]byte(s))
 }
@@ -93,70 +170,5 @@ func NewFromBytes(b []byte) (*Item, error) {
 	}
 
 	// log.Debug("input string: %s", string(b))
-	decoder := xml.NewDecoder(bytes.NewReader(b))
-	stk := newStack()
-	var curr *Item
-	var root *Item
-
-	for {
-		t, err := decoder.Token()
-		if err != nil {
-			if err == io.EOF {
-				// log.Debug("Parse XML finished!")
-				return root, nil
-			} else {
-				// log.Debug("Failed to Parse XML with the error of %v", err)
-				return nil, err
-			}
-			break
-		}
-		t = xml.CopyToken(t)
-
-		switch t := t.(type) {
-		case xml.StartElement:
-			// log.Debug("xml.StartElement")
-			name := t.Name.Local
-			item := NewItem(name)
-			attr := item.attrs
-			// log.Debug("name: %s", name)
-
-			for _, a := range t.Attr {
-				// log.Debug("attr: %s - %s", a.Name.Local, a.Value)
-				attr[a.Name.Local] = a.Value
-			}
-			if curr != nil {
-				curr.child[name] = item
-				stk.Push(curr)
-			} else {
-				root = item
-			}
-			curr = item
-
-		case xml.EndElement:
-			// log.Debug("xml.EndElement")
-			curr = stk.Pop()
-
-		case xml.CharData:
-			b := []byte(t)
-			b = bytes.Trim(b, "\r\n\t ")
-			if false == bytesEmpty(b) {
-				// log.Debug("xml.CharData: '%s'", string(b))
-				if curr != nil {
-					curr.data = b
-				}
-			}
-
-		case xml.Comment:
-			// ignore
-		}
-	}
-
-	return nil, FormatError
-}
-
-func bytesEmpty(b []byte) bool {
-	for _, _ = range b {
-		return false
-	}
-	return true
+	return NewFromReader(bytes.NewReader(b))
 }
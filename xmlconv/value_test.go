@@ -0,0 +1,98 @@
+package xmlconv
+
+import (
+	"testing"
+)
+
+func childNames(items []*Item) []string {
+	ret := make([]string, 0, len(items))
+	for _, c := range items {
+		ret = append(ret, c.Name()+"="+c.String())
+	}
+	return ret
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRepeatedChildren(t *testing.T) {
+	x, err := NewFromString(`<list><item>1</item><other>x</other><item>2</item><item>3</item></list>`)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+
+	if got := childNames(x.ChildList()); false == equalStrings(got, []string{"item=1", "other=x", "item=2", "item=3"}) {
+		t.Errorf("unexpected children: %v", got)
+	}
+	if got := childNames(x.ChildrenNamed("item")); false == equalStrings(got, []string{"item=1", "item=2", "item=3"}) {
+		t.Errorf("unexpected ChildrenNamed: %v", got)
+	}
+	if c, exist := x.GetChild("item"); false == exist || c.String() != "1" {
+		t.Errorf("GetChild should return the first item")
+	}
+	if c, exist := x.GetChildAt("item", 2); false == exist || c.String() != "3" {
+		t.Errorf("unexpected GetChildAt result")
+	}
+	if _, exist := x.GetChildAt("item", 3); exist {
+		t.Errorf("GetChildAt out of range should not exist")
+	}
+
+	// document order is kept when marshalling
+	s, _ := x.Marshal()
+	if s != `<list><item>1</item><other>x</other><item>2</item><item>3</item></list>` {
+		t.Errorf("unexpected marshal result: %s", s)
+	}
+}
+
+func TestGetChildren(t *testing.T) {
+	x, _ := NewFromString(`<r><a><b>1</b><b>2</b></a><a><b>3</b></a></r>`)
+	if got := childNames(x.GetChildren("a", "b")); false == equalStrings(got, []string{"b=1", "b=2", "b=3"}) {
+		t.Errorf("unexpected GetChildren: %v", got)
+	}
+	if got := x.GetChildren("a", "c"); len(got) != 0 {
+		t.Errorf("unexpected GetChildren: %v", got)
+	}
+}
+
+func TestModifyChildren(t *testing.T) {
+	x := NewItem("r")
+	b := x.AppendChild(NewItem("b"))
+	x.InsertChild(0, NewItem("a"))
+	x.InsertChild(100, NewItem("c"))
+	x.SetChildString("1", "b")
+	x.SetChildString("2", "d", "e")
+
+	s, _ := x.Marshal()
+	if s != `<r><a></a><b>1</b><c></c><d><e>2</e></d></r>` {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	// SetChild replaces in place
+	repl := NewItem("")
+	repl.SetString("new")
+	x.SetChild(repl, "b")
+	s, _ = x.Marshal()
+	if s != `<r><a></a><b>new</b><c></c><d><e>2</e></d></r>` {
+		t.Errorf("unexpected result: %s", s)
+	}
+
+	if x.RemoveChild(b) {
+		t.Errorf("replaced child should not be found")
+	}
+	if false == x.RemoveChild(repl) {
+		t.Errorf("RemoveChild failed")
+	}
+	s, _ = x.Marshal()
+	if s != `<r><a></a><c></c><d><e>2</e></d></r>` {
+		t.Errorf("unexpected result: %s", s)
+	}
+}
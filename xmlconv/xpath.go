@@ -0,0 +1,937 @@
+package xmlconv
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// XPathError is a syntax error in an XPath expression, or an error when
+// evaluating it
+type XPathError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *XPathError) Error() string {
+	if e.Offset < 0 {
+		return "xpath: " + e.Msg
+	}
+	return "xpath: " + e.Msg + " at offset " + strconv.Itoa(e.Offset)
+}
+
+// XPath is a compiled XPath 1.0 expression, safe for concurrent use on
+// different documents.
+//
+// Supported are location paths with axes child, descendant,
+// descendant-or-self, parent, ancestor, ancestor-or-self, following-sibling,
+// preceding-sibling, self and attribute, and their abbreviations "//", ".",
+// ".." and "@"; node tests by name, "*", "prefix:*", text(), node(),
+// comment() and processing-instruction(); predicates; unions; arithmetic,
+// comparison and boolean operators; and functions last, position, count,
+// name, local-name, namespace-uri, string, concat, starts-with, contains,
+// substring-before, substring-after, string-length, normalize-space, not,
+// true, false, boolean, number and sum. Variables are not supported.
+//
+// As everywhere in this package, a name without prefix matches elements in
+// any namespace, and a prefix is resolved in the scope of each element tested.
+type XPath struct {
+	src  string
+	expr xpExpr
+}
+
+// CompileXPath parses an XPath expression. Errors are *XPathError.
+func CompileXPath(expr string) (*XPath, error) {
+	e, err := parseXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &XPath{src: expr, expr: e}, nil
+}
+
+// MustCompileXPath is CompileXPath panicking on error
+func MustCompileXPath(expr string) *XPath {
+	p, err := CompileXPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source of the expression
+func (p *XPath) String() string {
+	return p.src
+}
+
+// Evaluate evaluates the expression with x as the context node. The result is
+// []*Item for node-sets, or float64, string or bool.
+func (p *XPath) Evaluate(x *Item) (interface{}, error) {
+	if nil == x {
+		return nil, ParaError
+	}
+	c := newXPContext(x)
+	return p.expr.eval(c)
+}
+
+// Find returns nodes selected by the expression in document order. Besides
+// elements, they may be text nodes, comments, or AttrNode items for
+// attributes.
+func (p *XPath) Find(x *Item) ([]*Item, error) {
+	v, err := p.Evaluate(x)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]*Item)
+	if false == ok {
+		return nil, &XPathError{Offset: -1, Msg: "expression does not select nodes"}
+	}
+	return nodes, nil
+}
+
+// FindOne returns the first node selected by the expression, or nil if none
+func (p *XPath) FindOne(x *Item) (*Item, error) {
+	nodes, err := p.Find(x)
+	if err != nil || 0 == len(nodes) {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// Find compiles expr and returns nodes it selects with x as the context node.
+// Use CompileXPath to evaluate an expression many times.
+func (x *Item) Find(expr string) ([]*Item, error) {
+	p, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(x)
+}
+
+// FindOne is Find returning the first node only, or nil if none
+func (x *Item) FindOne(expr string) (*Item, error) {
+	p, err := CompileXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.FindOne(x)
+}
+
+// ====================
+// evaluation
+
+type xpContext struct {
+	node *Item
+	pos  int
+	size int
+	doc  *Item
+}
+
+// newXPContext creates a document node above the root element of x
+func newXPContext(x *Item) *xpContext {
+	top := x
+	for nil != top.parent {
+		top = top.parent
+	}
+	if AttrNode == top.kind || documentNode == top.kind {
+		return &xpContext{node: x, pos: 1, size: 1, doc: top}
+	}
+	doc := &Item{kind: documentNode}
+	doc.child = append(doc.child, top.prolog...)
+	doc.child = append(doc.child, top)
+	doc.child = append(doc.child, top.epilog...)
+	return &xpContext{node: x, pos: 1, size: 1, doc: doc}
+}
+
+func (c *xpContext) with(node *Item, pos, size int) *xpContext {
+	return &xpContext{node: node, pos: pos, size: size, doc: c.doc}
+}
+
+func (c *xpContext) parentOf(n *Item) *Item {
+	if nil != n.parent {
+		return n.parent
+	}
+	if n == c.doc {
+		return nil
+	}
+	return c.doc
+}
+
+type xpExpr interface {
+	eval(c *xpContext) (interface{}, error)
+}
+
+type literalExpr struct {
+	v interface{}
+}
+
+func (e *literalExpr) eval(c *xpContext) (interface{}, error) {
+	return e.v, nil
+}
+
+type negExpr struct {
+	e xpExpr
+}
+
+func (e *negExpr) eval(c *xpContext) (interface{}, error) {
+	v, err := e.e.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	return -toNumber(v), nil
+}
+
+type binaryExpr struct {
+	op          string
+	left, right xpExpr
+}
+
+func (e *binaryExpr) eval(c *xpContext) (interface{}, error) {
+	l, err := e.left.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	switch e.op {
+	case "and":
+		if false == toBoolean(l) {
+			return false, nil
+		}
+	case "or":
+		if toBoolean(l) {
+			return true, nil
+		}
+	}
+	r, err := e.right.eval(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "and", "or":
+		return toBoolean(r), nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		return compareValues(e.op, l, r), nil
+	case "+":
+		return toNumber(l) + toNumber(r), nil
+	case "-":
+		return toNumber(l) - toNumber(r), nil
+	case "*":
+		return toNumber(l) * toNumber(r), nil
+	case "div":
+		return toNumber(l) / toNumber(r), nil
+	default:
+		// mod
+		return math.Mod(toNumber(l), toNumber(r)), nil
+	}
+}
+
+type unionExpr struct {
+	left, right xpExpr
+}
+
+func (e *unionExpr) eval(c *xpContext) (interface{}, error) {
+	l, err := evalNodes(e.left, c)
+	if err != nil {
+		return nil, err
+	}
+	r, err := evalNodes(e.right, c)
+	if err != nil {
+		return nil, err
+	}
+	return c.sortNodes(append(append([]*Item{}, l...), r...)), nil
+}
+
+func evalNodes(e xpExpr, c *xpContext) ([]*Item, error) {
+	v, err := e.eval(c)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := v.([]*Item)
+	if false == ok {
+		return nil, &XPathError{Offset: -1, Msg: "node-set expected"}
+	}
+	return nodes, nil
+}
+
+type filterExpr struct {
+	primary xpExpr
+	preds   []xpExpr
+}
+
+func (e *filterExpr) eval(c *xpContext) (interface{}, error) {
+	nodes, err := evalNodes(e.primary, c)
+	if err != nil {
+		return nil, err
+	}
+	return c.applyPredicates(nodes, e.preds)
+}
+
+type pathExpr struct {
+	absolute bool
+	filter   *filterExpr
+	steps    []*xpStep
+}
+
+func (e *pathExpr) eval(c *xpContext) (interface{}, error) {
+	var nodes []*Item
+	switch {
+	case nil != e.filter:
+		v, err := e.filter.eval(c)
+		if err != nil {
+			return nil, err
+		}
+		nodes = v.([]*Item)
+	case e.absolute:
+		nodes = []*Item{c.doc}
+	default:
+		nodes = []*Item{c.node}
+	}
+
+	// flat tells that no node is an ancestor of another, so that nodes
+	// selected from them by a forward axis within their subtrees are in
+	// document order without duplicates
+	flat := nil == e.filter
+	for _, s := range e.steps {
+		var next []*Item
+		for _, n := range nodes {
+			selected, err := c.applyStep(n, s)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		if s.axis.reverse() || (len(nodes) > 1 && false == (flat && s.axis.inSubtree())) {
+			next = c.sortNodes(next)
+		}
+		flat = len(next) <= 1 || ((flat || len(nodes) <= 1) && s.axis.flat())
+		nodes = next
+	}
+	if nil == nodes {
+		nodes = []*Item{}
+	}
+	return nodes, nil
+}
+
+func (c *xpContext) applyPredicates(nodes []*Item, preds []xpExpr) ([]*Item, error) {
+	for _, pred := range preds {
+		var kept []*Item
+		for i, n := range nodes {
+			v, err := pred.eval(c.with(n, i+1, len(nodes)))
+			if err != nil {
+				return nil, err
+			}
+			if f, ok := v.(float64); ok {
+				if f == float64(i+1) {
+					kept = append(kept, n)
+				}
+			} else if toBoolean(v) {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return nodes, nil
+}
+
+// ====================
+// steps
+
+type axisKind int
+
+const (
+	axisChild axisKind = iota
+	axisDescendant
+	axisDescendantOrSelf
+	axisParent
+	axisAncestor
+	axisAncestorOrSelf
+	axisFollowingSibling
+	axisPrecedingSibling
+	axisAttribute
+	axisSelf
+)
+
+// reverse tells whether positions in the axis count in reverse document order
+func (a axisKind) reverse() bool {
+	switch a {
+	case axisParent, axisAncestor, axisAncestorOrSelf, axisPrecedingSibling:
+		return true
+	}
+	return false
+}
+
+// inSubtree tells whether the axis selects nodes in document order within
+// the subtree of the context node
+func (a axisKind) inSubtree() bool {
+	switch a {
+	case axisChild, axisDescendant, axisDescendantOrSelf, axisAttribute, axisSelf:
+		return true
+	}
+	return false
+}
+
+// flat tells whether nodes selected by the axis from a context node are never
+// ancestors of each other
+func (a axisKind) flat() bool {
+	switch a {
+	case axisChild, axisAttribute, axisSelf:
+		return true
+	}
+	return false
+}
+
+type nodeTestKind int
+
+const (
+	testName nodeTestKind = iota
+	testNode
+	testText
+	testComment
+	testProcInst
+)
+
+type nodeTest struct {
+	kind   nodeTestKind
+	prefix string
+	// local is the name, "*", or the target of processing-instruction()
+	local string
+}
+
+type xpStep struct {
+	axis  axisKind
+	test  nodeTest
+	preds []xpExpr
+}
+
+func (c *xpContext) applyStep(n *Item, s *xpStep) ([]*Item, error) {
+	var nodes []*Item
+	c.axisNodes(n, s.axis, func(m *Item) {
+		if s.test.match(m, s.axis) {
+			nodes = append(nodes, m)
+		}
+	})
+	return c.applyPredicates(nodes, s.preds)
+}
+
+// axisNodes calls fn on nodes of the axis in axis order
+func (c *xpContext) axisNodes(n *Item, axis axisKind, fn func(*Item)) {
+	switch axis {
+	case axisSelf:
+		fn(n)
+	case axisChild:
+		if AttrNode != n.kind {
+			for _, m := range n.child {
+				fn(m)
+			}
+		}
+	case axisDescendantOrSelf:
+		fn(n)
+		c.axisNodes(n, axisDescendant, fn)
+	case axisDescendant:
+		if AttrNode != n.kind {
+			for _, m := range n.child {
+				fn(m)
+				c.axisNodes(m, axisDescendant, fn)
+			}
+		}
+	case axisParent:
+		if p := c.parentOf(n); nil != p {
+			fn(p)
+		}
+	case axisAncestorOrSelf:
+		fn(n)
+		c.axisNodes(n, axisAncestor, fn)
+	case axisAncestor:
+		for p := c.parentOf(n); nil != p; p = c.parentOf(p) {
+			fn(p)
+		}
+	case axisFollowingSibling, axisPrecedingSibling:
+		p := c.parentOf(n)
+		if nil == p || AttrNode == n.kind {
+			return
+		}
+		i := indexOfChild(p, n)
+		if axisFollowingSibling == axis {
+			for _, m := range p.child[i+1:] {
+				fn(m)
+			}
+		} else {
+			for j := i - 1; j >= 0; j-- {
+				fn(p.child[j])
+			}
+		}
+	case axisAttribute:
+		if ElementNode != n.kind {
+			return
+		}
+		for _, a := range n.attrNames() {
+			prefix, local := splitQName(a.qname)
+			fn(&Item{kind: AttrNode, name: local, prefix: prefix, space: a.space, data: []byte(n.attrs[a.qname]), parent: n})
+		}
+	}
+}
+
+func indexOfChild(p, n *Item) int {
+	for i, c := range p.child {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *nodeTest) match(n *Item, axis axisKind) bool {
+	switch t.kind {
+	case testNode:
+		return true
+	case testText:
+		return n.isText()
+	case testComment:
+		return CommentNode == n.kind
+	case testProcInst:
+		return ProcInstNode == n.kind && ("" == t.local || t.local == n.name)
+	}
+
+	// name test on the principal node type of the axis
+	if axisAttribute == axis {
+		if AttrNode != n.kind {
+			return false
+		}
+	} else if ElementNode != n.kind {
+		return false
+	}
+	if "*" == t.local {
+		return "" == t.prefix || t.prefix == n.prefix || t.resolve(n) == n.space
+	}
+	if t.local != n.name {
+		return false
+	}
+	if "" == t.prefix {
+		return true
+	}
+	if space := t.resolve(n); "" != space {
+		return space == n.space
+	}
+	return t.prefix == n.prefix
+}
+
+// resolve looks up the prefix of the test in the scope of n
+func (t *nodeTest) resolve(n *Item) string {
+	scope := n
+	if AttrNode == n.kind {
+		scope = n.parent
+	}
+	space, _ := scope.LookupNamespace(t.prefix)
+	return space
+}
+
+// ====================
+// document order
+
+// orderKey returns positions from the document node down to n, with
+// attributes before child nodes. Positions of children are looked up in pos,
+// which is filled once for each parent.
+func (c *xpContext) orderKey(n *Item, pos map[*Item]int) []int {
+	var key []int
+	for m := n; m != c.doc; {
+		p := c.parentOf(m)
+		if nil == p {
+			break
+		}
+		idx := -1
+		if AttrNode == m.kind {
+			names := p.attrNames()
+			for i, a := range names {
+				if a.qname == m.QName() {
+					idx = i - len(names)
+					break
+				}
+			}
+		} else {
+			i, exist := pos[m]
+			if false == exist {
+				for j, child := range p.child {
+					pos[child] = j
+				}
+				if i, exist = pos[m]; false == exist {
+					i = -1
+				}
+			}
+			idx = i
+		}
+		key = append(key, idx)
+		m = p
+	}
+	for i, j := 0, len(key)-1; i < j; i, j = i+1, j-1 {
+		key[i], key[j] = key[j], key[i]
+	}
+	return key
+}
+
+func compareKeys(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
+
+// sortNodes sorts nodes in document order and removes duplicates
+func (c *xpContext) sortNodes(nodes []*Item) []*Item {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	keys := make([][]int, len(nodes))
+	pos := make(map[*Item]int)
+	for i, n := range nodes {
+		keys[i] = c.orderKey(n, pos)
+	}
+	idx := make([]int, len(nodes))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return compareKeys(keys[idx[i]], keys[idx[j]]) < 0
+	})
+	ret := make([]*Item, 0, len(nodes))
+	for i, k := range idx {
+		if i > 0 && 0 == compareKeys(keys[k], keys[idx[i-1]]) {
+			continue
+		}
+		ret = append(ret, nodes[k])
+	}
+	return ret
+}
+
+// ====================
+// conversions
+
+// stringValue is the XPath string-value of a node, i.e. all descendant text
+// for elements
+func stringValue(n *Item) string {
+	if ElementNode != n.kind && documentNode != n.kind {
+		return string(n.data)
+	}
+	var b strings.Builder
+	var walk func(*Item)
+	walk = func(n *Item) {
+		for _, c := range n.child {
+			if c.isText() {
+				b.Write(c.data)
+			} else if ElementNode == c.kind {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return formatNumber(v)
+	case []*Item:
+		if 0 == len(v) {
+			return ""
+		}
+		return stringValue(v[0])
+	}
+	return ""
+}
+
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == math.Trunc(f) && math.Abs(f) < 1e15:
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func toNumber(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	return parseNumber(toString(v))
+}
+
+// parseNumber accepts the XPath Number syntax with optional minus sign
+func parseNumber(s string) float64 {
+	s = strings.TrimSpace(s)
+	body := strings.TrimPrefix(s, "-")
+	if "" == body || "." == body {
+		return math.NaN()
+	}
+	dot := false
+	for _, r := range body {
+		if '.' == r && false == dot {
+			dot = true
+		} else if r < '0' || r > '9' {
+			return math.NaN()
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+func toBoolean(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0 && false == math.IsNaN(v)
+	case string:
+		return "" != v
+	case []*Item:
+		return len(v) > 0
+	}
+	return false
+}
+
+func compareValues(op string, l, r interface{}) bool {
+	ln, lIsNodes := l.([]*Item)
+	rn, rIsNodes := r.([]*Item)
+	switch {
+	case lIsNodes && rIsNodes:
+		for _, a := range ln {
+			sa := stringValue(a)
+			for _, b := range rn {
+				if compareAtoms(op, sa, stringValue(b)) {
+					return true
+				}
+			}
+		}
+		return false
+	case lIsNodes:
+		if _, ok := r.(bool); ok {
+			return compareAtoms(op, toBoolean(l), r)
+		}
+		for _, a := range ln {
+			if compareAtoms(op, atomLike(stringValue(a), r), r) {
+				return true
+			}
+		}
+		return false
+	case rIsNodes:
+		if _, ok := l.(bool); ok {
+			return compareAtoms(op, l, toBoolean(r))
+		}
+		for _, b := range rn {
+			if compareAtoms(op, l, atomLike(stringValue(b), l)) {
+				return true
+			}
+		}
+		return false
+	}
+	return compareAtoms(op, l, r)
+}
+
+// atomLike converts a string value of node to the type of other
+func atomLike(s string, other interface{}) interface{} {
+	if _, ok := other.(float64); ok {
+		return parseNumber(s)
+	}
+	return s
+}
+
+func compareAtoms(op string, l, r interface{}) bool {
+	if "=" == op || "!=" == op {
+		var eq bool
+		_, lb := l.(bool)
+		_, rb := r.(bool)
+		_, lf := l.(float64)
+		_, rf := r.(float64)
+		switch {
+		case lb || rb:
+			eq = toBoolean(l) == toBoolean(r)
+		case lf || rf:
+			eq = toNumber(l) == toNumber(r)
+		default:
+			eq = toString(l) == toString(r)
+		}
+		return eq == ("=" == op)
+	}
+
+	a, b := toNumber(l), toNumber(r)
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default:
+		return a >= b
+	}
+}
+
+// ====================
+// functions
+
+type xpFunction struct {
+	minArgs int
+	maxArgs int // -1 for unlimited
+	fn      func(c *xpContext, args []xpExpr) (interface{}, error)
+}
+
+type callExpr struct {
+	name string
+	args []xpExpr
+	fn   func(c *xpContext, args []xpExpr) (interface{}, error)
+}
+
+func (e *callExpr) eval(c *xpContext) (interface{}, error) {
+	return e.fn(c, e.args)
+}
+
+var xpFunctions = map[string]xpFunction{
+	"last": {0, 0, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		return float64(c.size), nil
+	}},
+	"position": {0, 0, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		return float64(c.pos), nil
+	}},
+	"count": {1, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		nodes, err := evalNodes(args[0], c)
+		return float64(len(nodes)), err
+	}},
+	"name":          {0, 1, nodeNameFunc(func(n *Item) string { return n.QName() })},
+	"local-name":    {0, 1, nodeNameFunc(func(n *Item) string { return n.name })},
+	"namespace-uri": {0, 1, nodeNameFunc(func(n *Item) string { return n.space })},
+	"string": {0, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		return stringArg(c, args, 0)
+	}},
+	"concat": {2, -1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		var b strings.Builder
+		for i := range args {
+			s, err := stringArg(c, args, i)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(s)
+		}
+		return b.String(), nil
+	}},
+	"starts-with": {2, 2, stringPairFunc(func(a, b string) interface{} {
+		return strings.HasPrefix(a, b)
+	})},
+	"contains": {2, 2, stringPairFunc(func(a, b string) interface{} {
+		return strings.Contains(a, b)
+	})},
+	"substring-before": {2, 2, stringPairFunc(func(a, b string) interface{} {
+		if i := strings.Index(a, b); i >= 0 {
+			return a[:i]
+		}
+		return ""
+	})},
+	"substring-after": {2, 2, stringPairFunc(func(a, b string) interface{} {
+		if i := strings.Index(a, b); i >= 0 {
+			return a[i+len(b):]
+		}
+		return ""
+	})},
+	"string-length": {0, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		s, err := stringArg(c, args, 0)
+		return float64(len([]rune(s))), err
+	}},
+	"normalize-space": {0, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		s, err := stringArg(c, args, 0)
+		return strings.Join(strings.Fields(s), " "), err
+	}},
+	"not": {1, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		v, err := args[0].eval(c)
+		return false == toBoolean(v), err
+	}},
+	"true": {0, 0, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		return true, nil
+	}},
+	"false": {0, 0, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		return false, nil
+	}},
+	"boolean": {1, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		v, err := args[0].eval(c)
+		return toBoolean(v), err
+	}},
+	"number": {0, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		if 0 == len(args) {
+			return parseNumber(stringValue(c.node)), nil
+		}
+		v, err := args[0].eval(c)
+		return toNumber(v), err
+	}},
+	"sum": {1, 1, func(c *xpContext, args []xpExpr) (interface{}, error) {
+		nodes, err := evalNodes(args[0], c)
+		sum := 0.0
+		for _, n := range nodes {
+			sum += parseNumber(stringValue(n))
+		}
+		return sum, err
+	}},
+}
+
+// stringArg evaluates args[i] as string, or the context node if absent
+func stringArg(c *xpContext, args []xpExpr, i int) (string, error) {
+	if i >= len(args) {
+		return stringValue(c.node), nil
+	}
+	v, err := args[i].eval(c)
+	if err != nil {
+		return "", err
+	}
+	return toString(v), nil
+}
+
+func stringPairFunc(fn func(a, b string) interface{}) func(c *xpContext, args []xpExpr) (interface{}, error) {
+	return func(c *xpContext, args []xpExpr) (interface{}, error) {
+		a, err := stringArg(c, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		b, err := stringArg(c, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return fn(a, b), nil
+	}
+}
+
+func nodeNameFunc(fn func(n *Item) string) func(c *xpContext, args []xpExpr) (interface{}, error) {
+	return func(c *xpContext, args []xpExpr) (interface{}, error) {
+		n := c.node
+		if len(args) > 0 {
+			nodes, err := evalNodes(args[0], c)
+			if err != nil || 0 == len(nodes) {
+				return "", err
+			}
+			n = nodes[0]
+		}
+		if documentNode == n.kind {
+			return "", nil
+		}
+		return fn(n), nil
+	}
+}
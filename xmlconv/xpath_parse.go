@@ -0,0 +1,533 @@
+package xmlconv
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ====================
+// lexer
+
+type xpTokenKind int
+
+const (
+	xpEOF xpTokenKind = iota
+	xpName
+	xpNumber
+	xpString
+	xpOperator
+	xpPunct
+)
+
+type xpToken struct {
+	kind xpTokenKind
+	s    string
+	n    float64
+	pos  int
+}
+
+func isNameStart(r rune) bool {
+	return '_' == r || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || unicode.IsDigit(r) || '-' == r || '.' == r
+}
+
+// afterOperand tells whether t ends an operand, so that a following "*" or
+// name such as "div" is an operator. See XPath 1.0 section 3.7.
+func afterOperand(t *xpToken) bool {
+	if nil == t {
+		return false
+	}
+	switch t.kind {
+	case xpOperator:
+		return false
+	case xpPunct:
+		switch t.s {
+		case "@", "::", "(", "[", ",":
+			return false
+		}
+	}
+	return true
+}
+
+func xpLex(s string) ([]xpToken, error) {
+	var tokens []xpToken
+	var prev *xpToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		start := i
+		var t xpToken
+
+		switch {
+		case ' ' == c || '\t' == c || '\r' == c || '\n' == c:
+			i++
+			continue
+
+		case strings.ContainsRune("()[],@|+=", rune(c)):
+			t = xpToken{kind: xpPunct, s: s[i : i+1]}
+			if '|' == c || '+' == c || '=' == c {
+				t.kind = xpOperator
+			}
+			i++
+
+		case '-' == c:
+			t = xpToken{kind: xpOperator, s: "-"}
+			i++
+
+		case '!' == c:
+			if i+1 >= len(s) || s[i+1] != '=' {
+				return nil, &XPathError{Offset: i, Msg: "unexpected '!'"}
+			}
+			t = xpToken{kind: xpOperator, s: "!="}
+			i += 2
+
+		case '<' == c || '>' == c:
+			t = xpToken{kind: xpOperator, s: s[i : i+1]}
+			i++
+			if i < len(s) && '=' == s[i] {
+				t.s += "="
+				i++
+			}
+
+		case '/' == c:
+			t = xpToken{kind: xpOperator, s: "/"}
+			i++
+			if i < len(s) && '/' == s[i] {
+				t.s = "//"
+				i++
+			}
+
+		case ':' == c:
+			if i+1 >= len(s) || s[i+1] != ':' {
+				return nil, &XPathError{Offset: i, Msg: "unexpected ':'"}
+			}
+			t = xpToken{kind: xpPunct, s: "::"}
+			i += 2
+
+		case '.' == c && (i+1 >= len(s) || s[i+1] < '0' || s[i+1] > '9'):
+			t = xpToken{kind: xpPunct, s: "."}
+			i++
+			if i < len(s) && '.' == s[i] {
+				t.s = ".."
+				i++
+			}
+
+		case '.' == c || (c >= '0' && c <= '9'):
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+			if i < len(s) && '.' == s[i] {
+				i++
+				for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+					i++
+				}
+			}
+			n, _ := strconv.ParseFloat(s[start:i], 64)
+			t = xpToken{kind: xpNumber, s: s[start:i], n: n}
+
+		case '"' == c || '\'' == c:
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, &XPathError{Offset: i, Msg: "unterminated string literal"}
+			}
+			t = xpToken{kind: xpString, s: s[i+1 : i+1+end]}
+			i += end + 2
+
+		case '*' == c:
+			t = xpToken{kind: xpName, s: "*"}
+			if afterOperand(prev) {
+				t.kind = xpOperator
+			}
+			i++
+
+		case '$' == c:
+			return nil, &XPathError{Offset: i, Msg: "variables are not supported"}
+
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if false == isNameStart(r) {
+				return nil, &XPathError{Offset: i, Msg: "unexpected character " + strconv.QuoteRune(r)}
+			}
+			i = scanName(s, i+size)
+			// prefix:local or prefix:*
+			if i+1 < len(s) && ':' == s[i] && ':' != s[i+1] {
+				if '*' == s[i+1] {
+					i += 2
+				} else if r, size := utf8.DecodeRuneInString(s[i+1:]); isNameStart(r) {
+					i = scanName(s, i+1+size)
+				}
+			}
+			t = xpToken{kind: xpName, s: s[start:i]}
+			if afterOperand(prev) {
+				switch t.s {
+				case "and", "or", "mod", "div":
+					t.kind = xpOperator
+				default:
+					return nil, &XPathError{Offset: start, Msg: "unexpected name " + strconv.Quote(t.s)}
+				}
+			}
+		}
+
+		t.pos = start
+		tokens = append(tokens, t)
+		prev = &tokens[len(tokens)-1]
+	}
+	tokens = append(tokens, xpToken{kind: xpEOF, pos: len(s)})
+	return tokens, nil
+}
+
+func scanName(s string, i int) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if false == isNameChar(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// ====================
+// parser
+
+type xpParser struct {
+	tokens []xpToken
+	i      int
+}
+
+func (p *xpParser) peek() *xpToken {
+	return &p.tokens[p.i]
+}
+
+func (p *xpParser) peekAt(n int) *xpToken {
+	if p.i+n >= len(p.tokens) {
+		return &p.tokens[len(p.tokens)-1]
+	}
+	return &p.tokens[p.i+n]
+}
+
+func (p *xpParser) next() *xpToken {
+	t := &p.tokens[p.i]
+	if t.kind != xpEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *xpParser) is(kind xpTokenKind, s string) bool {
+	t := p.peek()
+	return t.kind == kind && t.s == s
+}
+
+func (p *xpParser) expect(kind xpTokenKind, s string) error {
+	if false == p.is(kind, s) {
+		return p.errorf("expected '" + s + "'")
+	}
+	p.next()
+	return nil
+}
+
+func (p *xpParser) errorf(msg string) error {
+	t := p.peek()
+	if xpEOF == t.kind {
+		return &XPathError{Offset: t.pos, Msg: msg + ", got end of expression"}
+	}
+	return &XPathError{Offset: t.pos, Msg: msg}
+}
+
+func parseXPath(s string) (xpExpr, error) {
+	tokens, err := xpLex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &xpParser{tokens: tokens}
+	e, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != xpEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return e, nil
+}
+
+// binary operators from lowest precedence
+var xpPrecedence = [][]string{
+	{"or"},
+	{"and"},
+	{"=", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "div", "mod"},
+}
+
+func (p *xpParser) parseBinary(level int) (xpExpr, error) {
+	if level >= len(xpPrecedence) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != xpOperator || false == containsString(xpPrecedence[level], t.s) {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.s, left: left, right: right}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *xpParser) parseUnary() (xpExpr, error) {
+	if p.is(xpOperator, "-") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negExpr{e: e}, nil
+	}
+	left, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	for p.is(xpOperator, "|") {
+		p.next()
+		right, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		left = &unionExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+var xpNodeTypes = map[string]nodeTestKind{
+	"node":                   testNode,
+	"text":                   testText,
+	"comment":                testComment,
+	"processing-instruction": testProcInst,
+}
+
+func isNodeType(name string) bool {
+	_, exist := xpNodeTypes[name]
+	return exist
+}
+
+// startsStep tells whether the next token begins a location step
+func (p *xpParser) startsStep() bool {
+	t := p.peek()
+	switch t.kind {
+	case xpName:
+		return true
+	case xpPunct:
+		return "@" == t.s || "." == t.s || ".." == t.s
+	}
+	return false
+}
+
+func (p *xpParser) parsePath() (xpExpr, error) {
+	t := p.peek()
+	path := &pathExpr{}
+
+	switch {
+	case xpOperator == t.kind && ("/" == t.s || "//" == t.s):
+		path.absolute = true
+		p.next()
+		if "//" == t.s {
+			path.steps = append(path.steps, descendantOrSelfStep())
+		} else if false == p.startsStep() {
+			return path, nil
+		}
+
+	case xpString == t.kind || xpNumber == t.kind || (xpPunct == t.kind && "(" == t.s) ||
+		(xpName == t.kind && p.peekAt(1).kind == xpPunct && "(" == p.peekAt(1).s && false == isNodeType(t.s)):
+		primary, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		filter := &filterExpr{primary: primary}
+		if filter.preds, err = p.parsePredicates(); err != nil {
+			return nil, err
+		}
+		if false == p.is(xpOperator, "/") && false == p.is(xpOperator, "//") {
+			if 0 == len(filter.preds) {
+				return primary, nil
+			}
+			return filter, nil
+		}
+		path.filter = filter
+		if p.next().s == "//" {
+			path.steps = append(path.steps, descendantOrSelfStep())
+		}
+
+	case false == p.startsStep():
+		return nil, p.errorf("expected expression")
+	}
+
+	for {
+		s, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, s)
+		if p.is(xpOperator, "/") {
+			p.next()
+		} else if p.is(xpOperator, "//") {
+			p.next()
+			path.steps = append(path.steps, descendantOrSelfStep())
+		} else {
+			return path, nil
+		}
+	}
+}
+
+func descendantOrSelfStep() *xpStep {
+	return &xpStep{axis: axisDescendantOrSelf, test: nodeTest{kind: testNode}}
+}
+
+var xpAxes = map[string]axisKind{
+	"child":              axisChild,
+	"descendant":         axisDescendant,
+	"descendant-or-self": axisDescendantOrSelf,
+	"parent":             axisParent,
+	"ancestor":           axisAncestor,
+	"ancestor-or-self":   axisAncestorOrSelf,
+	"following-sibling":  axisFollowingSibling,
+	"preceding-sibling":  axisPrecedingSibling,
+	"attribute":          axisAttribute,
+	"self":               axisSelf,
+}
+
+func (p *xpParser) parseStep() (*xpStep, error) {
+	if p.is(xpPunct, ".") {
+		p.next()
+		return &xpStep{axis: axisSelf, test: nodeTest{kind: testNode}}, nil
+	}
+	if p.is(xpPunct, "..") {
+		p.next()
+		return &xpStep{axis: axisParent, test: nodeTest{kind: testNode}}, nil
+	}
+
+	s := &xpStep{axis: axisChild}
+	if p.is(xpPunct, "@") {
+		p.next()
+		s.axis = axisAttribute
+	} else if t := p.peek(); xpName == t.kind && p.peekAt(1).kind == xpPunct && "::" == p.peekAt(1).s {
+		axis, exist := xpAxes[t.s]
+		if false == exist {
+			return nil, p.errorf("unsupported axis " + strconv.Quote(t.s))
+		}
+		s.axis = axis
+		p.next()
+		p.next()
+	}
+
+	t := p.peek()
+	if t.kind != xpName {
+		return nil, p.errorf("expected node test")
+	}
+	p.next()
+	if kind, exist := xpNodeTypes[t.s]; exist && p.is(xpPunct, "(") {
+		p.next()
+		s.test.kind = kind
+		if testProcInst == kind && xpString == p.peek().kind {
+			s.test.local = p.next().s
+		}
+		if err := p.expect(xpPunct, ")"); err != nil {
+			return nil, err
+		}
+	} else {
+		s.test.kind = testName
+		s.test.prefix, s.test.local = splitQName(t.s)
+	}
+
+	var err error
+	if s.preds, err = p.parsePredicates(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *xpParser) parsePredicates() ([]xpExpr, error) {
+	var preds []xpExpr
+	for p.is(xpPunct, "[") {
+		p.next()
+		e, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(xpPunct, "]"); err != nil {
+			return nil, err
+		}
+		preds = append(preds, e)
+	}
+	return preds, nil
+}
+
+func (p *xpParser) parsePrimary() (xpExpr, error) {
+	t := p.next()
+	switch t.kind {
+	case xpString:
+		return &literalExpr{v: t.s}, nil
+	case xpNumber:
+		return &literalExpr{v: t.n}, nil
+	case xpPunct:
+		// "("
+		e, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(xpPunct, ")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	// function call
+	fn, exist := xpFunctions[t.s]
+	if false == exist {
+		return nil, &XPathError{Offset: t.pos, Msg: "unknown function " + strconv.Quote(t.s)}
+	}
+	p.next()
+	call := &callExpr{name: t.s, fn: fn.fn}
+	if false == p.is(xpPunct, ")") {
+		for {
+			e, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, e)
+			if false == p.is(xpPunct, ",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expect(xpPunct, ")"); err != nil {
+		return nil, err
+	}
+	if len(call.args) < fn.minArgs || (fn.maxArgs >= 0 && len(call.args) > fn.maxArgs) {
+		return nil, &XPathError{Offset: t.pos, Msg: "wrong number of arguments for " + t.s + "()"}
+	}
+	return call, nil
+}
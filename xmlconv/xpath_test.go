@@ -0,0 +1,176 @@
+package xmlconv
+
+import (
+	"strings"
+	"testing"
+)
+
+const catalogXML = `<?xml version="1.0"?>
+<catalog xmlns:x="urn:x">
+  <product id="1" type="book"><name>Go in Action</name><price>30</price></product>
+  <product id="2" type="food"><name> Apple  pie </name><price>5</price></product>
+  <!-- discontinued -->
+  <product id="3" type="book"><name>XML Basics</name><price>12.5</price><x:tag>old</x:tag></product>
+  <section><product id="4" type="toy"><name>Ball</name><price>8</price></product></section>
+</catalog>`
+
+func findStrings(t *testing.T, x *Item, expr string) []string {
+	nodes, err := x.Find(expr)
+	if err != nil {
+		t.Fatalf("Find(%s) error: %v", expr, err)
+	}
+	ret := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		switch {
+		case ElementNode != n.Kind() || "name" == n.Name():
+			ret = append(ret, stringValue(n))
+		case len(n.Attrs()) > 0:
+			id, _ := n.GetAttr("id")
+			ret = append(ret, n.Name()+"#"+id)
+		default:
+			ret = append(ret, n.Name())
+		}
+	}
+	return ret
+}
+
+func TestXPathFind(t *testing.T) {
+	x, err := NewFromString(catalogXML)
+	if err != nil {
+		t.Fatalf("NewFromString error: %v", err)
+	}
+
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{"/catalog/product", "product#1,product#2,product#3"},
+		{"//product", "product#1,product#2,product#3,product#4"},
+		{"product[2]", "product#2"},
+		{"product[last()]", "product#3"},
+		{"//product[@type='book'][2]", "product#3"},
+		{"//product[price > 10]/name", "Go in Action,XML Basics"},
+		{"//product[@id=2]/name/text()", " Apple  pie "},
+		{"//name[normalize-space()='Apple pie']/..", "product#2"},
+		{"//product[contains(name, 'XML') or starts-with(name, 'Ball')]", "product#3,product#4"},
+		{"//product/@id", "1,2,3,4"},
+		{"product[1]/following-sibling::product", "product#2,product#3"},
+		{"product[3]/preceding-sibling::*[1]", "product#2"},
+		{"//price[. = 8]/ancestor::*", "catalog,section,product#4"},
+		{"//x:tag/text()", "old"},
+		{"//product[x:tag]/@id", "3"},
+		{"comment()", " discontinued "},
+		{"//product[@id=1] | //product[@id=4] | //product[@id=1]", "product#1,product#4"},
+		{"(//product)[position() > 3]", "product#4"},
+		{"//section/descendant::price", "price"},
+		{"//product[not(@type='book')][count(*) = 2]/@id", "2,4"},
+	}
+	for _, c := range cases {
+		got := findStrings(t, x, c.expr)
+		if strings.Join(got, ",") != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.expr, c.expected, strings.Join(got, ","))
+		}
+	}
+}
+
+func TestXPathEvaluate(t *testing.T) {
+	x, _ := NewFromString(catalogXML)
+	cases := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{"count(//product)", 4.0},
+		{"sum(//price)", 55.5},
+		{"string(//product[2]/@type)", "food"},
+		{"concat(name(/*), '-', 1 + 2 * 3)", "catalog-7"},
+		{"10 div 4 - 5 mod 3", 0.5},
+		{"//price = 5", true},
+		{"//price != 5", true},
+		{"not(//price > 100)", true},
+		{"string-length(substring-before('a-b', '-'))", 1.0},
+		{"local-name(//x:tag) = 'tag' and namespace-uri(//x:tag) = 'urn:x'", true},
+	}
+	for _, c := range cases {
+		p, err := CompileXPath(c.expr)
+		if err != nil {
+			t.Fatalf("CompileXPath(%s) error: %v", c.expr, err)
+		}
+		v, err := p.Evaluate(x)
+		if err != nil {
+			t.Fatalf("Evaluate(%s) error: %v", c.expr, err)
+		}
+		if v != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.expr, c.expected, v)
+		}
+	}
+}
+
+func TestXPathReuse(t *testing.T) {
+	p := MustCompileXPath("/xml/return_code")
+	for _, s := range []string{"SUCCESS", "FAIL"} {
+		x, _ := NewFromString("<xml><return_code>" + s + "</return_code></xml>")
+		n, err := p.FindOne(x)
+		if err != nil || nil == n || n.String() != s {
+			t.Errorf("unexpected result for %s: %v, %v", s, n, err)
+		}
+	}
+
+	// relative to a sub element
+	x, _ := NewFromString(catalogXML)
+	section, _ := x.GetChild("section")
+	if n, _ := section.FindOne("product/name"); nil == n || n.String() != "Ball" {
+		t.Errorf("unexpected relative result")
+	}
+	if n, _ := section.FindOne("/catalog/product/name"); nil == n || n.String() != "Go in Action" {
+		t.Errorf("unexpected absolute result")
+	}
+	if n, _ := section.FindOne("missing"); nil != n {
+		t.Errorf("expected nil")
+	}
+}
+
+func TestXPathError(t *testing.T) {
+	for _, expr := range []string{"", "/a[", "//a]", "foo(1)", "a/following::b", "1 +", "@", "'abc", "$v", "count(1)"} {
+		_, err := CompileXPath(expr)
+		if "count(1)" == expr {
+			// type errors are found when evaluating
+			x := NewItem("a")
+			_, err = MustCompileXPath(expr).Evaluate(x)
+		}
+		if err == nil {
+			t.Errorf("%q: expected error", expr)
+			continue
+		}
+		if _, ok := err.(*XPathError); false == ok {
+			t.Errorf("%q: unexpected error type %T", expr, err)
+		}
+	}
+}
+
+func TestXPathDocumentOrder(t *testing.T) {
+	// nested context nodes still give results in document order
+	x, _ := NewFromString(`<r><x><a><x><y>1</y></x></a><y>2</y></x><x><y>3</y></x></r>`)
+	if got := strings.Join(findStrings(t, x, "//x/y/text()"), ","); got != "1,2,3" {
+		t.Errorf("unexpected //x/y result: %s", got)
+	}
+	if got := strings.Join(findStrings(t, x, "//x//y/text()"), ","); got != "1,2,3" {
+		t.Errorf("unexpected //x//y result: %s", got)
+	}
+	if got := strings.Join(findStrings(t, x, "//y/ancestor::x/y/text()"), ","); got != "1,2,3" {
+		t.Errorf("unexpected ancestor result: %s", got)
+	}
+
+	// many siblings
+	b := strings.Builder{}
+	b.WriteString("<c>")
+	for i := 0; i < 20000; i++ {
+		b.WriteString("<p><name>n</name></p>")
+	}
+	b.WriteString("</c>")
+	x, _ = NewFromString(b.String())
+	for _, expr := range []string{"/c/p/name", "//name", "/c/p[last()]/preceding-sibling::p/name | /c/p[last()]/name"} {
+		if nodes, err := x.Find(expr); err != nil || len(nodes) != 20000 {
+			t.Errorf("%s: unexpected result of %d nodes, %v", expr, len(nodes), err)
+		}
+	}
+}